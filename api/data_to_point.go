@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"reflect"
-	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
@@ -14,7 +13,10 @@ import (
 // 'lp' prefix and values measurement,tag, field or timestamp.
 // Valid point must contain measurement and at least one field.
 //
-// A field with timestamp must be of a type time.Time
+// A field with timestamp must be of a type time.Time or *time.Time.
+// Fields can be pointers, nil pointers are omitted. Empty fields and tags annotated by omitempty, e.g. `lp:"field,name,omitempty"`, are omitted.
+// Types implementing PointValueMarshaler provide their own values.
+// The layout of a struct type is parsed once and cached, see also StructEncoder.
 //
 //	 type TemperatureSensor struct {
 //		  Measurement string `lp:"measurement"`
@@ -35,55 +37,52 @@ func DataToPoint(x interface{}) (*write.Point, error) {
 	if t.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("cannot use %v as point", t)
 	}
-	fields := reflect.VisibleFields(t)
+	layout, err := structLayoutOf(t)
+	if err != nil {
+		return nil, err
+	}
 
 	var measurement = ""
 	var lpTags = make(map[string]string)
 	var lpFields = make(map[string]interface{})
 	var lpTime time.Time
 
-	for _, f := range fields {
-		name := f.Name
-		if tag, ok := f.Tag.Lookup("lp"); ok {
-			if tag == "-" {
-				continue
-			}
-			parts := strings.Split(tag, ",")
-			if len(parts) > 2 {
-				return nil, fmt.Errorf("multiple tag attributes are not supported")
-			}
-			typ := parts[0]
-			if len(parts) == 2 {
-				name = parts[1]
-			}
-			t := getFieldType(v.FieldByIndex(f.Index))
-			if !validFieldType(t) {
-				return nil, fmt.Errorf("cannot use field '%s' of type '%v' as to create a point", f.Name, t)
+	if fv := layout.measurement.value(v); fv.IsValid() {
+		if measurement, err = stringValue(layout.measurement, fv); err != nil {
+			return nil, err
+		}
+	}
+	for _, sf := range layout.tags {
+		if fv := sf.value(v); fv.IsValid() {
+			if lpTags[sf.name], err = stringValue(sf, fv); err != nil {
+				return nil, err
 			}
-			switch typ {
-			case "measurement":
-				if measurement != "" {
-					return nil, fmt.Errorf("multiple measurement fields")
-				}
-				measurement = v.FieldByIndex(f.Index).String()
-			case "tag":
-				if name == "" {
-					return nil, fmt.Errorf("cannot use field '%s': invalid lp tag name \"\"", f.Name)
-				}
-				lpTags[name] = v.FieldByIndex(f.Index).String()
-			case "field":
-				if name == "" {
-					return nil, fmt.Errorf("cannot use field '%s': invalid lp field name \"\"", f.Name)
-				}
-				lpFields[name] = v.FieldByIndex(f.Index).Interface()
-			case "timestamp":
-				if f.Type != timeType {
-					return nil, fmt.Errorf("cannot use field '%s' as a timestamp", f.Name)
-				}
-				lpTime = v.FieldByIndex(f.Index).Interface().(time.Time)
-			default:
-				return nil, fmt.Errorf("invalid tag %s", typ)
+		}
+	}
+	for _, sf := range layout.fields {
+		fv := sf.value(v)
+		if !fv.IsValid() {
+			continue
+		}
+		var value interface{}
+		if sf.marshaler {
+			if value, err = sf.marshal(fv); err != nil {
+				return nil, err
 			}
+		} else {
+			value = fv.Interface()
+		}
+		if value == nil {
+			continue
+		}
+		if t := reflect.TypeOf(value); !validFieldType(t) {
+			return nil, fmt.Errorf("cannot use field '%s' of type '%v' as to create a point", sf.fieldName, t)
+		}
+		lpFields[sf.name] = value
+	}
+	if layout.timestamp != nil {
+		if fv := layout.timestamp.value(v); fv.IsValid() {
+			lpTime = fv.Interface().(time.Time)
 		}
 	}
 	if measurement == "" {
@@ -146,6 +146,30 @@ func TestDataToPoint(t *testing.T) {
 			},
 			error: `no struct field with tag 'field'`,
 		},
+		{
+			name: "test empty measurement",
+			s: &struct {
+				Measurement string  `lp:"measurement"`
+				Temp        float64 `lp:"field"`
+			}{
+				"",
+				23.5,
+			},
+			error: `no struct field with tag 'measurement'`,
+		},
+		{
+			name: "test nil and omitted fields only",
+			s: &struct {
+				Measurement string   `lp:"measurement"`
+				Sensor      string   `lp:"tag,t"`
+				Temp        *float64 `lp:"field,temp"`
+				Hum         int      `lp:"field,hum,omitempty"`
+			}{
+				Measurement: "air",
+				Sensor:      "x",
+			},
+			error: `no struct field with tag 'field'`,
+		},
 		{
 			name: "test double measurement",
 			s: &struct {
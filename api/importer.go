@@ -0,0 +1,506 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// CheckpointSuffix is appended to the path of an imported file to get the path of its checkpoint file
+const CheckpointSuffix = ".checkpoint"
+
+// maxReportedLines limits number of skipped, invalid and rejected lines kept in ImportReport
+const maxReportedLines = 1000
+
+// ImportCheckpoint describes progress of an import. It is stored as JSON in the checkpoint file after each written batch.
+type ImportCheckpoint struct {
+	// Offset is position in the imported file in bytes, after the last line of the last written batch
+	Offset int64 `json:"offset"`
+	// Line is number of lines of the file up to Offset, including empty lines and comments.
+	// For CSV, it is number of CSV records.
+	Line int64 `json:"line"`
+	// LinesCommitted is number of lines written to the server
+	LinesCommitted int64 `json:"linesCommitted"`
+	// LinesSkipped is number of invalid lines, which were not sent to the server
+	LinesSkipped int64 `json:"linesSkipped"`
+	// LinesRejected is number of lines rejected by the server
+	LinesRejected int64 `json:"linesRejected"`
+	// LinesInvalid is number of lines rejected by validation configured in write options, which were not sent to the server
+	LinesInvalid int64 `json:"linesInvalid"`
+}
+
+// ImportedLine describes a line of the imported file, which was not written
+type ImportedLine struct {
+	// Line is number of the line in the file, starting from 1
+	Line int64
+	// Text of the line. For CSV, it is line protocol of the row when reported by the server, otherwise empty.
+	Text string
+	// Reason why the line was not written
+	Reason string
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	// ImportCheckpoint holds totals of the import, including lines imported before it was resumed
+	ImportCheckpoint
+	// Resumed is true if the import continued from a checkpoint
+	Resumed bool
+	// Batches is number of batches sent by this run of the import
+	Batches int64
+	// Skipped holds invalid lines skipped by this run of the import, at most 1000 lines are kept
+	Skipped []ImportedLine
+	// Rejected holds lines rejected by the server in this run of the import, at most 1000 lines are kept
+	Rejected []ImportedLine
+	// Invalid holds lines rejected by validation configured in write options in this run of the import, at most 1000 lines are kept
+	Invalid []ImportedLine
+}
+
+// ImportProgressCallback is synchronously notified about progress of an import after each written batch.
+// size is size of the imported data in bytes.
+type ImportProgressCallback func(checkpoint ImportCheckpoint, size int64)
+
+// Importer writes large line protocol or annotated CSV files using WriteAPIBlocking.
+// After each written batch, the position in the file is recorded in a checkpoint file.
+// When an import fails, e.g. because of a network error or a crash of the application, it can be started again
+// and it resumes from the last checkpoint. The checkpoint file is removed when the import finishes.
+//
+// Line protocol is expected to have one record per line. Invalid lines and CSV rows are skipped, so that they don't cause
+// rejecting of whole batches. Lines rejected by the server are counted and writing continues.
+// Lines rejected by validation configured in write options are not sent and they are counted separately.
+// Skipped, invalid and rejected lines are listed in ImportReport.
+type Importer struct {
+	writeAPI   WriteAPIBlocking
+	batchSize  int
+	precision  time.Duration
+	progressCb ImportProgressCallback
+}
+
+// NewImporter creates Importer writing by writeAPI in batches of 5000 lines
+func NewImporter(writeAPI WriteAPIBlocking) *Importer {
+	return &Importer{writeAPI: writeAPI, batchSize: 5_000, precision: time.Nanosecond}
+}
+
+// SetBatchSize sets number of lines written in a batch. Default 5000.
+func (i *Importer) SetBatchSize(batchSize uint) *Importer {
+	if batchSize == 0 {
+		batchSize = 1
+	}
+	i.batchSize = int(batchSize)
+	return i
+}
+
+// SetPrecision sets precision of timestamps of imported lines, used to validate them. Default time.Nanosecond.
+func (i *Importer) SetPrecision(precision time.Duration) *Importer {
+	i.precision = precision
+	return i
+}
+
+// SetProgressCallback sets callback notified about progress after each written batch
+func (i *Importer) SetProgressCallback(cb ImportProgressCallback) *Importer {
+	i.progressCb = cb
+	return i
+}
+
+// ImportFile imports line protocol file. The checkpoint is stored in the file with CheckpointSuffix appended to path.
+func (i *Importer) ImportFile(ctx context.Context, path string) (*ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return i.Import(ctx, f, path+CheckpointSuffix)
+}
+
+// Import imports line protocol read from r, recording checkpoints in checkpointPath.
+// If checkpointPath exists, the import resumes from the recorded position.
+func (i *Importer) Import(ctx context.Context, r io.ReadSeeker, checkpointPath string) (*ImportReport, error) {
+	return i.importFrom(ctx, r, checkpointPath, func(cp *ImportCheckpoint) (importSource, error) {
+		if _, err := r.Seek(cp.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return &lpSource{reader: bufio.NewReader(r), offset: cp.Offset, line: cp.Line, precision: i.precision}, nil
+	})
+}
+
+// ImportCSVFile imports annotated CSV file, see write.AnnotatedCSVReader for supported annotations.
+// The checkpoint is stored in the file with CheckpointSuffix appended to path.
+func (i *Importer) ImportCSVFile(ctx context.Context, path string) (*ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return i.ImportCSV(ctx, f, path+CheckpointSuffix)
+}
+
+// ImportCSV imports annotated CSV read from r, recording checkpoints in checkpointPath.
+// If checkpointPath exists, the import resumes after the recorded number of CSV records,
+// because annotations at the beginning of the data must be read again.
+// Line of ImportCheckpoint is number of CSV records and Offset is approximate, it is useful only for reporting progress.
+func (i *Importer) ImportCSV(ctx context.Context, r io.ReadSeeker, checkpointPath string) (*ImportReport, error) {
+	return i.importFrom(ctx, r, checkpointPath, func(cp *ImportCheckpoint) (importSource, error) {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		counter := &countingReader{reader: r}
+		return &csvSource{reader: write.NewAnnotatedCSVReader(counter), counter: counter, resume: cp.Line}, nil
+	})
+}
+
+// importRecord is a record read from imported data, either line protocol or point
+type importRecord struct {
+	line  string
+	point *write.Point
+	// number of the line in the file
+	number int64
+}
+
+// importSource reads records of imported data
+type importSource interface {
+	// next returns the next record, or description of a skipped invalid line. It returns io.EOF at the end.
+	next() (*importRecord, *ImportedLine, error)
+	// position returns position after the last read record, stored in a checkpoint
+	position() (offset int64, line int64)
+}
+
+// importFrom imports records from the source created by open, starting from the checkpoint
+func (i *Importer) importFrom(ctx context.Context, r io.Seeker, checkpointPath string, open func(cp *ImportCheckpoint) (importSource, error)) (*ImportReport, error) {
+	report := &ImportReport{}
+	cp, err := readCheckpoint(checkpointPath)
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if cp != nil {
+		if cp.Offset > size {
+			return nil, fmt.Errorf("checkpoint %s: offset %d is beyond end of data of size %d", checkpointPath, cp.Offset, size)
+		}
+		report.ImportCheckpoint = *cp
+		report.Resumed = true
+	}
+	src, err := open(&report.ImportCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	batch := make([]*importRecord, 0, i.batchSize)
+	for {
+		rec, skipped, rerr := src.next()
+		if rerr != nil && !errors.Is(rerr, io.EOF) {
+			return report, rerr
+		}
+		if skipped != nil {
+			report.LinesSkipped++
+			if len(report.Skipped) < maxReportedLines {
+				report.Skipped = append(report.Skipped, *skipped)
+			}
+		}
+		if rec != nil {
+			batch = append(batch, rec)
+		}
+		if len(batch) == i.batchSize || rerr != nil {
+			if err := i.writeBatch(ctx, batch, report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+			report.Offset, report.Line = src.position()
+			if err := writeCheckpoint(checkpointPath, &report.ImportCheckpoint); err != nil {
+				return report, err
+			}
+			if i.progressCb != nil {
+				i.progressCb(report.ImportCheckpoint, size)
+			}
+		}
+		if rerr != nil {
+			break
+		}
+	}
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	return report, nil
+}
+
+// writeBatch writes records and updates report. It returns error only if the batch was not written
+// for other reason than invalid data.
+func (i *Importer) writeBatch(ctx context.Context, batch []*importRecord, report *ImportReport) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	report.Batches++
+	// records in the order of written lines, line numbers of WriteError refer to them
+	written := batch
+	var verr *write.ValidationError
+	var err error
+	if w, ok := i.writeAPI.(*writeAPIBlocking); ok {
+		var lines []string
+		lines, written, verr = encodeRecords(w, batch)
+		if len(lines) > 0 {
+			err = w.write(ctx, strings.Join(lines, "\n"))
+		}
+	} else {
+		err = i.writeRecords(ctx, batch)
+		if errors.As(err, &verr) {
+			// invalid records were not written, the others were
+			written = validRecords(batch, verr)
+			err = nil
+		}
+	}
+	if verr != nil {
+		for _, d := range verr.Invalid {
+			if d.Index >= 0 && d.Index < len(batch) {
+				report.Invalid = appendImportedLine(report.Invalid, batch[d.Index].number, batch[d.Index].line, d.Err.Error())
+			}
+		}
+		report.LinesInvalid += int64(len(verr.Invalid))
+	}
+	if err == nil {
+		err = i.writeAPI.Flush(ctx)
+	}
+	if err == nil {
+		report.LinesCommitted += int64(len(written))
+		return nil
+	}
+	var werr *write.WriteError
+	if !errors.As(err, &werr) {
+		return err
+	}
+	if len(werr.Lines) == 0 || len(werr.Lines) >= len(written) {
+		// the whole batch was rejected
+		for _, rec := range written {
+			report.Rejected = appendImportedLine(report.Rejected, rec.number, rec.line, werr.Error())
+		}
+		report.LinesRejected += int64(len(written))
+		return nil
+	}
+	for _, rl := range werr.Lines {
+		if rl.Number > 0 && rl.Number <= len(written) {
+			report.Rejected = appendImportedLine(report.Rejected, written[rl.Number-1].number, rl.Line, rl.Reason)
+		}
+	}
+	report.LinesRejected += int64(len(werr.Lines))
+	report.LinesCommitted += int64(len(written) - len(werr.Lines))
+	return nil
+}
+
+// writeRecords writes records of batch by a write API of unknown implementation, which validates them itself
+func (i *Importer) writeRecords(ctx context.Context, batch []*importRecord) error {
+	if batch[0].point != nil {
+		points := make([]*write.Point, len(batch))
+		for n, rec := range batch {
+			points[n] = rec.point
+		}
+		return i.writeAPI.WritePoint(ctx, points...)
+	}
+	lines := make([]string, len(batch))
+	for n, rec := range batch {
+		lines[n] = rec.line
+	}
+	return i.writeAPI.WriteRecord(ctx, lines...)
+}
+
+// encodeRecords validates records of batch according to write options of w and encodes points to line protocol.
+// It returns lines to write, records of the lines and error describing invalid records, which are not returned.
+// Points dropped by point processors are not returned either.
+func encodeRecords(w *writeAPIBlocking, batch []*importRecord) ([]string, []*importRecord, *write.ValidationError) {
+	if batch[0].point == nil {
+		lines := make([]string, len(batch))
+		for n, rec := range batch {
+			lines[n] = rec.line
+		}
+		valid, err := w.service.ValidateRecords(lines...)
+		var verr *write.ValidationError
+		if !errors.As(err, &verr) {
+			return lines, batch, nil
+		}
+		return valid, validRecords(batch, verr), verr
+	}
+	lines := make([]string, 0, len(batch))
+	records := make([]*importRecord, 0, len(batch))
+	var invalid []write.InvalidData
+	for n, rec := range batch {
+		line, err := w.service.EncodePoints(w.service.ProcessPoints(rec.point)...)
+		var verr *write.ValidationError
+		if errors.As(err, &verr) && len(verr.Invalid) > 0 {
+			invalid = append(invalid, write.InvalidData{Index: n, Err: verr.Invalid[0].Err})
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+		records = append(records, rec)
+	}
+	if len(invalid) > 0 {
+		return lines, records, &write.ValidationError{Invalid: invalid}
+	}
+	return lines, records, nil
+}
+
+// validRecords returns records of batch not described by verr
+func validRecords(batch []*importRecord, verr *write.ValidationError) []*importRecord {
+	valid := make([]*importRecord, 0, len(batch))
+	next := 0
+	for n, rec := range batch {
+		if next < len(verr.Invalid) && verr.Invalid[next].Index == n {
+			next++
+			continue
+		}
+		valid = append(valid, rec)
+	}
+	return valid
+}
+
+// lpSource reads line protocol, one record per line
+type lpSource struct {
+	reader    *bufio.Reader
+	offset    int64
+	line      int64
+	precision time.Duration
+}
+
+func (s *lpSource) next() (*importRecord, *ImportedLine, error) {
+	text, err := s.reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	if text == "" {
+		return nil, nil, err
+	}
+	s.offset += int64(len(text))
+	s.line++
+	point, perr := write.ParseLine(text, s.precision)
+	if perr != nil {
+		var parseErr *write.ParseError
+		errors.As(perr, &parseErr)
+		return nil, &ImportedLine{Line: s.line, Text: parseErr.Text, Reason: fmt.Sprintf("column %d: %s", parseErr.Column, parseErr.Msg)}, err
+	}
+	if point == nil {
+		return nil, nil, err
+	}
+	return &importRecord{line: strings.TrimSpace(text), number: s.line}, nil, err
+}
+
+func (s *lpSource) position() (int64, int64) {
+	return s.offset, s.line
+}
+
+// csvSource reads points from annotated CSV
+type csvSource struct {
+	reader  *write.AnnotatedCSVReader
+	counter *countingReader
+	// resume is number of records to skip
+	resume int64
+}
+
+func (s *csvSource) next() (*importRecord, *ImportedLine, error) {
+	for {
+		point, err := s.reader.Next()
+		if int64(s.reader.Records()) <= s.resume && err == nil {
+			// written before the import was resumed
+			continue
+		}
+		var csvErr *write.CSVError
+		switch {
+		case errors.As(err, &csvErr):
+			if int64(s.reader.Records()) <= s.resume {
+				continue
+			}
+			return nil, &ImportedLine{Line: int64(csvErr.Line), Reason: csvErr.Msg}, nil
+		case err != nil:
+			return nil, nil, err
+		}
+		return &importRecord{point: point, number: int64(s.reader.Line())}, nil, nil
+	}
+}
+
+func (s *csvSource) position() (int64, int64) {
+	return s.counter.count, int64(s.reader.Records())
+}
+
+// countingReader counts bytes read from reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// appendImportedLine appends line to lines, unless there are already maxReportedLines
+func appendImportedLine(lines []ImportedLine, number int64, text, reason string) []ImportedLine {
+	if len(lines) >= maxReportedLines {
+		return lines
+	}
+	return append(lines, ImportedLine{Line: number, Text: text, Reason: reason})
+}
+
+// readCheckpoint reads checkpoint from path, it returns nil if the file doesn't exist
+func readCheckpoint(path string) (*ImportCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cp := &ImportCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint replaces checkpoint file at path, so that it is never left partially written
+func writeCheckpoint(path string, cp *ImportCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir syncs directory entries, errors are ignored as not all platforms support it
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
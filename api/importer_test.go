@@ -0,0 +1,195 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/influxdata/influxdb-client-go/v2/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFile(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetRequestHandler(func(_ string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		if strings.Contains(string(b), "m f=-1") {
+			// partial write
+			_ = service.DecodeLines(strings.NewReader(strings.Replace(string(b), "\nm f=-1", "", 1)))
+			return &http2.Error{StatusCode: 400, Code: "invalid", Message: "unable to parse 'm f=-1': value out of range"}
+		}
+		return service.DecodeLines(strings.NewReader(string(b)))
+	})
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions())
+	path := filepath.Join(t.TempDir(), "data.lp")
+	data := "# header\nm f=1\nm f=x\n\nm f=2\nm f=3\nm f=-1\nm f=4"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+
+	var progress []ImportCheckpoint
+	importer := NewImporter(writeAPI).SetBatchSize(2).SetProgressCallback(func(checkpoint ImportCheckpoint, size int64) {
+		assert.Equal(t, int64(len(data)), size)
+		progress = append(progress, checkpoint)
+	})
+	report, err := importer.ImportFile(context.Background(), path)
+	require.NoError(t, err)
+	assert.False(t, report.Resumed)
+	assert.Equal(t, ImportCheckpoint{Offset: int64(len(data)), Line: 8, LinesCommitted: 4, LinesSkipped: 1, LinesRejected: 1}, report.ImportCheckpoint)
+	assert.Equal(t, int64(3), report.Batches)
+	assert.Equal(t, []ImportedLine{{Line: 3, Text: "m f=x", Reason: "column 5: invalid field value"}}, report.Skipped)
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, int64(7), report.Rejected[0].Line)
+	assert.Equal(t, "m f=-1", report.Rejected[0].Text)
+	assert.Equal(t, []string{"m f=1", "m f=2", "m f=3", "m f=4"}, service.Lines())
+	require.Len(t, progress, 3)
+	assert.Equal(t, ImportCheckpoint{Offset: 28, Line: 5, LinesCommitted: 2, LinesSkipped: 1}, progress[0])
+	assert.Equal(t, report.ImportCheckpoint, progress[2])
+
+	_, err = os.Stat(path + CheckpointSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestImportValidation(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions().SetValidationLevel(write.ValidationStrict))
+	path := filepath.Join(t.TempDir(), "data.lp")
+	data := "m f=1\nm _f=2\nm f=3"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+
+	report, err := NewImporter(writeAPI).ImportFile(context.Background(), path)
+	require.NoError(t, err)
+	assert.Equal(t, ImportCheckpoint{Offset: int64(len(data)), Line: 3, LinesCommitted: 2, LinesInvalid: 1}, report.ImportCheckpoint)
+	require.Len(t, report.Invalid, 1)
+	assert.Equal(t, int64(2), report.Invalid[0].Line)
+	assert.Equal(t, "m _f=2", report.Invalid[0].Text)
+	assert.Len(t, report.Rejected, 0)
+	assert.Equal(t, []string{"m f=1", "m f=3"}, service.Lines())
+}
+
+func TestImportValidationAndRejected(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetRequestHandler(func(_ string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		if strings.Contains(string(b), "m f=-1") {
+			_ = service.DecodeLines(strings.NewReader(strings.Replace(string(b), "\nm f=-1", "", 1)))
+			return &http2.Error{StatusCode: 400, Code: "invalid", Message: "partial write: failed to parse line protocol:\nline 3: value out of range"}
+		}
+		return service.DecodeLines(strings.NewReader(string(b)))
+	})
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions().SetValidationLevel(write.ValidationStrict))
+	path := filepath.Join(t.TempDir(), "data.lp")
+	data := "m f=1\nm _f=2\nm f=3\nm f=-1\nm f=4"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+
+	report, err := NewImporter(writeAPI).ImportFile(context.Background(), path)
+	require.NoError(t, err)
+	assert.Equal(t, ImportCheckpoint{Offset: int64(len(data)), Line: 5, LinesCommitted: 3, LinesInvalid: 1, LinesRejected: 1}, report.ImportCheckpoint)
+	require.Len(t, report.Invalid, 1)
+	assert.Equal(t, int64(2), report.Invalid[0].Line)
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, ImportedLine{Line: 4, Text: "m f=-1", Reason: "value out of range"}, report.Rejected[0])
+	assert.Equal(t, []string{"m f=1", "m f=3", "m f=4"}, service.Lines())
+}
+
+func TestImportResume(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions())
+	path := filepath.Join(t.TempDir(), "data.lp")
+	records := test.GenRecords(10)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(records, "\n")), 0644))
+	importer := NewImporter(writeAPI).SetBatchSize(3)
+
+	// fail the third batch
+	service.SetRequestHandler(func(_ string, body io.Reader) error {
+		if service.Requests() == 3 {
+			return &http2.Error{StatusCode: 503, Code: "unavailable", Message: "service unavailable"}
+		}
+		return service.DecodeLines(body)
+	})
+	report, err := importer.ImportFile(context.Background(), path)
+	require.Error(t, err)
+	assert.Equal(t, int64(6), report.LinesCommitted)
+	assert.Len(t, service.Lines(), 6)
+
+	data, err := os.ReadFile(path + CheckpointSuffix)
+	require.NoError(t, err)
+	var cp ImportCheckpoint
+	require.NoError(t, json.Unmarshal(data, &cp))
+	assert.Equal(t, int64(6), cp.Line)
+	assert.Equal(t, int64(6), cp.LinesCommitted)
+
+	service.SetRequestHandler(nil)
+	report, err = importer.ImportFile(context.Background(), path)
+	require.NoError(t, err)
+	assert.True(t, report.Resumed)
+	assert.Equal(t, int64(2), report.Batches)
+	assert.Equal(t, int64(10), report.LinesCommitted)
+	require.Len(t, service.Lines(), 10)
+	assert.Equal(t, records, service.Lines())
+
+	_, err = os.Stat(path + CheckpointSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestImportInvalidCheckpoint(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions())
+	path := filepath.Join(t.TempDir(), "data.lp")
+	require.NoError(t, os.WriteFile(path, []byte("m f=1\n"), 0644))
+	require.NoError(t, os.WriteFile(path+CheckpointSuffix, []byte(`{"offset":100,"line":10}`), 0644))
+
+	_, err := NewImporter(writeAPI).ImportFile(context.Background(), path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "offset 100 is beyond end of data of size 6")
+	assert.Equal(t, 0, service.Requests())
+}
+
+func TestImportCSVResume(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions())
+	path := filepath.Join(t.TempDir(), "data.csv")
+	data := `#constant measurement,cpu
+#datatype tag,double,dateTime:number
+host,usage,time
+a,1,1
+b,x,2
+c,3,3
+d,4,4
+e,5,5
+`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	importer := NewImporter(writeAPI).SetBatchSize(2)
+
+	// fail the second batch
+	service.SetRequestHandler(func(_ string, body io.Reader) error {
+		if service.Requests() == 2 {
+			return &http2.Error{StatusCode: 503, Code: "unavailable", Message: "service unavailable"}
+		}
+		return service.DecodeLines(body)
+	})
+	report, err := importer.ImportCSVFile(context.Background(), path)
+	require.Error(t, err)
+	assert.Equal(t, int64(2), report.LinesCommitted)
+	assert.Equal(t, []ImportedLine{{Line: 5, Reason: `column usage: invalid double value "x": invalid syntax`}}, report.Skipped)
+	assert.Equal(t, []string{"cpu,host=a usage=1 1", "cpu,host=c usage=3 3"}, service.Lines())
+
+	service.SetRequestHandler(nil)
+	report, err = importer.ImportCSVFile(context.Background(), path)
+	require.NoError(t, err)
+	assert.True(t, report.Resumed)
+	assert.Len(t, report.Skipped, 0)
+	assert.Equal(t, int64(1), report.LinesSkipped)
+	assert.Equal(t, int64(4), report.LinesCommitted)
+	assert.Equal(t, int64(8), report.Line)
+	assert.Equal(t, []string{"cpu,host=a usage=1 1", "cpu,host=c usage=3 3", "cpu,host=d usage=4 4", "cpu,host=e usage=5 5"}, service.Lines())
+}
@@ -0,0 +1,540 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/internal/log"
+)
+
+// InfluxQLFormat is format of InfluxQL query response
+type InfluxQLFormat int
+
+const (
+	// InfluxQLFormatJSON requests response in JSON, it is the default
+	InfluxQLFormatJSON InfluxQLFormat = iota
+	// InfluxQLFormatCSV requests response in CSV
+	InfluxQLFormatCSV
+)
+
+// InfluxQLOptions holds parameters of an InfluxQL query
+type InfluxQLOptions struct {
+	// Database to query. InfluxDB 2 maps database and retention policy to a bucket by DBRP mappings.
+	Database string
+	// RetentionPolicy to query, optional
+	RetentionPolicy string
+	// Epoch is precision of returned timestamps, one of ns, u, ms, s, m or h.
+	// Server returns RFC3339 timestamps if it is empty. Timestamps are always decoded as time.Time.
+	Epoch string
+	// ChunkSize makes server return results in chunks of at most ChunkSize rows. Chunks are merged, 0 disables chunking.
+	ChunkSize int
+	// Params holds values of bound parameters, which are referenced by $name in the query
+	Params map[string]interface{}
+	// Format of the response, JSON by default
+	Format InfluxQLFormat
+}
+
+// InfluxQLResult holds results of all statements of an InfluxQL query
+type InfluxQLResult struct {
+	// Results of statements in the order of statements
+	Results []InfluxQLStatementResult
+}
+
+// InfluxQLStatementResult holds result of a single InfluxQL statement
+type InfluxQLStatementResult struct {
+	// StatementID is index of the statement in the query, starting from 0.
+	// CSV responses do not contain statements without data, statements are then numbered in the order of the response.
+	StatementID int
+	// Series returned by the statement
+	Series []InfluxQLSeries
+	// Messages are informational messages or warnings sent by the server
+	Messages []InfluxQLMessage
+	// Error describes why the statement failed, empty if it succeeded
+	Error string
+}
+
+// InfluxQLMessage is a message of the server about a statement
+type InfluxQLMessage struct {
+	// Level of the message, e.g. warning
+	Level string
+	// Text of the message
+	Text string
+}
+
+// InfluxQLSeries holds rows of a series
+type InfluxQLSeries struct {
+	// Name is name of the series, usually measurement
+	Name string
+	// Tags of the series, when grouping by tags
+	Tags map[string]string
+	// Columns holds names of columns
+	Columns []string
+	// Values holds rows, each row has a value for each column.
+	// Values are int64, float64, bool, string, time.Time for the time column, or nil.
+	// Numbers without a fraction are decoded as int64, because JSON responses of the server do not distinguish integers and floats.
+	Values [][]interface{}
+}
+
+// ColumnIndex returns index of column with the name, or -1 if there is no such column
+func (s *InfluxQLSeries) ColumnIndex(name string) int {
+	for i, c := range s.Columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// InfluxQLError describes a failed InfluxQL query or statement
+type InfluxQLError struct {
+	// StatementID is index of the failed statement, or -1 if the whole query failed
+	StatementID int
+	// Message is the error message of the server
+	Message string
+}
+
+// Error fulfils error interface
+func (e *InfluxQLError) Error() string {
+	if e.StatementID < 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("statement %d: %s", e.StatementID, e.Message)
+}
+
+// InfluxQLQueryAPI provides methods for querying by InfluxQL using the InfluxDB v1 compatibility endpoint.
+type InfluxQLQueryAPI interface {
+	// QueryRaw executes InfluxQL query and returns the response body as it was sent by the server, in the format set by options
+	QueryRaw(ctx context.Context, query string, options *InfluxQLOptions) (string, error)
+	// Query executes InfluxQL query and returns results of all statements.
+	// If the query or a statement fails, *InfluxQLError is returned for the first failure together with the results.
+	Query(ctx context.Context, query string, options *InfluxQLOptions) (*InfluxQLResult, error)
+}
+
+// influxQLQueryAPI implements InfluxQLQueryAPI interface
+type influxQLQueryAPI struct {
+	httpService http2.Service
+}
+
+// NewInfluxQLQueryAPI returns new InfluxQL query client
+func NewInfluxQLQueryAPI(service http2.Service) InfluxQLQueryAPI {
+	return &influxQLQueryAPI{httpService: service}
+}
+
+func (q *influxQLQueryAPI) QueryRaw(ctx context.Context, query string, options *InfluxQLOptions) (string, error) {
+	var body string
+	err := q.query(ctx, query, options, func(r io.Reader) error {
+		b, err := io.ReadAll(r)
+		body = string(b)
+		return err
+	})
+	return body, err
+}
+
+func (q *influxQLQueryAPI) Query(ctx context.Context, query string, options *InfluxQLOptions) (*InfluxQLResult, error) {
+	if options == nil {
+		options = &InfluxQLOptions{}
+	}
+	var result *InfluxQLResult
+	err := q.query(ctx, query, options, func(r io.Reader) error {
+		var err error
+		if options.Format == InfluxQLFormatCSV {
+			result, err = parseInfluxQLCSV(r, options.Epoch)
+		} else {
+			result, err = parseInfluxQLJSON(r, options.Epoch)
+		}
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	for _, r := range result.Results {
+		if r.Error != "" {
+			return result, &InfluxQLError{StatementID: r.StatementID, Message: r.Error}
+		}
+	}
+	return result, nil
+}
+
+// query sends query request and passes response body to read
+func (q *influxQLQueryAPI) query(ctx context.Context, query string, options *InfluxQLOptions, read func(r io.Reader) error) error {
+	if options == nil {
+		options = &InfluxQLOptions{}
+	}
+	queryURL, err := q.queryURL(options)
+	if err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("q", query)
+	if len(options.Params) > 0 {
+		params, err := json.Marshal(options.Params)
+		if err != nil {
+			return err
+		}
+		form.Set("params", string(params))
+	}
+	log.Debugf("InfluxQL query: %s", query)
+	accept := "application/json"
+	if options.Format == InfluxQLFormatCSV {
+		accept = "application/csv"
+	}
+	perror := q.httpService.DoPostRequest(ctx, queryURL, strings.NewReader(form.Encode()), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Accept-Encoding", "gzip")
+	},
+		func(resp *http.Response) error {
+			defer resp.Body.Close()
+			body := io.Reader(resp.Body)
+			if resp.Header.Get("Content-Encoding") == "gzip" {
+				gr, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					return err
+				}
+				body = gr
+			}
+			return read(body)
+		})
+	if perror != nil {
+		return perror
+	}
+	return nil
+}
+
+// queryURL returns URL of the v1 query endpoint with parameters from options
+func (q *influxQLQueryAPI) queryURL(options *InfluxQLOptions) (string, error) {
+	u, err := url.Parse(q.httpService.ServerURL())
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, "query")
+	params := u.Query()
+	if options.Database != "" {
+		params.Set("db", options.Database)
+	}
+	if options.RetentionPolicy != "" {
+		params.Set("rp", options.RetentionPolicy)
+	}
+	if options.Epoch != "" {
+		if _, err := epochUnit(options.Epoch); err != nil {
+			return "", err
+		}
+		params.Set("epoch", options.Epoch)
+	}
+	if options.ChunkSize > 0 {
+		params.Set("chunked", "true")
+		params.Set("chunk_size", strconv.Itoa(options.ChunkSize))
+	}
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
+// epochUnit returns duration of epoch unit
+func epochUnit(epoch string) (time.Duration, error) {
+	switch epoch {
+	case "", "ns", "n":
+		return time.Nanosecond, nil
+	case "u", "µ":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("invalid epoch '%s'", epoch)
+	}
+}
+
+// influxQLResponse is JSON response of the query endpoint
+type influxQLResponse struct {
+	Results []struct {
+		StatementID int `json:"statement_id"`
+		Series      []struct {
+			Name    string              `json:"name"`
+			Tags    map[string]string   `json:"tags"`
+			Columns []string            `json:"columns"`
+			Values  [][]json.RawMessage `json:"values"`
+			Partial bool                `json:"partial"`
+		} `json:"series"`
+		Messages []struct {
+			Level string `json:"level"`
+			Text  string `json:"text"`
+		} `json:"messages"`
+		Error   string `json:"error"`
+		Partial bool   `json:"partial"`
+	} `json:"results"`
+	Error string `json:"error"`
+}
+
+// parseInfluxQLJSON parses JSON response, which can consist of more chunks
+func parseInfluxQLJSON(r io.Reader, epoch string) (*InfluxQLResult, error) {
+	unit, err := epochUnit(epoch)
+	if err != nil {
+		return nil, err
+	}
+	result := &InfluxQLResult{}
+	merger := &influxQLMerger{result: result}
+	decoder := json.NewDecoder(r)
+	for {
+		var resp influxQLResponse
+		if err := decoder.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				return result, nil
+			}
+			return result, fmt.Errorf("cannot decode InfluxQL response: %w", err)
+		}
+		if resp.Error != "" {
+			return result, &InfluxQLError{StatementID: -1, Message: resp.Error}
+		}
+		for _, res := range resp.Results {
+			stmt := merger.statement(res.StatementID)
+			for _, m := range res.Messages {
+				stmt.Messages = append(stmt.Messages, InfluxQLMessage{Level: m.Level, Text: m.Text})
+			}
+			if res.Error != "" {
+				stmt.Error = res.Error
+			}
+			for _, s := range res.Series {
+				rows := make([][]interface{}, len(s.Values))
+				for i, raw := range s.Values {
+					row := make([]interface{}, len(raw))
+					for j, v := range raw {
+						column := ""
+						if j < len(s.Columns) {
+							column = s.Columns[j]
+						}
+						if row[j], err = influxQLJSONValue(v, column, unit); err != nil {
+							return result, fmt.Errorf("statement %d, series %s, column %s: %w", res.StatementID, s.Name, column, err)
+						}
+					}
+					rows[i] = row
+				}
+				merger.add(stmt, s.Name, s.Tags, s.Columns, rows)
+			}
+		}
+	}
+}
+
+// influxQLJSONValue decodes value of a column
+func influxQLJSONValue(raw json.RawMessage, column string, unit time.Duration) (interface{}, error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch val := v.(type) {
+	case json.Number:
+		if column == "time" {
+			n, err := val.Int64()
+			if err != nil {
+				return nil, err
+			}
+			return epochTime(n, unit), nil
+		}
+		if n, err := val.Int64(); err == nil {
+			return n, nil
+		}
+		return val.Float64()
+	case string:
+		if column == "time" {
+			return time.Parse(time.RFC3339Nano, val)
+		}
+		return val, nil
+	case bool, nil:
+		return val, nil
+	default:
+		// arrays or objects are kept as JSON text
+		return string(raw), nil
+	}
+}
+
+// epochTime returns time n units since Unix epoch
+func epochTime(n int64, unit time.Duration) time.Time {
+	if unit >= time.Second {
+		return time.Unix(n*int64(unit/time.Second), 0)
+	}
+	return time.Unix(0, n*int64(unit))
+}
+
+// parseInfluxQLCSV parses CSV response. Results of statements are separated by empty lines,
+// each series block starts with a header row of name, tags and columns.
+func parseInfluxQLCSV(r io.Reader, epoch string) (*InfluxQLResult, error) {
+	unit, err := epochUnit(epoch)
+	if err != nil {
+		return nil, err
+	}
+	result := &InfluxQLResult{}
+	merger := &influxQLMerger{result: result}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	statementID := -1
+	var stmt *InfluxQLStatementResult
+	var header []string
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			// next statement
+			stmt = nil
+			header = nil
+			continue
+		}
+		row, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			return result, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if stmt == nil {
+			statementID++
+			stmt = merger.statement(statementID)
+		}
+		switch {
+		case len(row) == 1 && row[0] == "error" && header == nil:
+			header = row
+			continue
+		case header != nil && len(header) == 1 && header[0] == "error":
+			stmt.Error = row[0]
+			continue
+		case len(row) >= 2 && row[0] == "name" && row[1] == "tags":
+			header = row
+			continue
+		case header == nil:
+			return result, fmt.Errorf("line %d: missing header", lineNum)
+		}
+		if len(row) != len(header) {
+			return result, fmt.Errorf("line %d: expected %d columns, found %d", lineNum, len(header), len(row))
+		}
+		values := make([]interface{}, len(row)-2)
+		for i, s := range row[2:] {
+			if values[i], err = influxQLCSVValue(s, header[i+2], unit); err != nil {
+				return result, fmt.Errorf("line %d, column %s: %w", lineNum, header[i+2], err)
+			}
+		}
+		merger.add(stmt, row[0], parseInfluxQLTags(row[1]), header[2:], [][]interface{}{values})
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// influxQLCSVValue converts CSV value of a column
+func influxQLCSVValue(s, column string, unit time.Duration) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if column == "time" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+		return epochTime(n, unit), nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return b, nil
+	}
+	return s, nil
+}
+
+// parseInfluxQLTags parses tags of CSV row, formatted as key=value pairs separated by commas
+func parseInfluxQLTags(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if i := strings.IndexByte(pair, '='); i > 0 {
+			tags[pair[:i]] = pair[i+1:]
+		}
+	}
+	return tags
+}
+
+// influxQLMerger merges chunks and rows into series of statement results
+type influxQLMerger struct {
+	result *InfluxQLResult
+}
+
+// statement returns result of statement with id, it is created if it does not exist
+func (m *influxQLMerger) statement(id int) *InfluxQLStatementResult {
+	for i := range m.result.Results {
+		if m.result.Results[i].StatementID == id {
+			return &m.result.Results[i]
+		}
+	}
+	m.result.Results = append(m.result.Results, InfluxQLStatementResult{StatementID: id})
+	return &m.result.Results[len(m.result.Results)-1]
+}
+
+// add appends rows to the last series of statement if it is the same series, or adds a new series
+func (m *influxQLMerger) add(stmt *InfluxQLStatementResult, name string, tags map[string]string, columns []string, rows [][]interface{}) {
+	if n := len(stmt.Series); n > 0 {
+		last := &stmt.Series[n-1]
+		if last.Name == name && equalStrings(last.Columns, columns) && equalTags(last.Tags, tags) {
+			last.Values = append(last.Values, rows...)
+			return
+		}
+	}
+	stmt.Series = append(stmt.Series, InfluxQLSeries{Name: name, Tags: tags, Columns: columns, Values: rows})
+}
+
+// equalStrings returns true if a and b hold the same strings
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalTags returns true if a and b hold the same tags
+func equalTags(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := b[k]; !ok || v != a[k] {
+			return false
+		}
+	}
+	return true
+}
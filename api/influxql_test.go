@@ -0,0 +1,185 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/internal/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// influxQLServer returns server replying with body and recording the last request
+func influxQLServer(t *testing.T, contentType, body string, compress bool, lastRequest **http.Request, lastForm *url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		*lastRequest = r
+		*lastForm = r.PostForm
+		data := []byte(body)
+		if compress {
+			gz, err := gzip.CompressWithGzip(strings.NewReader(body))
+			require.NoError(t, err)
+			data, err = io.ReadAll(gz)
+			require.NoError(t, err)
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	}))
+}
+
+func TestInfluxQLQueryJSON(t *testing.T) {
+	body := `{"results":[{"statement_id":0,"series":[{"name":"cpu","tags":{"host":"a"},"columns":["time","usage","count","ok","note"],"values":[["2022-01-01T10:00:00Z",1.5,2,true,"x"],["2022-01-01T10:00:10Z",2,3,false,null]]}]},{"statement_id":1,"messages":[{"level":"warning","text":"deprecated"}]}]}`
+	var req *http.Request
+	var form url.Values
+	server := influxQLServer(t, "application/json", body, true, &req, &form)
+	defer server.Close()
+	api := NewInfluxQLQueryAPI(http2.NewService(server.URL+"/", "a", http2.DefaultOptions()))
+
+	res, err := api.Query(context.Background(), "SELECT * FROM cpu WHERE host = $host", &InfluxQLOptions{
+		Database:        "db",
+		RetentionPolicy: "autogen",
+		Params:          map[string]interface{}{"host": "a"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, req)
+	assert.Equal(t, "db", req.URL.Query().Get("db"))
+	assert.Equal(t, "autogen", req.URL.Query().Get("rp"))
+	assert.Equal(t, "", req.URL.Query().Get("chunked"))
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+	assert.Equal(t, "SELECT * FROM cpu WHERE host = $host", form.Get("q"))
+	assert.Equal(t, `{"host":"a"}`, form.Get("params"))
+
+	require.Len(t, res.Results, 2)
+	require.Len(t, res.Results[0].Series, 1)
+	s := res.Results[0].Series[0]
+	assert.Equal(t, "cpu", s.Name)
+	assert.Equal(t, map[string]string{"host": "a"}, s.Tags)
+	assert.Equal(t, []string{"time", "usage", "count", "ok", "note"}, s.Columns)
+	assert.Equal(t, 1, s.ColumnIndex("usage"))
+	assert.Equal(t, -1, s.ColumnIndex("none"))
+	require.Len(t, s.Values, 2)
+	assert.Equal(t, []interface{}{time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC), 1.5, int64(2), true, "x"}, s.Values[0])
+	assert.Equal(t, []interface{}{time.Date(2022, 1, 1, 10, 0, 10, 0, time.UTC), int64(2), int64(3), false, nil}, s.Values[1])
+	assert.Equal(t, 1, res.Results[1].StatementID)
+	assert.Equal(t, []InfluxQLMessage{{Level: "warning", Text: "deprecated"}}, res.Results[1].Messages)
+}
+
+func TestInfluxQLQueryChunked(t *testing.T) {
+	body := `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[1640995200,1],[1640995210,2]],"partial":true}],"partial":true}]}
+{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[1640995220,3]]},{"name":"mem","columns":["time","v"],"values":[[1640995200,4]]}]}]}
+`
+	var req *http.Request
+	var form url.Values
+	server := influxQLServer(t, "application/json", body, false, &req, &form)
+	defer server.Close()
+	api := NewInfluxQLQueryAPI(http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	res, err := api.Query(context.Background(), "SELECT v FROM cpu; SELECT v FROM mem", &InfluxQLOptions{Database: "db", Epoch: "s", ChunkSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "true", req.URL.Query().Get("chunked"))
+	assert.Equal(t, "2", req.URL.Query().Get("chunk_size"))
+	assert.Equal(t, "s", req.URL.Query().Get("epoch"))
+	require.Len(t, res.Results, 1)
+	require.Len(t, res.Results[0].Series, 2)
+	cpu := res.Results[0].Series[0]
+	require.Len(t, cpu.Values, 3)
+	assert.True(t, time.Unix(1640995220, 0).Equal(cpu.Values[2][0].(time.Time)))
+	assert.Equal(t, int64(3), cpu.Values[2][1])
+	assert.Equal(t, "mem", res.Results[0].Series[1].Name)
+}
+
+func TestInfluxQLQueryErrors(t *testing.T) {
+	body := `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[["2022-01-01T10:00:00Z",1]]}]},{"statement_id":1,"error":"measurement not found"}]}`
+	var req *http.Request
+	var form url.Values
+	server := influxQLServer(t, "application/json", body, false, &req, &form)
+	defer server.Close()
+	api := NewInfluxQLQueryAPI(http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	res, err := api.Query(context.Background(), "SELECT v FROM cpu; SELECT v FROM none", &InfluxQLOptions{Database: "db"})
+	require.Error(t, err)
+	var qerr *InfluxQLError
+	require.True(t, errors.As(err, &qerr))
+	assert.Equal(t, 1, qerr.StatementID)
+	assert.Equal(t, "statement 1: measurement not found", err.Error())
+	require.NotNil(t, res)
+	require.Len(t, res.Results, 2)
+	assert.Len(t, res.Results[0].Series, 1)
+
+	_, err = api.Query(context.Background(), "SELECT", &InfluxQLOptions{Epoch: "x"})
+	assert.EqualError(t, err, "invalid epoch 'x'")
+
+	server2 := influxQLServer(t, "application/json", `{"error":"database not found: db"}`, false, &req, &form)
+	defer server2.Close()
+	api = NewInfluxQLQueryAPI(http2.NewService(server2.URL, "a", http2.DefaultOptions()))
+	_, err = api.Query(context.Background(), "SELECT v FROM cpu", &InfluxQLOptions{Database: "db"})
+	require.True(t, errors.As(err, &qerr))
+	assert.Equal(t, -1, qerr.StatementID)
+	assert.Equal(t, "database not found: db", err.Error())
+}
+
+func TestInfluxQLQueryHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Error", "error parsing query: found EOF")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"error parsing query: found EOF"}`))
+	}))
+	defer server.Close()
+	api := NewInfluxQLQueryAPI(http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	res, err := api.Query(context.Background(), "SELECT", nil)
+	require.Error(t, err)
+	assert.Nil(t, res)
+	assert.Contains(t, err.Error(), "error parsing query: found EOF")
+}
+
+func TestInfluxQLQueryCSV(t *testing.T) {
+	body := "name,tags,time,usage,note\r\ncpu,host=a,1640995200000000000,1.5,x\r\ncpu,host=a,1640995210000000000,2,\r\ncpu,host=b,1640995200000000000,3,\"a,b\"\r\n\r\nerror\r\nmeasurement not found\r\n"
+	var req *http.Request
+	var form url.Values
+	server := influxQLServer(t, "application/csv", body, false, &req, &form)
+	defer server.Close()
+	api := NewInfluxQLQueryAPI(http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	raw, err := api.QueryRaw(context.Background(), "SELECT * FROM cpu GROUP BY host; SELECT * FROM none", &InfluxQLOptions{Database: "db", Format: InfluxQLFormatCSV})
+	require.NoError(t, err)
+	assert.Equal(t, body, raw)
+	assert.Equal(t, "application/csv", req.Header.Get("Accept"))
+
+	res, err := api.Query(context.Background(), "SELECT * FROM cpu GROUP BY host; SELECT * FROM none", &InfluxQLOptions{Database: "db", Format: InfluxQLFormatCSV})
+	assert.EqualError(t, err, "statement 1: measurement not found")
+	require.NotNil(t, res)
+	require.Len(t, res.Results, 2)
+	require.Len(t, res.Results[0].Series, 2)
+	a := res.Results[0].Series[0]
+	assert.Equal(t, "cpu", a.Name)
+	assert.Equal(t, map[string]string{"host": "a"}, a.Tags)
+	assert.Equal(t, []string{"time", "usage", "note"}, a.Columns)
+	require.Len(t, a.Values, 2)
+	assert.True(t, time.Unix(1640995200, 0).Equal(a.Values[0][0].(time.Time)))
+	assert.Equal(t, []interface{}{1.5, "x"}, a.Values[0][1:])
+	assert.Equal(t, []interface{}{int64(2), nil}, a.Values[1][1:])
+	b := res.Results[0].Series[1]
+	assert.Equal(t, map[string]string{"host": "b"}, b.Tags)
+	assert.Equal(t, []interface{}{int64(3), "a,b"}, b.Values[0][1:])
+	assert.Equal(t, "measurement not found", res.Results[1].Error)
+}
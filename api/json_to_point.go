@@ -0,0 +1,505 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// JSONMapping declaratively maps JSON documents to points.
+// Values are selected by paths, e.g. $.sensor.id, $.values[0] or $['dotted.key'], the leading $. is optional.
+// Valid point must contain measurement and at least one field, the same as for DataToPoint.
+//
+//	mapping := &api.JSONMapping{
+//		Measurement: "air",
+//		Tags:        []api.JSONTag{{Name: "sensor", Path: "$.device.id"}},
+//		Fields:      []api.JSONField{{Name: "temperature", Path: "$.temp", Type: "float"}},
+//		TimePath:    "$.ts",
+//		TimeFormat:  "unix_ms",
+//	}
+type JSONMapping struct {
+	// Measurement is the measurement name, used when MeasurementPath is empty
+	Measurement string
+	// MeasurementPath is path of the measurement name
+	MeasurementPath string
+	// Tags map values to tags
+	Tags []JSONTag
+	// Fields map values to fields
+	Fields []JSONField
+	// TimePath is path of the timestamp, point without timestamp gets time of the server when written
+	TimePath string
+	// TimeFormat is format of the timestamp: RFC3339 (default, including fractional seconds), unix, unix_ms, unix_us, unix_ns
+	// for numbers of seconds, milliseconds, microseconds or nanoseconds, or a Go time layout, e.g. 2006-01-02 15:04:05
+	TimeFormat string
+}
+
+// JSONTag maps a value to a tag. Numbers and booleans are converted to strings.
+type JSONTag struct {
+	// Name of the tag
+	Name string
+	// Path of the value
+	Path string
+	// Optional tag is omitted when the value is missing or null, otherwise it is an error
+	Optional bool
+}
+
+// JSONField maps a value to a field
+type JSONField struct {
+	// Name of the field
+	Name string
+	// Path of the value
+	Path string
+	// Type of the field: float, int, uint, bool or string. Numeric and boolean strings are converted to the type.
+	// When empty, the type is given by JSON: numbers are floats, strings and booleans are kept.
+	Type string
+	// Optional field is omitted when the value is missing or null, otherwise it is an error
+	Optional bool
+}
+
+// JSONPathError describes a value of JSON document, which cannot be mapped
+type JSONPathError struct {
+	// Path of the value
+	Path string
+	// Msg describes the error
+	Msg string
+}
+
+// Error fulfils error interface
+func (e *JSONPathError) Error() string {
+	return fmt.Sprintf("path %s: %s", e.Path, e.Msg)
+}
+
+// pathStep is a step of a parsed path, key of an object or index of an array
+type pathStep struct {
+	key   string
+	index int
+}
+
+// jsonValueMapping is a compiled mapping of a value
+type jsonValueMapping struct {
+	name     string
+	path     string
+	steps    []pathStep
+	typ      string
+	optional bool
+}
+
+// compiledJSONMapping holds mapping with parsed paths
+type compiledJSONMapping struct {
+	mapping     *JSONMapping
+	measurement *jsonValueMapping
+	tags        []*jsonValueMapping
+	fields      []*jsonValueMapping
+	time        *jsonValueMapping
+}
+
+// Validate checks that mapping creates valid points and that all paths are valid
+func (m *JSONMapping) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+// compile validates mapping and parses its paths
+func (m *JSONMapping) compile() (*compiledJSONMapping, error) {
+	c := &compiledJSONMapping{mapping: m}
+	var err error
+	if m.MeasurementPath != "" {
+		if c.measurement, err = newJSONValueMapping("measurement", m.MeasurementPath, "string", false); err != nil {
+			return nil, err
+		}
+	} else if m.Measurement == "" {
+		return nil, fmt.Errorf("no measurement")
+	}
+	for _, t := range m.Tags {
+		if t.Name == "" {
+			return nil, fmt.Errorf("cannot use path '%s': invalid lp tag name \"\"", t.Path)
+		}
+		vm, err := newJSONValueMapping(t.Name, t.Path, "string", t.Optional)
+		if err != nil {
+			return nil, err
+		}
+		c.tags = append(c.tags, vm)
+	}
+	if len(m.Fields) == 0 {
+		return nil, fmt.Errorf("no field")
+	}
+	for _, f := range m.Fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("cannot use path '%s': invalid lp field name \"\"", f.Path)
+		}
+		switch f.Type {
+		case "", "float", "int", "uint", "bool", "string":
+		default:
+			return nil, fmt.Errorf("cannot use path '%s': invalid field type '%s'", f.Path, f.Type)
+		}
+		vm, err := newJSONValueMapping(f.Name, f.Path, f.Type, f.Optional)
+		if err != nil {
+			return nil, err
+		}
+		c.fields = append(c.fields, vm)
+	}
+	if m.TimePath != "" {
+		if c.time, err = newJSONValueMapping("time", m.TimePath, m.TimeFormat, false); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// newJSONValueMapping creates mapping of a value at path
+func newJSONValueMapping(name, path, typ string, optional bool) (*jsonValueMapping, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, &JSONPathError{Path: path, Msg: err.Error()}
+	}
+	return &jsonValueMapping{name: name, path: path, steps: steps, typ: typ, optional: optional}, nil
+}
+
+// parseJSONPath parses path into steps
+func parseJSONPath(path string) ([]pathStep, error) {
+	p := strings.TrimPrefix(path, "$")
+	if p == "" {
+		return nil, errors.New("empty path")
+	}
+	var steps []pathStep
+	for len(p) > 0 {
+		switch {
+		case p[0] == '.':
+			p = p[1:]
+			fallthrough
+		case len(steps) == 0 && p[0] != '[':
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, errors.New("empty key")
+			}
+			steps = append(steps, pathStep{key: p[:end], index: -1})
+			p = p[end:]
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, errors.New("missing ]")
+			}
+			inner := p[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, pathStep{key: inner[1 : len(inner)-1], index: -1})
+			} else {
+				i, err := strconv.Atoi(inner)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("invalid index %s", inner)
+				}
+				steps = append(steps, pathStep{index: i})
+			}
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", p[0])
+		}
+	}
+	return steps, nil
+}
+
+// lookup returns value at the path, or nil if it is missing
+func (vm *jsonValueMapping) lookup(doc interface{}) interface{} {
+	v := doc
+	for _, s := range vm.steps {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			if s.index >= 0 {
+				return nil
+			}
+			v = x[s.key]
+		case []interface{}:
+			if s.index < 0 || s.index >= len(x) {
+				return nil
+			}
+			v = x[s.index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// value returns value at the path converted to the type of the mapping. It returns nil for a missing optional value.
+func (vm *jsonValueMapping) value(doc interface{}) (interface{}, error) {
+	v := vm.lookup(doc)
+	if v == nil {
+		if vm.optional {
+			return nil, nil
+		}
+		return nil, &JSONPathError{Path: vm.path, Msg: "value not found"}
+	}
+	res, err := convertJSONValue(v, vm.typ)
+	if err != nil {
+		return nil, &JSONPathError{Path: vm.path, Msg: err.Error()}
+	}
+	if !validFieldType(reflect.TypeOf(res)) {
+		return nil, &JSONPathError{Path: vm.path, Msg: fmt.Sprintf("cannot use value of type '%T'", res)}
+	}
+	return res, nil
+}
+
+// convertJSONValue converts decoded JSON value to type
+func convertJSONValue(v interface{}, typ string) (interface{}, error) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = x.String()
+		if typ == "" {
+			typ = "float"
+		}
+	case string:
+		s = x
+		if typ == "" {
+			return x, nil
+		}
+	case bool:
+		s = strconv.FormatBool(x)
+		if typ == "" {
+			return x, nil
+		}
+	default:
+		return nil, fmt.Errorf("cannot use %s as a value", jsonTypeName(v))
+	}
+	var res interface{}
+	var err error
+	switch typ {
+	case "string":
+		return s, nil
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		if err == nil && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			err = strconv.ErrSyntax
+		}
+		res = f
+	case "int":
+		res, err = strconv.ParseInt(s, 10, 64)
+	case "uint":
+		res, err = strconv.ParseUint(s, 10, 64)
+	case "bool":
+		res, err = strconv.ParseBool(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %q to %s", s, typ)
+	}
+	return res, nil
+}
+
+// jsonTypeName returns name of JSON type of decoded value
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// timestamp returns time at the path, or zero time if it is not mapped
+func (c *compiledJSONMapping) timestamp(doc interface{}) (time.Time, error) {
+	if c.time == nil {
+		return time.Time{}, nil
+	}
+	v := c.time.lookup(doc)
+	if v == nil {
+		return time.Time{}, &JSONPathError{Path: c.time.path, Msg: "value not found"}
+	}
+	t, err := parseJSONTime(v, c.time.typ)
+	if err != nil {
+		return time.Time{}, &JSONPathError{Path: c.time.path, Msg: err.Error()}
+	}
+	return t, nil
+}
+
+// parseJSONTime parses timestamp in format
+func parseJSONTime(v interface{}, format string) (time.Time, error) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = x.String()
+	case string:
+		s = x
+	default:
+		return time.Time{}, fmt.Errorf("cannot use %s as a timestamp", jsonTypeName(v))
+	}
+	var unit time.Duration
+	switch format {
+	case "", "RFC3339", "RFC3339Nano":
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q as RFC3339 time", s)
+		}
+		return t, nil
+	case "unix":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	default:
+		t, err := time.Parse(format, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q as time in format %s", s, format)
+		}
+		return t, nil
+	}
+	// integer and fractional parts are parsed separately to keep precision
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	n, err := strconv.ParseInt(intPart, 10, 64)
+	var frac float64
+	if err == nil && fracPart != "" {
+		frac, err = strconv.ParseFloat("0."+fracPart, 64)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q as %s time", s, format)
+	}
+	d := time.Duration(math.Round(frac * float64(unit)))
+	if strings.HasPrefix(intPart, "-") {
+		d = -d
+	}
+	if unit == time.Second {
+		return time.Unix(n, 0).Add(d), nil
+	}
+	// nanoseconds must fit in int64
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return time.Time{}, fmt.Errorf("%q is out of range of %s time", s, format)
+	}
+	return time.Unix(0, n*int64(unit)).Add(d), nil
+}
+
+// point creates point from a decoded document
+func (c *compiledJSONMapping) point(doc interface{}) (*write.Point, error) {
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("cannot use %s as point", jsonTypeName(doc))
+	}
+	measurement := c.mapping.Measurement
+	if c.measurement != nil {
+		v, err := c.measurement.value(doc)
+		if err != nil {
+			return nil, err
+		}
+		measurement = v.(string)
+		if measurement == "" {
+			return nil, &JSONPathError{Path: c.measurement.path, Msg: "empty measurement"}
+		}
+	}
+	point := write.NewPointWithMeasurement(measurement)
+	for _, t := range c.tags {
+		v, err := t.value(doc)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			point.AddTag(t.name, v.(string))
+		}
+	}
+	for _, f := range c.fields {
+		v, err := f.value(doc)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			point.AddField(f.name, v)
+		}
+	}
+	if len(point.FieldList()) == 0 {
+		return nil, fmt.Errorf("no field")
+	}
+	t, err := c.timestamp(doc)
+	if err != nil {
+		return nil, err
+	}
+	return point.SetTime(t), nil
+}
+
+// JSONToPoint converts JSON object into a Point according to mapping.
+// Values which cannot be mapped are reported as *JSONPathError.
+func JSONToPoint(data []byte, mapping *JSONMapping) (*write.Point, error) {
+	c, err := mapping.compile()
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return c.point(doc)
+}
+
+// JSONPointReader reads points from a stream of JSON documents according to mapping,
+// e.g. newline delimited JSON (NDJSON). A document, which is an array, is read as a sequence of its items.
+type JSONPointReader struct {
+	decoder   *json.Decoder
+	mapping   *JSONMapping
+	compiled  *compiledJSONMapping
+	pending   []interface{}
+	documents int
+	err       error
+}
+
+// NewJSONPointReader creates reader of points from JSON documents read from r
+func NewJSONPointReader(r io.Reader, mapping *JSONMapping) *JSONPointReader {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return &JSONPointReader{decoder: decoder, mapping: mapping}
+}
+
+// Documents returns number of JSON objects read so far
+func (r *JSONPointReader) Documents() int {
+	return r.documents
+}
+
+// Next returns the next point. It returns io.EOF when there are no more documents.
+// Document which cannot be mapped is reported as an error, e.g. *JSONPathError, reading can continue by calling Next again.
+// Invalid mapping, invalid JSON and errors of the reader stop reading.
+func (r *JSONPointReader) Next() (*write.Point, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.compiled == nil {
+		if r.compiled, r.err = r.mapping.compile(); r.err != nil {
+			return nil, r.err
+		}
+	}
+	for len(r.pending) == 0 {
+		var doc interface{}
+		if err := r.decoder.Decode(&doc); err != nil {
+			r.err = err
+			return nil, err
+		}
+		if items, ok := doc.([]interface{}); ok {
+			r.pending = items
+		} else {
+			r.pending = append(r.pending, doc)
+		}
+	}
+	doc := r.pending[0]
+	r.pending = r.pending[1:]
+	r.documents++
+	return r.compiled.point(doc)
+}
@@ -0,0 +1,214 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fieldMap(p *write.Point) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, f := range p.FieldList() {
+		fields[f.Key] = f.Value
+	}
+	return fields
+}
+
+func TestJSONToPoint(t *testing.T) {
+	mapping := &JSONMapping{
+		MeasurementPath: "$.type",
+		Tags: []JSONTag{
+			{Name: "sensor", Path: "$.device.id"},
+			{Name: "room", Path: "$['device.room']", Optional: true},
+			{Name: "floor", Path: "device.floor", Optional: true},
+		},
+		Fields: []JSONField{
+			{Name: "temperature", Path: "$.values[0]"},
+			{Name: "humidity", Path: "$.values[1]", Type: "int"},
+			{Name: "counter", Path: "$.counter", Type: "uint"},
+			{Name: "ok", Path: "$.ok"},
+			{Name: "state", Path: "$.state", Type: "bool"},
+			{Name: "note", Path: "$.note", Optional: true},
+			{Name: "big", Path: "$.big", Type: "int"},
+		},
+		TimePath:   "$.ts",
+		TimeFormat: "unix_ms",
+	}
+	require.NoError(t, mapping.Validate())
+	p, err := JSONToPoint([]byte(`{"type":"air","device":{"id":10,"floor":null},"device.room":"kitchen",
+		"values":[23.5,"55"],"counter":3,"ok":true,"state":"false","big":9007199254740993,"ts":1600000000123}`), mapping)
+	require.NoError(t, err)
+	assert.Equal(t, "air", p.Name())
+	require.Len(t, p.TagList(), 2)
+	assert.Equal(t, "sensor", p.TagList()[0].Key)
+	assert.Equal(t, "10", p.TagList()[0].Value)
+	assert.Equal(t, "kitchen", p.TagList()[1].Value)
+	assert.Equal(t, map[string]interface{}{
+		"temperature": 23.5,
+		"humidity":    int64(55),
+		"counter":     uint64(3),
+		"ok":          true,
+		"state":       false,
+		"big":         int64(9007199254740993),
+	}, fieldMap(p))
+	assert.Equal(t, time.Unix(1600000000, 123000000), p.Time())
+}
+
+func TestJSONToPointTimeFormats(t *testing.T) {
+	testCases := []struct {
+		format string
+		value  string
+		time   time.Time
+	}{
+		{"", `"2020-09-13T12:26:40.5Z"`, time.Unix(1600000000, 500000000).UTC()},
+		{"RFC3339", `"2020-09-13T14:26:40+02:00"`, time.Unix(1600000000, 0)},
+		{"unix", `1600000000.25`, time.Unix(1600000000, 250000000)},
+		{"unix", `"1600000000"`, time.Unix(1600000000, 0)},
+		{"unix", `10000000000.5`, time.Unix(10000000000, 500000000)},
+		{"unix_ms", `9223372036854`, time.Unix(9223372036, 854000000)},
+		{"unix_ms", `-9223372036854`, time.Unix(-9223372037, 146000000)},
+		{"unix_us", `1600000000000001`, time.Unix(1600000000, 1000)},
+		{"unix_ns", `1600000000000000001`, time.Unix(1600000000, 1)},
+		{"2006-01-02 15:04:05", `"2020-09-13 12:26:40"`, time.Unix(1600000000, 0).UTC()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.format+tc.value, func(t *testing.T) {
+			p, err := JSONToPoint([]byte(`{"v":1,"t":`+tc.value+`}`), &JSONMapping{
+				Measurement: "m",
+				Fields:      []JSONField{{Name: "v", Path: "v"}},
+				TimePath:    "t",
+				TimeFormat:  tc.format,
+			})
+			require.NoError(t, err)
+			assert.True(t, tc.time.Equal(p.Time()), p.Time())
+		})
+	}
+}
+
+func TestJSONToPointErrors(t *testing.T) {
+	mapping := &JSONMapping{
+		Measurement: "m",
+		Tags:        []JSONTag{{Name: "id", Path: "$.id"}},
+		Fields:      []JSONField{{Name: "v", Path: "$.v", Type: "int"}},
+		TimePath:    "$.t",
+	}
+	testCases := []struct {
+		json string
+		err  string
+		path string
+	}{
+		{`{"v":1,"t":"2020-09-13T12:26:40Z"}`, "path $.id: value not found", "$.id"},
+		{`{"id":{"a":1},"v":1,"t":"2020-09-13T12:26:40Z"}`, "path $.id: cannot use object as a value", "$.id"},
+		{`{"id":1,"v":1.5,"t":"2020-09-13T12:26:40Z"}`, `path $.v: cannot convert "1.5" to int`, "$.v"},
+		{`{"id":1,"v":null,"t":"2020-09-13T12:26:40Z"}`, "path $.v: value not found", "$.v"},
+		{`{"id":1,"v":1,"t":"yesterday"}`, `path $.t: cannot parse "yesterday" as RFC3339 time`, "$.t"},
+		{`{"id":1,"v":1,"t":[1]}`, `path $.t: cannot use array as a timestamp`, "$.t"},
+		{`[1]`, "cannot use array as point", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.json, func(t *testing.T) {
+			_, err := JSONToPoint([]byte(tc.json), mapping)
+			require.Error(t, err)
+			assert.Equal(t, tc.err, err.Error())
+			var perr *JSONPathError
+			if tc.path != "" {
+				require.True(t, errors.As(err, &perr))
+				assert.Equal(t, tc.path, perr.Path)
+			} else {
+				assert.False(t, errors.As(err, &perr))
+			}
+		})
+	}
+}
+
+func TestJSONToPointTimeOutOfRange(t *testing.T) {
+	testCases := []struct {
+		format string
+		value  string
+	}{
+		{"unix_ms", `9223372036855`},
+		{"unix_ms", `-9223372036855`},
+		{"unix_us", `9223372036854776`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.format+tc.value, func(t *testing.T) {
+			_, err := JSONToPoint([]byte(`{"v":1,"t":`+tc.value+`}`), &JSONMapping{
+				Measurement: "m",
+				Fields:      []JSONField{{Name: "v", Path: "v"}},
+				TimePath:    "t",
+				TimeFormat:  tc.format,
+			})
+			require.Error(t, err)
+			assert.Equal(t, fmt.Sprintf("path t: %q is out of range of %s time", tc.value, tc.format), err.Error())
+		})
+	}
+}
+
+func TestJSONMappingValidate(t *testing.T) {
+	fields := []JSONField{{Name: "v", Path: "v"}}
+	testCases := []struct {
+		mapping JSONMapping
+		err     string
+	}{
+		{JSONMapping{Fields: fields}, "no measurement"},
+		{JSONMapping{Measurement: "m"}, "no field"},
+		{JSONMapping{Measurement: "m", Fields: []JSONField{{Path: "v"}}}, `cannot use path 'v': invalid lp field name ""`},
+		{JSONMapping{Measurement: "m", Fields: fields, Tags: []JSONTag{{Path: "t"}}}, `cannot use path 't': invalid lp tag name ""`},
+		{JSONMapping{Measurement: "m", Fields: []JSONField{{Name: "v", Path: "v", Type: "complex"}}}, `cannot use path 'v': invalid field type 'complex'`},
+		{JSONMapping{Measurement: "m", Fields: []JSONField{{Name: "v", Path: "$"}}}, "path $: empty path"},
+		{JSONMapping{Measurement: "m", Fields: []JSONField{{Name: "v", Path: "a..b"}}}, "path a..b: empty key"},
+		{JSONMapping{Measurement: "m", Fields: []JSONField{{Name: "v", Path: "a[x]"}}}, "path a[x]: invalid index x"},
+		{JSONMapping{Measurement: "m", Fields: []JSONField{{Name: "v", Path: "a[0"}}}, "path a[0: missing ]"},
+		{JSONMapping{Measurement: "m", Fields: fields, TimePath: "$.t["}, "path $.t[: missing ]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err, func(t *testing.T) {
+			err := tc.mapping.Validate()
+			require.Error(t, err)
+			assert.Equal(t, tc.err, err.Error())
+		})
+	}
+}
+
+func TestJSONPointReader(t *testing.T) {
+	reader := NewJSONPointReader(strings.NewReader(`{"host":"a","v":1}
+{"host":"b","v":"x"}
+{"host":"c","v":3}
+[{"host":"d","v":4},{"host":"e","v":5}]
+{"host":`), &JSONMapping{
+		Measurement: "cpu",
+		Tags:        []JSONTag{{Name: "host", Path: "host"}},
+		Fields:      []JSONField{{Name: "v", Path: "v", Type: "float"}},
+	})
+	var hosts []string
+	var errs []string
+	for {
+		p, err := reader.Next()
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		var perr *JSONPathError
+		if errors.As(err, &perr) {
+			errs = append(errs, err.Error())
+			continue
+		}
+		require.NoError(t, err)
+		hosts = append(hosts, p.TagList()[0].Value)
+	}
+	assert.Equal(t, []string{"a", "c", "d", "e"}, hosts)
+	assert.Equal(t, []string{`path v: cannot convert "x" to float`}, errs)
+	assert.Equal(t, 5, reader.Documents())
+	_, err := reader.Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -71,6 +71,11 @@ type QueryAPI interface {
 	Query(ctx context.Context, query string) (*QueryTableResult, error)
 	// QueryWithParams executes flux parametrized query  on the InfluxDB server and returns QueryTableResult which parses streamed response into structures representing flux table parts
 	QueryWithParams(ctx context.Context, query string, params interface{}) (*QueryTableResult, error)
+	// QueryTables executes flux query on the InfluxDB server and reads the whole response into memory as a list of tables
+	QueryTables(ctx context.Context, query string) (query.FluxTables, error)
+	// QueryTablesWithOptions executes flux query with parameters set by options and reads the whole response into memory as a list of tables.
+	// If the response exceeds row or byte limits of options, tables read so far are returned together with ErrTablesLimit.
+	QueryTablesWithOptions(ctx context.Context, query string, options *QueryTablesOptions) (query.FluxTables, error)
 }
 
 // NewQueryAPI returns new query client for querying buckets belonging to org
@@ -94,8 +99,18 @@ type QueryTableResult struct {
 	table         *query.FluxTableMetadata
 	record        *query.FluxRecord
 	err           error
+	// byResult is true when the result is iterated by results using NextResult
+	byResult bool
+	// pending is true when the current record was read, but not returned by Next yet
+	pending bool
+	// hasResult is true when NextResult found a result
+	hasResult  bool
+	resultName string
 }
 
+// RecordHandler processes a record of a query result
+type RecordHandler func(record *query.FluxRecord) error
+
 // NewQueryTableResult returns new QueryTableResult
 func NewQueryTableResult(rawResponse io.ReadCloser) *QueryTableResult {
 	csvReader := csv.NewReader(rawResponse)
@@ -213,10 +228,11 @@ func (q *queryAPI) QueryWithParams(ctx context.Context, query string, params int
 	},
 		func(resp *http.Response) error {
 			if resp.Header.Get("Content-Encoding") == "gzip" {
-				resp.Body, err = gzip.NewReader(resp.Body)
+				gzipReader, err := gzip.NewReader(resp.Body)
 				if err != nil {
 					return err
 				}
+				resp.Body = &gzipBody{Reader: gzipReader, body: resp.Body}
 			}
 			csvReader := csv.NewReader(resp.Body)
 			csvReader.FieldsPerRecord = -1
@@ -229,6 +245,18 @@ func (q *queryAPI) QueryWithParams(ctx context.Context, query string, params int
 	return queryResult, nil
 }
 
+// gzipBody reads decompressed response body, closing it closes also the response body
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes gzip reader and the response body
+func (g *gzipBody) Close() error {
+	_ = g.Reader.Close()
+	return g.body.Close()
+}
+
 func (q *queryAPI) queryURL() (string, error) {
 	if q.url == "" {
 		u, err := url.Parse(q.httpService.ServerAPIURL())
@@ -322,6 +350,44 @@ func (q *QueryTableResult) Record() *query.FluxRecord {
 	return q.record
 }
 
+// Decode copies values of the last parsed record into the struct pointed to by dst.
+// Struct fields are mapped to columns by `flux` or `lp` tags, see query.FluxRecord.Scan.
+// If dst is a pointer to a slice of structs, or of pointers to structs, Decode reads all remaining records
+// by calling Next and appends them to the slice.
+func (q *QueryTableResult) Decode(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("cannot decode into %T, pointer is required", dst)
+	}
+	slice := v.Elem()
+	if slice.Kind() != reflect.Slice {
+		if q.record == nil {
+			return errors.New("no record, call Next first")
+		}
+		return q.record.Scan(dst)
+	}
+	elemType := slice.Type().Elem()
+	ptr := elemType.Kind() == reflect.Ptr
+	if ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %T, slice of structs is required", dst)
+	}
+	for q.Next() {
+		elem := reflect.New(elemType)
+		if err := q.record.Scan(elem.Interface()); err != nil {
+			return fmt.Errorf("table %d: %w", q.record.Table(), err)
+		}
+		if ptr {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+	}
+	return q.err
+}
+
 type parsingState int
 
 const (
@@ -334,8 +400,106 @@ const (
 // Next advances to next row in query result.
 // During the first time it is called, Next creates also table metadata
 // Actual parsed row is available through Record() function
-// Returns false in case of end or an error, otherwise true
+// Returns false in case of end or an error, otherwise true.
+// When the result is iterated by NextResult, Next returns false also at the end of the current result.
 func (q *QueryTableResult) Next() bool {
+	if q.pending {
+		if q.record.Result() != q.resultName {
+			// the record belongs to the next result
+			return false
+		}
+		q.pending = false
+		return true
+	}
+	if !q.next() {
+		return false
+	}
+	if q.byResult && q.record.Result() != q.resultName {
+		q.pending = true
+		return false
+	}
+	return true
+}
+
+// NextResult advances to the next result, produced by a yield function of the flux query.
+// Records of the rest of the current result are skipped. Records of the new result are then read by Next,
+// which returns false at the end of the result. The result name is available through ResultName().
+// Returns false in case of end or an error, otherwise true.
+func (q *QueryTableResult) NextResult() bool {
+	if !q.byResult && q.record != nil {
+		// records were already read by Next, skip the rest of their result
+		q.resultName = q.record.Result()
+		q.hasResult = true
+	}
+	q.byResult = true
+	for {
+		if q.pending && (!q.hasResult || q.record.Result() != q.resultName) {
+			q.resultName = q.record.Result()
+			q.hasResult = true
+			return true
+		}
+		q.pending = false
+		if !q.next() {
+			return false
+		}
+		q.pending = true
+	}
+}
+
+// ResultName returns name of the current result, set by NextResult.
+// When NextResult is not used, it returns result name of the last parsed record.
+func (q *QueryTableResult) ResultName() string {
+	if q.byResult {
+		return q.resultName
+	}
+	if q.record != nil {
+		return q.record.Result()
+	}
+	return ""
+}
+
+// HandleResults reads all results and passes their records to handlers registered for result names.
+// Records of results without a handler are skipped. Reading stops at the first error returned by a handler.
+func (q *QueryTableResult) HandleResults(handlers map[string]RecordHandler) error {
+	for q.NextResult() {
+		handler, ok := handlers[q.resultName]
+		if !ok {
+			continue
+		}
+		for q.Next() {
+			if err := handler(q.record); err != nil {
+				_ = q.Close()
+				return fmt.Errorf("result %s: %w", q.resultName, err)
+			}
+		}
+	}
+	return q.err
+}
+
+// DecodeResults reads all results and decodes records of each result into the target registered for its name.
+// Targets are pointers to slices of structs, or of pointers to structs, see Decode.
+// Records of results without a target are skipped.
+func (q *QueryTableResult) DecodeResults(targets map[string]interface{}) error {
+	for q.NextResult() {
+		target, ok := targets[q.resultName]
+		if !ok {
+			continue
+		}
+		v := reflect.ValueOf(target)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+			_ = q.Close()
+			return fmt.Errorf("result %s: cannot decode into %T, pointer to slice is required", q.resultName, target)
+		}
+		if err := q.Decode(target); err != nil {
+			_ = q.Close()
+			return fmt.Errorf("result %s: %w", q.resultName, err)
+		}
+	}
+	return q.err
+}
+
+// next parses next row in query result
+func (q *QueryTableResult) next() bool {
 	var row []string
 	// set closing query in case of preliminary return
 	closer := func() {
@@ -0,0 +1,453 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+)
+
+// FluxTable holds all rows of a flux table in memory.
+// Values are stored by columns, in slices of the column data type.
+type FluxTable struct {
+	metadata *FluxTableMetadata
+	columns  []*tableColumn
+	index    map[string]int
+	result   string
+	table    int
+	rows     int
+	size     int
+}
+
+// FluxTables is a list of flux tables in the order of the query response
+type FluxTables []*FluxTable
+
+// columnKind determines how values of a column are stored
+type columnKind int
+
+const (
+	kindOther columnKind = iota
+	kindFloat
+	kindInt
+	kindUint
+	kindString
+	kindBool
+	kindTime
+)
+
+// tableColumn holds values of a column. Only the slice of the column kind is used.
+type tableColumn struct {
+	kind    columnKind
+	floats  []float64
+	ints    []int64
+	uints   []uint64
+	strings []string
+	bools   []bool
+	times   []time.Time
+	others  []interface{}
+	// nulls marks null values, it is nil until the first null value
+	nulls []bool
+}
+
+// NewFluxTable creates empty FluxTable with columns described by metadata
+func NewFluxTable(metadata *FluxTableMetadata) *FluxTable {
+	t := &FluxTable{
+		metadata: metadata,
+		columns:  make([]*tableColumn, len(metadata.Columns())),
+		index:    make(map[string]int, len(metadata.Columns())),
+	}
+	for i, c := range metadata.Columns() {
+		t.columns[i] = &tableColumn{kind: columnKindOf(c.DataType())}
+		t.index[c.Name()] = i
+	}
+	return t
+}
+
+// columnKindOf returns kind of column with flux dataType
+func columnKindOf(dataType string) columnKind {
+	switch dataType {
+	case "double":
+		return kindFloat
+	case "long":
+		return kindInt
+	case "unsignedLong":
+		return kindUint
+	case "string":
+		return kindString
+	case "boolean":
+		return kindBool
+	case "dateTime:RFC3339", "dateTime:RFC3339Nano":
+		return kindTime
+	default:
+		return kindOther
+	}
+}
+
+// AddRecord appends values of record as a new row. Values of columns not present in the table metadata are ignored.
+// The first record sets result name and table number of the table.
+func (t *FluxTable) AddRecord(record *FluxRecord) *FluxTable {
+	if t.rows == 0 {
+		t.result = record.Result()
+		t.table = record.Table()
+	}
+	for i, c := range t.metadata.Columns() {
+		t.size += t.columns[i].append(record.ValueByKey(c.Name()), t.rows)
+	}
+	t.rows++
+	return t
+}
+
+// Metadata returns metadata of the table
+func (t *FluxTable) Metadata() *FluxTableMetadata {
+	return t.metadata
+}
+
+// Columns returns columns of the table
+func (t *FluxTable) Columns() []*FluxColumn {
+	return t.metadata.Columns()
+}
+
+// Result returns name of the result the table belongs to
+func (t *FluxTable) Result() string {
+	return t.result
+}
+
+// Table returns value of the table column, the table number
+func (t *FluxTable) Table() int {
+	return t.table
+}
+
+// Len returns number of rows
+func (t *FluxTable) Len() int {
+	return t.rows
+}
+
+// Size returns approximate size in bytes of stored values
+func (t *FluxTable) Size() int {
+	return t.size
+}
+
+// column returns storage of the named column, or nil if there is no such column
+func (t *FluxTable) column(name string) *tableColumn {
+	if i, ok := t.index[name]; ok {
+		return t.columns[i]
+	}
+	return nil
+}
+
+// Value returns value of column in row, or nil if there is no such column or row or the value is null
+func (t *FluxTable) Value(row int, column string) interface{} {
+	c := t.column(column)
+	if c == nil || row < 0 || row >= t.rows {
+		return nil
+	}
+	return c.value(row)
+}
+
+// IsNull returns true if value of column in row is null or missing
+func (t *FluxTable) IsNull(row int, column string) bool {
+	c := t.column(column)
+	if c == nil || row < 0 || row >= t.rows {
+		return true
+	}
+	return c.isNull(row)
+}
+
+// Values returns all values of column, or nil if there is no such column. Null values are nil.
+func (t *FluxTable) Values(column string) []interface{} {
+	c := t.column(column)
+	if c == nil {
+		return nil
+	}
+	values := make([]interface{}, t.rows)
+	for i := range values {
+		values[i] = c.value(i)
+	}
+	return values
+}
+
+// Floats returns values of a double column, or nil if the column is not double.
+// Null values are zero, use IsNull to distinguish them. The returned slice must not be modified.
+func (t *FluxTable) Floats(column string) []float64 {
+	if c := t.column(column); c != nil && c.kind == kindFloat {
+		return c.floats
+	}
+	return nil
+}
+
+// Ints returns values of a long column, or nil if the column is not long.
+// Null values are zero, use IsNull to distinguish them. The returned slice must not be modified.
+func (t *FluxTable) Ints(column string) []int64 {
+	if c := t.column(column); c != nil && c.kind == kindInt {
+		return c.ints
+	}
+	return nil
+}
+
+// Uints returns values of an unsignedLong column, or nil if the column is not unsignedLong.
+// Null values are zero, use IsNull to distinguish them. The returned slice must not be modified.
+func (t *FluxTable) Uints(column string) []uint64 {
+	if c := t.column(column); c != nil && c.kind == kindUint {
+		return c.uints
+	}
+	return nil
+}
+
+// Strings returns values of a string column, or nil if the column is not string.
+// Null values are empty, use IsNull to distinguish them. The returned slice must not be modified.
+func (t *FluxTable) Strings(column string) []string {
+	if c := t.column(column); c != nil && c.kind == kindString {
+		return c.strings
+	}
+	return nil
+}
+
+// Bools returns values of a boolean column, or nil if the column is not boolean.
+// Null values are false, use IsNull to distinguish them. The returned slice must not be modified.
+func (t *FluxTable) Bools(column string) []bool {
+	if c := t.column(column); c != nil && c.kind == kindBool {
+		return c.bools
+	}
+	return nil
+}
+
+// Times returns values of a dateTime column, or nil if the column is not dateTime.
+// Null values are zero time, use IsNull to distinguish them. The returned slice must not be modified.
+func (t *FluxTable) Times(column string) []time.Time {
+	if c := t.column(column); c != nil && c.kind == kindTime {
+		return c.times
+	}
+	return nil
+}
+
+// Record returns row as FluxRecord, or nil if there is no such row
+func (t *FluxTable) Record(row int) *FluxRecord {
+	if row < 0 || row >= t.rows {
+		return nil
+	}
+	values := make(map[string]interface{}, len(t.columns))
+	for i, c := range t.metadata.Columns() {
+		values[c.Name()] = t.columns[i].value(row)
+	}
+	return NewFluxRecord(t.metadata.Position(), values)
+}
+
+// GroupKey returns values of group key columns
+func (t *FluxTable) GroupKey() map[string]interface{} {
+	key := make(map[string]interface{})
+	for i, c := range t.metadata.Columns() {
+		if c.IsGroup() {
+			if t.rows > 0 {
+				key[c.Name()] = t.columns[i].value(0)
+			} else {
+				key[c.Name()] = nil
+			}
+		}
+	}
+	return key
+}
+
+// matches returns true if the table has group key columns with all values of key
+func (t *FluxTable) matches(key map[string]interface{}) bool {
+	for name, value := range key {
+		i, ok := t.index[name]
+		if !ok || !t.metadata.Columns()[i].IsGroup() || t.rows == 0 {
+			return false
+		}
+		if v := t.columns[i].value(0); !equalValues(v, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalValues compares values, times are compared by time.Time.Equal and byte slices by content.
+// Other values of uncomparable types are never equal.
+func equalValues(a, b interface{}) bool {
+	switch ta := a.(type) {
+	case time.Time:
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	case []byte:
+		tb, ok := b.([]byte)
+		return ok && bytes.Equal(ta, tb)
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// Lookup returns tables whose group key contains all values of key, e.g. {"_measurement": "cpu", "host": "a"}.
+// Values of key must have Go types of values of the columns, e.g. int64 for long columns, not int.
+func (f FluxTables) Lookup(key map[string]interface{}) FluxTables {
+	var tables FluxTables
+	for _, t := range f {
+		if t.matches(key) {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// Results returns names of results in the order of their first table
+func (f FluxTables) Results() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, t := range f {
+		if !seen[t.result] {
+			seen[t.result] = true
+			names = append(names, t.result)
+		}
+	}
+	return names
+}
+
+// Result returns tables of the named result
+func (f FluxTables) Result(name string) FluxTables {
+	var tables FluxTables
+	for _, t := range f {
+		if t.result == name {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// Rows returns total number of rows of all tables
+func (f FluxTables) Rows() int {
+	rows := 0
+	for _, t := range f {
+		rows += t.rows
+	}
+	return rows
+}
+
+// append adds value as row and returns its approximate size in bytes
+func (c *tableColumn) append(v interface{}, row int) int {
+	if v == nil {
+		if c.nulls == nil {
+			c.nulls = make([]bool, row, row+1)
+		}
+		c.nulls = append(c.nulls, true)
+		c.appendZero()
+		return 1
+	}
+	if c.nulls != nil {
+		c.nulls = append(c.nulls, false)
+	}
+	switch c.kind {
+	case kindFloat:
+		if f, ok := v.(float64); ok {
+			c.floats = append(c.floats, f)
+			return 8
+		}
+	case kindInt:
+		if n, ok := v.(int64); ok {
+			c.ints = append(c.ints, n)
+			return 8
+		}
+	case kindUint:
+		if n, ok := v.(uint64); ok {
+			c.uints = append(c.uints, n)
+			return 8
+		}
+	case kindString:
+		if s, ok := v.(string); ok {
+			c.strings = append(c.strings, s)
+			return 16 + len(s)
+		}
+	case kindBool:
+		if b, ok := v.(bool); ok {
+			c.bools = append(c.bools, b)
+			return 1
+		}
+	case kindTime:
+		if t, ok := v.(time.Time); ok {
+			c.times = append(c.times, t)
+			return 24
+		}
+	case kindOther:
+		c.others = append(c.others, v)
+		return valueSize(v)
+	}
+	// value does not match the column type, store the column as interface values
+	c.toOther(row)
+	c.others = append(c.others, v)
+	return valueSize(v)
+}
+
+// valueSize returns approximate size of v in bytes
+func valueSize(v interface{}) int {
+	switch val := v.(type) {
+	case string:
+		return 16 + len(val)
+	case []byte:
+		return 24 + len(val)
+	default:
+		return 16
+	}
+}
+
+// appendZero appends zero value of the column kind
+func (c *tableColumn) appendZero() {
+	switch c.kind {
+	case kindFloat:
+		c.floats = append(c.floats, 0)
+	case kindInt:
+		c.ints = append(c.ints, 0)
+	case kindUint:
+		c.uints = append(c.uints, 0)
+	case kindString:
+		c.strings = append(c.strings, "")
+	case kindBool:
+		c.bools = append(c.bools, false)
+	case kindTime:
+		c.times = append(c.times, time.Time{})
+	default:
+		c.others = append(c.others, nil)
+	}
+}
+
+// toOther converts first rows values of the column to interface values
+func (c *tableColumn) toOther(rows int) {
+	others := make([]interface{}, rows, rows+1)
+	for i := range others {
+		others[i] = c.value(i)
+	}
+	*c = tableColumn{kind: kindOther, others: others, nulls: c.nulls}
+}
+
+// isNull returns true if value in row is null
+func (c *tableColumn) isNull(row int) bool {
+	return c.nulls != nil && c.nulls[row]
+}
+
+// value returns value in row, nil for null
+func (c *tableColumn) value(row int) interface{} {
+	if c.isNull(row) {
+		return nil
+	}
+	switch c.kind {
+	case kindFloat:
+		return c.floats[row]
+	case kindInt:
+		return c.ints[row]
+	case kindUint:
+		return c.uints[row]
+	case kindString:
+		return c.strings[row]
+	case kindBool:
+		return c.bools[row]
+	case kindTime:
+		return c.times[row]
+	default:
+		return c.others[row]
+	}
+}
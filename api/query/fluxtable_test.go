@@ -0,0 +1,141 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTableMetadata() *FluxTableMetadata {
+	return NewFluxTableMetadataFull(0, []*FluxColumn{
+		NewFluxColumnFull("string", "_result", "result", false, 0),
+		NewFluxColumnFull("long", "", "table", false, 1),
+		NewFluxColumnFull("dateTime:RFC3339", "", "_time", false, 2),
+		NewFluxColumnFull("double", "", "_value", false, 3),
+		NewFluxColumnFull("string", "", "host", true, 4),
+		NewFluxColumnFull("unsignedLong", "", "count", false, 5),
+		NewFluxColumnFull("boolean", "", "ok", false, 6),
+		NewFluxColumnFull("duration", "", "elapsed", false, 7),
+	})
+}
+
+func testRecord(table int64, host string, value interface{}, tm time.Time) *FluxRecord {
+	return NewFluxRecord(0, map[string]interface{}{
+		"result":  "_result",
+		"table":   table,
+		"_time":   tm,
+		"_value":  value,
+		"host":    host,
+		"count":   uint64(2),
+		"ok":      true,
+		"elapsed": time.Second,
+	})
+}
+
+func TestFluxTable(t *testing.T) {
+	tm := mustParseTime("2022-01-01T10:00:00Z")
+	table := NewFluxTable(testTableMetadata())
+	assert.Equal(t, 0, table.Len())
+	assert.Equal(t, map[string]interface{}{"host": nil}, table.GroupKey())
+	table.AddRecord(testRecord(1, "a", 1.5, tm)).
+		AddRecord(testRecord(1, "a", nil, tm.Add(time.Second))).
+		AddRecord(testRecord(1, "a", 3.5, tm.Add(2*time.Second)))
+
+	assert.Equal(t, 3, table.Len())
+	assert.Equal(t, "_result", table.Result())
+	assert.Equal(t, 1, table.Table())
+	assert.Len(t, table.Columns(), 8)
+	assert.Greater(t, table.Size(), 0)
+	assert.Equal(t, []float64{1.5, 0, 3.5}, table.Floats("_value"))
+	assert.Nil(t, table.Ints("_value"))
+	assert.Equal(t, []int64{1, 1, 1}, table.Ints("table"))
+	assert.Equal(t, []uint64{2, 2, 2}, table.Uints("count"))
+	assert.Equal(t, []string{"a", "a", "a"}, table.Strings("host"))
+	assert.Equal(t, []bool{true, true, true}, table.Bools("ok"))
+	assert.Equal(t, []time.Time{tm, tm.Add(time.Second), tm.Add(2 * time.Second)}, table.Times("_time"))
+	assert.Equal(t, []interface{}{1.5, nil, 3.5}, table.Values("_value"))
+	assert.Equal(t, []interface{}{time.Second, time.Second, time.Second}, table.Values("elapsed"))
+	assert.Nil(t, table.Values("none"))
+	assert.Nil(t, table.Floats("none"))
+
+	assert.Equal(t, 3.5, table.Value(2, "_value"))
+	assert.Nil(t, table.Value(1, "_value"))
+	assert.Nil(t, table.Value(3, "_value"))
+	assert.Nil(t, table.Value(0, "none"))
+	assert.True(t, table.IsNull(1, "_value"))
+	assert.False(t, table.IsNull(0, "_value"))
+	assert.True(t, table.IsNull(0, "none"))
+
+	record := table.Record(2)
+	require.NotNil(t, record)
+	assert.Equal(t, 3.5, record.Value())
+	assert.Equal(t, "a", record.ValueByKey("host"))
+	assert.Equal(t, tm.Add(2*time.Second), record.Time())
+	assert.Nil(t, table.Record(3))
+
+	assert.Equal(t, map[string]interface{}{"host": "a"}, table.GroupKey())
+}
+
+func TestFluxTableMismatchedType(t *testing.T) {
+	tm := mustParseTime("2022-01-01T10:00:00Z")
+	table := NewFluxTable(testTableMetadata())
+	table.AddRecord(testRecord(0, "a", 1.5, tm)).
+		AddRecord(testRecord(0, "a", nil, tm)).
+		AddRecord(testRecord(0, "a", "x", tm))
+	assert.Nil(t, table.Floats("_value"))
+	assert.Equal(t, []interface{}{1.5, nil, "x"}, table.Values("_value"))
+	assert.True(t, table.IsNull(1, "_value"))
+}
+
+func TestFluxTables(t *testing.T) {
+	tm := mustParseTime("2022-01-01T10:00:00Z")
+	a := NewFluxTable(testTableMetadata()).AddRecord(testRecord(0, "a", 1.0, tm))
+	b := NewFluxTable(testTableMetadata()).AddRecord(testRecord(1, "b", 2.0, tm)).AddRecord(testRecord(1, "b", 3.0, tm))
+	other := testRecord(0, "a", 4.0, tm)
+	other.Values()["result"] = "max"
+	c := NewFluxTable(testTableMetadata()).AddRecord(other)
+	tables := FluxTables{a, b, c}
+
+	assert.Equal(t, 4, tables.Rows())
+	assert.Equal(t, []string{"_result", "max"}, tables.Results())
+	assert.Equal(t, FluxTables{a, b}, tables.Result("_result"))
+	assert.Equal(t, FluxTables{c}, tables.Result("max"))
+	assert.Nil(t, tables.Result("none"))
+
+	assert.Equal(t, FluxTables{a, c}, tables.Lookup(map[string]interface{}{"host": "a"}))
+	assert.Equal(t, FluxTables{b}, tables.Result("_result").Lookup(map[string]interface{}{"host": "b"}))
+	// only group key columns are matched
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"_value": 1.0}))
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"host": "c"}))
+	assert.Equal(t, tables, tables.Lookup(nil))
+}
+
+func TestFluxTablesLookupUncomparable(t *testing.T) {
+	meta := NewFluxTableMetadataFull(0, []*FluxColumn{
+		NewFluxColumnFull("string", "_result", "result", false, 0),
+		NewFluxColumnFull("long", "", "table", false, 1),
+		NewFluxColumnFull("base64Binary", "", "id", true, 2),
+		NewFluxColumnFull("long", "", "code", true, 3),
+	})
+	table := NewFluxTable(meta).AddRecord(NewFluxRecord(0, map[string]interface{}{
+		"result": "_result",
+		"table":  int64(0),
+		"id":     []byte("abc"),
+		"code":   int64(1),
+	}))
+	tables := FluxTables{table}
+	assert.Equal(t, tables, tables.Lookup(map[string]interface{}{"id": []byte("abc")}))
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"id": []byte("abd")}))
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"id": "abc"}))
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"id": []string{"abc"}}))
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"id": nil}))
+	// values must have types of column values
+	assert.Equal(t, tables, tables.Lookup(map[string]interface{}{"code": int64(1)}))
+	assert.Nil(t, tables.Lookup(map[string]interface{}{"code": 1}))
+}
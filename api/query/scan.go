@@ -0,0 +1,236 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// column kinds of struct fields
+const (
+	scanColumn = iota
+	scanField
+)
+
+// scanTarget describes struct field populated from a column
+type scanTarget struct {
+	field  reflect.StructField
+	column string
+	kind   int
+}
+
+// Scan copies values of the record into the struct pointed to by dst.
+// Struct fields are mapped to columns by the `flux:"column"` tag or by the same `lp` tags as used by api.DataToPoint:
+// measurement is read from the _measurement column, tag from the column named by the tag, timestamp from the _time column.
+// Value of a field is read from the column named by the field, as in pivoted results, or from the _value column
+// when the _field column holds the field name. Fields missing in the record are left unchanged,
+// but at least one must be present. Other columns must be present in the record.
+// Fields tagged `flux:"-"` or `lp:"-"` and fields without tags are skipped.
+//
+// Values are converted to types of struct fields, numbers to any numeric type if they fit, durations also to int64.
+// Struct fields can be pointers, which are allocated. Null values leave fields unchanged.
+//
+//	type Temperature struct {
+//		Sensor string    `lp:"tag,sensor"`
+//		Temp   float64   `lp:"field,temperature"`
+//		Time   time.Time `lp:"timestamp"`
+//		Table  int64     `flux:"table"`
+//	}
+func (r *FluxRecord) Scan(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot scan into %T, pointer to struct is required", dst)
+	}
+	v = v.Elem()
+	targets, err := scanTargets(v.Type())
+	if err != nil {
+		return err
+	}
+	fields, fieldsFound := 0, 0
+	for _, t := range targets {
+		value, ok := r.values[t.column]
+		if t.kind == scanField {
+			fields++
+			if !ok && r.Field() == t.column {
+				value, ok = r.values["_value"]
+			}
+			if !ok {
+				continue
+			}
+			fieldsFound++
+		} else if !ok {
+			return fmt.Errorf("cannot scan into field '%s': column '%s' not found", t.field.Name, t.column)
+		}
+		if value == nil {
+			continue
+		}
+		field, err := fieldByIndex(v, t.field.Index)
+		if err == nil {
+			err = setValue(field, value)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot scan column '%s' into field '%s': %w", t.column, t.field.Name, err)
+		}
+	}
+	if fields > 0 && fieldsFound == 0 {
+		return fmt.Errorf("cannot scan into %v: no field found in the record", v.Type())
+	}
+	return nil
+}
+
+// scanTargets returns struct fields mapped to columns
+func scanTargets(t reflect.Type) ([]scanTarget, error) {
+	var targets []scanTarget
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Anonymous || !f.IsExported() {
+			continue
+		}
+		if tag, ok := f.Tag.Lookup("flux"); ok {
+			if tag == "-" {
+				continue
+			}
+			column := strings.Split(tag, ",")[0]
+			if column == "" {
+				column = f.Name
+			}
+			targets = append(targets, scanTarget{field: f, column: column, kind: scanColumn})
+			continue
+		}
+		tag, ok := f.Tag.Lookup("lp")
+		if !ok || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("multiple tag attributes are not supported")
+		}
+		name := f.Name
+		if len(parts) == 2 && parts[1] != "" {
+			name = parts[1]
+		}
+		switch parts[0] {
+		case "measurement":
+			targets = append(targets, scanTarget{field: f, column: "_measurement", kind: scanColumn})
+		case "tag":
+			targets = append(targets, scanTarget{field: f, column: name, kind: scanColumn})
+		case "field":
+			targets = append(targets, scanTarget{field: f, column: name, kind: scanField})
+		case "timestamp":
+			targets = append(targets, scanTarget{field: f, column: "_time", kind: scanColumn})
+		default:
+			return nil, fmt.Errorf("invalid tag %s", parts[0])
+		}
+	}
+	return targets, nil
+}
+
+// fieldByIndex returns nested field, allocating nil embedded pointers on the way
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, fmt.Errorf("cannot set embedded pointer to unexported struct %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// setValue sets value to v, converting it to the type of v
+func setValue(v reflect.Value, value interface{}) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(v.Type()) {
+		v.Set(src)
+		return nil
+	}
+	mismatch := fmt.Errorf("%T is not convertible to %v", value, v.Type())
+	if v.Type() == timeType || src.Type() == timeType {
+		return mismatch
+	}
+	if d, ok := value.(time.Duration); ok {
+		if v.Kind() != reflect.Int64 {
+			return mismatch
+		}
+		src = reflect.ValueOf(int64(d))
+	}
+	switch src.Kind() {
+	case reflect.Int64:
+		n := src.Int()
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(n) {
+				return fmt.Errorf("value %d overflows %v", n, v.Type())
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n < 0 || v.OverflowUint(uint64(n)) {
+				return fmt.Errorf("value %d overflows %v", n, v.Type())
+			}
+			v.SetUint(uint64(n))
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(n))
+		default:
+			return mismatch
+		}
+	case reflect.Uint64:
+		n := src.Uint()
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n > math.MaxInt64 || v.OverflowInt(int64(n)) {
+				return fmt.Errorf("value %d overflows %v", n, v.Type())
+			}
+			v.SetInt(int64(n))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.OverflowUint(n) {
+				return fmt.Errorf("value %d overflows %v", n, v.Type())
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(n))
+		default:
+			return mismatch
+		}
+	case reflect.Float64:
+		f := src.Float()
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if v.OverflowFloat(f) {
+				return fmt.Errorf("value %v overflows %v", f, v.Type())
+			}
+			v.SetFloat(f)
+		default:
+			return mismatch
+		}
+	default:
+		if v.Type() == durationType || !src.Type().ConvertibleTo(v.Type()) || src.Kind() != v.Kind() {
+			return mismatch
+		}
+		// named types, e.g. type Status string
+		v.Set(src.Convert(v.Type()))
+	}
+	return nil
+}
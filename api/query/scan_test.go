@@ -0,0 +1,122 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Status string
+
+type SensorBase struct {
+	Sensor string `lp:"tag,sensor"`
+}
+
+type sensorData struct {
+	*SensorBase
+	Measurement string        `lp:"measurement"`
+	Temp        float32       `lp:"field,temperature"`
+	Hum         *int          `lp:"field,humidity"`
+	Count       uint8         `lp:"field"`
+	Time        time.Time     `lp:"timestamp"`
+	Table       int           `flux:"table"`
+	Status      Status        `flux:"status"`
+	Elapsed     time.Duration `flux:"elapsed"`
+	Description string        `lp:"-"`
+	Ignored     string        `flux:"-" lp:"field,ignored"`
+	Other       string
+}
+
+func TestScanPivoted(t *testing.T) {
+	now := time.Now()
+	record := NewFluxRecord(1, map[string]interface{}{
+		"table":        int64(1),
+		"_measurement": "air",
+		"sensor":       "SHT31",
+		"temperature":  23.5,
+		"humidity":     int64(55),
+		"Count":        uint64(3),
+		"_time":        now,
+		"status":       "ok",
+		"elapsed":      int64(time.Second),
+		"ignored":      "x",
+	})
+	var s sensorData
+	require.NoError(t, record.Scan(&s))
+	hum := 55
+	assert.Equal(t, sensorData{
+		SensorBase:  &SensorBase{Sensor: "SHT31"},
+		Measurement: "air",
+		Temp:        23.5,
+		Hum:         &hum,
+		Count:       3,
+		Time:        now,
+		Table:       1,
+		Status:      "ok",
+		Elapsed:     time.Second,
+	}, s)
+}
+
+func TestScanFieldValue(t *testing.T) {
+	type temperature struct {
+		Sensor string    `lp:"tag,sensor"`
+		Temp   float64   `lp:"field,temperature"`
+		Hum    int64     `lp:"field,humidity"`
+		Time   time.Time `lp:"timestamp"`
+	}
+	record := NewFluxRecord(0, map[string]interface{}{
+		"sensor": "SHT31",
+		"_field": "temperature",
+		"_value": int64(23),
+		"_time":  mustParseTime("2020-02-18T10:34:08Z"),
+	})
+	var s temperature
+	require.NoError(t, record.Scan(&s))
+	assert.Equal(t, temperature{Sensor: "SHT31", Temp: 23, Time: mustParseTime("2020-02-18T10:34:08Z")}, s)
+
+	// null values leave fields unchanged
+	record = NewFluxRecord(0, map[string]interface{}{"sensor": nil, "_field": "humidity", "_value": nil, "_time": nil})
+	require.NoError(t, record.Scan(&s))
+	assert.Equal(t, temperature{Sensor: "SHT31", Temp: 23, Time: mustParseTime("2020-02-18T10:34:08Z")}, s)
+}
+
+func TestScanErrors(t *testing.T) {
+	type columns struct {
+		Value int8   `flux:"_value"`
+		Name  string `flux:"name"`
+	}
+	type fields struct {
+		Temp float64 `lp:"field,temperature"`
+	}
+	type invalid struct {
+		Temp float64 `lp:"value,temperature"`
+	}
+	testCases := []struct {
+		name   string
+		values map[string]interface{}
+		dst    interface{}
+		err    string
+	}{
+		{"not pointer", nil, columns{}, "cannot scan into query.columns, pointer to struct is required"},
+		{"not struct", nil, new(int), "cannot scan into *int, pointer to struct is required"},
+		{"missing column", map[string]interface{}{"_value": int64(1)}, &columns{}, "cannot scan into field 'Name': column 'name' not found"},
+		{"overflow", map[string]interface{}{"_value": int64(300), "name": "a"}, &columns{}, "cannot scan column '_value' into field 'Value': value 300 overflows int8"},
+		{"mismatch", map[string]interface{}{"_value": 1.5, "name": "a"}, &columns{}, "cannot scan column '_value' into field 'Value': float64 is not convertible to int8"},
+		{"string mismatch", map[string]interface{}{"_value": int64(1), "name": true}, &columns{}, "cannot scan column 'name' into field 'Name': bool is not convertible to string"},
+		{"no field", map[string]interface{}{"_field": "humidity", "_value": 1.0}, &fields{}, "cannot scan into query.fields: no field found in the record"},
+		{"invalid tag", map[string]interface{}{}, &invalid{}, "invalid tag value"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewFluxRecord(0, tc.values).Scan(tc.dst)
+			require.Error(t, err)
+			assert.Equal(t, tc.err, err.Error())
+		})
+	}
+}
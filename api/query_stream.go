@@ -0,0 +1,114 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/query"
+)
+
+// ForEach reads all remaining records and passes them to handler.
+// Reading stops when handler returns an error, or promptly when ctx is done, the response body is then closed.
+// It returns the final error, which is also available through Err().
+func (q *QueryTableResult) ForEach(ctx context.Context, handler RecordHandler) error {
+	stop := q.watchContext(ctx)
+	defer stop()
+	for ctx.Err() == nil && q.Next() {
+		if err := handler(q.record); err != nil {
+			_ = q.Closer.Close()
+			q.err = err
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		_ = q.Closer.Close()
+		q.err = err
+	}
+	return q.err
+}
+
+// Records reads all remaining records in a new goroutine and sends them to the returned channel, which has buffer for size records.
+// The channel is closed at the end of the result, on an error, or when ctx is done. Reading stops promptly when ctx is done
+// and the response body is closed. Check Err() for the final error after the channel is closed.
+func (q *QueryTableResult) Records(ctx context.Context, size int) <-chan *query.FluxRecord {
+	ch := make(chan *query.FluxRecord, size)
+	go func() {
+		defer close(ch)
+		_ = q.ForEach(ctx, func(record *query.FluxRecord) error {
+			select {
+			case ch <- record:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return ch
+}
+
+// DecodeChannel reads all remaining records in a new goroutine, decodes them into structs and sends them to ch.
+// ch is a channel of structs, or of pointers to structs, struct fields are mapped to columns as described in Decode.
+// The channel is closed at the end of the result, on an error, or when ctx is done. Reading stops promptly when ctx is done
+// and the response body is closed. Check Err() for the final error after the channel is closed.
+// DecodeChannel returns an error without reading records if ch is not a channel of structs.
+func (q *QueryTableResult) DecodeChannel(ctx context.Context, ch interface{}) error {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.SendDir == 0 {
+		return fmt.Errorf("cannot decode into %T, channel is required", ch)
+	}
+	elemType := v.Type().Elem()
+	ptr := elemType.Kind() == reflect.Ptr
+	if ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %T, channel of structs is required", ch)
+	}
+	go func() {
+		defer v.Close()
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectSend, Chan: v},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		}
+		_ = q.ForEach(ctx, func(record *query.FluxRecord) error {
+			elem := reflect.New(elemType)
+			if err := record.Scan(elem.Interface()); err != nil {
+				return fmt.Errorf("table %d: %w", record.Table(), err)
+			}
+			if ptr {
+				cases[0].Send = elem
+			} else {
+				cases[0].Send = elem.Elem()
+			}
+			if chosen, _, _ := reflect.Select(cases); chosen == 1 {
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+	return nil
+}
+
+// watchContext closes the response body when ctx is done, which interrupts reading of the body.
+// The returned function stops watching.
+func (q *QueryTableResult) watchContext(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = q.Closer.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBody returns data, then blocks reading until it is closed, like a slow response body
+type blockingBody struct {
+	data   io.Reader
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newBlockingBody(data string) *blockingBody {
+	return &blockingBody{data: strings.NewReader(data), closed: make(chan struct{})}
+}
+
+func (b *blockingBody) Read(p []byte) (int, error) {
+	n, err := b.data.Read(p)
+	if n > 0 || !errors.Is(err, io.EOF) {
+		return n, err
+	}
+	<-b.closed
+	return 0, errors.New("read on closed body")
+}
+
+func (b *blockingBody) Close() error {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+	return nil
+}
+
+func (b *blockingBody) isClosed() bool {
+	select {
+	case <-b.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// readTimeout receives from ch, it fails if nothing is received in a second
+func readTimeout(t *testing.T, ch <-chan *query.FluxRecord) (*query.FluxRecord, bool) {
+	select {
+	case r, ok := <-ch:
+		return r, ok
+	case <-time.After(time.Second):
+		require.Fail(t, "channel not closed")
+		return nil, false
+	}
+}
+
+func TestQueryTableResultRecords(t *testing.T) {
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	var values []interface{}
+	for record := range queryResult.Records(context.Background(), 2) {
+		values = append(values, record.Value())
+	}
+	require.NoError(t, queryResult.Err())
+	assert.Equal(t, []interface{}{1.5, 2.5, 3.5, 2.5, nil}, values)
+
+	// cancelled while waiting for data
+	body := newBlockingBody(strings.Join(strings.Split(multiResultCSV, "\r\n")[:6], "\r\n") + "\r\n")
+	queryResult = NewQueryTableResult(body)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := queryResult.Records(ctx, 0)
+	record, ok := readTimeout(t, ch)
+	require.True(t, ok)
+	assert.Equal(t, 1.5, record.Value())
+	record, ok = readTimeout(t, ch)
+	require.True(t, ok)
+	assert.Equal(t, 2.5, record.Value())
+	cancel()
+	_, ok = readTimeout(t, ch)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(queryResult.Err(), context.Canceled))
+	assert.True(t, body.isClosed())
+
+	// cancelled while the consumer does not read
+	queryResult = NewQueryTableResult(newBlockingBody(multiResultCSV))
+	ctx, cancel = context.WithCancel(context.Background())
+	ch = queryResult.Records(ctx, 1)
+	<-time.After(50 * time.Millisecond)
+	cancel()
+	n := 0
+	for {
+		if _, ok = readTimeout(t, ch); !ok {
+			break
+		}
+		n++
+	}
+	assert.LessOrEqual(t, n, 2)
+	assert.True(t, errors.Is(queryResult.Err(), context.Canceled))
+}
+
+func TestQueryTableResultForEach(t *testing.T) {
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	count := 0
+	require.NoError(t, queryResult.ForEach(context.Background(), func(record *query.FluxRecord) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 5, count)
+
+	body := newBlockingBody(multiResultCSV)
+	queryResult = NewQueryTableResult(body)
+	count = 0
+	err := queryResult.ForEach(context.Background(), func(record *query.FluxRecord) error {
+		count++
+		if count == 2 {
+			return errors.New("stop")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "stop")
+	assert.EqualError(t, queryResult.Err(), "stop")
+	assert.Equal(t, 2, count)
+	assert.True(t, body.isClosed())
+
+	// timeout while the server does not send data
+	body = newBlockingBody(multiResultCSV)
+	queryResult = NewQueryTableResult(body)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	count = 0
+	err = queryResult.ForEach(ctx, func(record *query.FluxRecord) error {
+		count++
+		return nil
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 5, count)
+	assert.True(t, body.isClosed())
+}
+
+func TestQueryTableResultDecodeChannel(t *testing.T) {
+	type usage struct {
+		Host  string  `lp:"tag,host"`
+		Value float64 `flux:"_value"`
+	}
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	ch := make(chan *usage)
+	require.NoError(t, queryResult.DecodeChannel(context.Background(), ch))
+	var usages []usage
+	for u := range ch {
+		usages = append(usages, *u)
+	}
+	require.NoError(t, queryResult.Err())
+	assert.Equal(t, []usage{{"a", 1.5}, {"a", 2.5}, {"b", 3.5}, {"a", 2.5}, {"b", 0}}, usages)
+
+	// cancelled while the consumer does not read
+	body := newBlockingBody(multiResultCSV)
+	queryResult = NewQueryTableResult(body)
+	ctx, cancel := context.WithCancel(context.Background())
+	values := make(chan usage)
+	require.NoError(t, queryResult.DecodeChannel(ctx, values))
+	<-values
+	cancel()
+	select {
+	case <-values:
+	case <-time.After(time.Second):
+		require.Fail(t, "channel not closed")
+	}
+	assert.True(t, body.isClosed())
+
+	// decoding error
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	wrong := make(chan struct {
+		Host int `lp:"tag,host"`
+	}, 10)
+	require.NoError(t, queryResult.DecodeChannel(context.Background(), wrong))
+	for range wrong {
+	}
+	assert.EqualError(t, queryResult.Err(), "table 0: cannot scan column 'host' into field 'Host': string is not convertible to int")
+
+	var recvOnly <-chan usage = make(chan usage)
+	assert.EqualError(t, queryResult.DecodeChannel(context.Background(), recvOnly), "cannot decode into <-chan api.usage, channel is required")
+	assert.EqualError(t, queryResult.DecodeChannel(context.Background(), make(chan int)), "cannot decode into chan int, channel of structs is required")
+	assert.EqualError(t, queryResult.DecodeChannel(context.Background(), &usages), "cannot decode into *[]api.usage, channel is required")
+}
+
+func TestGzipBodyClose(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write([]byte("data"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	body := &closeRecorder{Reader: &buf}
+	gr, err := gzip.NewReader(body)
+	require.NoError(t, err)
+	gb := &gzipBody{Reader: gr, body: body}
+	data, err := io.ReadAll(gb)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	require.NoError(t, gb.Close())
+	assert.True(t, body.closed)
+}
+
+// closeRecorder records whether it was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
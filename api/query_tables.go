@@ -0,0 +1,72 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/query"
+)
+
+// ErrTablesLimit is returned by QueryTables when the query result exceeds the row or byte limit
+var ErrTablesLimit = errors.New("query result exceeds limit")
+
+// QueryTablesOptions holds parameters of QueryTablesWithOptions
+type QueryTablesOptions struct {
+	// Params of a parametrized query, see QueryWithParams
+	Params interface{}
+	// MaxRows limits total number of rows of all tables, 0 means no limit
+	MaxRows int
+	// MaxBytes limits approximate total size of stored values of all tables, 0 means no limit
+	MaxBytes int
+}
+
+func (q *queryAPI) QueryTables(ctx context.Context, query string) (query.FluxTables, error) {
+	return q.QueryTablesWithOptions(ctx, query, nil)
+}
+
+func (q *queryAPI) QueryTablesWithOptions(ctx context.Context, flux string, options *QueryTablesOptions) (query.FluxTables, error) {
+	if options == nil {
+		options = &QueryTablesOptions{}
+	}
+	result, err := q.QueryWithParams(ctx, flux, options.Params)
+	if err != nil {
+		return nil, err
+	}
+	return readTables(result, options)
+}
+
+// readTables reads all tables of result. When a limit is exceeded, result is closed
+// and tables read so far are returned together with ErrTablesLimit.
+func readTables(result *QueryTableResult, options *QueryTablesOptions) (query.FluxTables, error) {
+	var tables query.FluxTables
+	var table *query.FluxTable
+	rows, size := 0, 0
+	for result.Next() {
+		if options.MaxRows > 0 && rows == options.MaxRows {
+			_ = result.Close()
+			return tables, fmt.Errorf("%w: more than %d rows", ErrTablesLimit, options.MaxRows)
+		}
+		record := result.Record()
+		if table == nil || result.TableChanged() || record.Table() != table.Table() || record.Result() != table.Result() {
+			table = query.NewFluxTable(result.TableMetadata())
+			tables = append(tables, table)
+		}
+		before := table.Size()
+		table.AddRecord(record)
+		rows++
+		size += table.Size() - before
+		if options.MaxBytes > 0 && size > options.MaxBytes {
+			_ = result.Close()
+			return tables, fmt.Errorf("%w: more than %d bytes", ErrTablesLimit, options.MaxBytes)
+		}
+	}
+	if result.Err() != nil {
+		return tables, result.Err()
+	}
+	return tables, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multiResultCSV holds two tables of the result _result in one annotation block and a table of the result max
+var multiResultCSV = strings.Join([]string{
+	`#datatype,string,long,dateTime:RFC3339,double,string,string`,
+	`#group,false,false,false,false,true,true`,
+	`#default,_result,,,,,`,
+	`,result,table,_time,_value,_field,host`,
+	`,,0,2022-01-01T10:00:00Z,1.5,usage,a`,
+	`,,0,2022-01-01T10:00:10Z,2.5,usage,a`,
+	`,,1,2022-01-01T10:00:00Z,3.5,usage,b`,
+	``,
+	`#datatype,string,long,dateTime:RFC3339,double,string,string`,
+	`#group,false,false,false,false,true,true`,
+	`#default,max,,,,,`,
+	`,result,table,_time,_value,_field,host`,
+	`,,0,2022-01-01T10:00:10Z,2.5,usage,a`,
+	`,,1,2022-01-01T10:00:00Z,,usage,b`,
+	``,
+}, "\r\n")
+
+func newCSVQueryServer(t *testing.T, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(body))
+		assert.NoError(t, err)
+	}))
+}
+
+func TestQueryTables(t *testing.T) {
+	server := newCSVQueryServer(t, multiResultCSV)
+	defer server.Close()
+	queryAPI := NewQueryAPI("org", http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	tables, err := queryAPI.QueryTables(context.Background(), "flux")
+	require.NoError(t, err)
+	require.Len(t, tables, 4)
+	assert.Equal(t, 5, tables.Rows())
+	assert.Equal(t, []string{"_result", "max"}, tables.Results())
+
+	result := tables.Result("_result")
+	require.Len(t, result, 2)
+	assert.Equal(t, 0, result[0].Table())
+	assert.Equal(t, []float64{1.5, 2.5}, result[0].Floats("_value"))
+	assert.Equal(t, mustParseTime("2022-01-01T10:00:10Z"), result[0].Times("_time")[1])
+	assert.Equal(t, map[string]interface{}{"_field": "usage", "host": "a"}, result[0].GroupKey())
+	assert.Equal(t, 1, result[1].Table())
+	assert.Equal(t, []float64{3.5}, result[1].Floats("_value"))
+
+	b := tables.Result("max").Lookup(map[string]interface{}{"host": "b"})
+	require.Len(t, b, 1)
+	assert.True(t, b[0].IsNull(0, "_value"))
+	assert.Len(t, tables.Lookup(map[string]interface{}{"host": "a", "_field": "usage"}), 2)
+}
+
+func TestQueryTablesLimits(t *testing.T) {
+	server := newCSVQueryServer(t, multiResultCSV)
+	defer server.Close()
+	queryAPI := NewQueryAPI("org", http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	tables, err := queryAPI.QueryTablesWithOptions(context.Background(), "flux", &QueryTablesOptions{MaxRows: 3})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrTablesLimit))
+	assert.EqualError(t, err, "query result exceeds limit: more than 3 rows")
+	assert.Len(t, tables, 2)
+	assert.Equal(t, 3, tables.Rows())
+
+	tables, err = queryAPI.QueryTablesWithOptions(context.Background(), "flux", &QueryTablesOptions{MaxBytes: 100})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrTablesLimit))
+	assert.Greater(t, tables.Rows(), 0)
+	assert.Less(t, tables.Rows(), 5)
+
+	tables, err = queryAPI.QueryTablesWithOptions(context.Background(), "flux", &QueryTablesOptions{MaxRows: 5, MaxBytes: 10_000})
+	require.NoError(t, err)
+	assert.Equal(t, 5, tables.Rows())
+}
+
+func TestQueryTablesError(t *testing.T) {
+	csvTable := strings.Join([]string{
+		`#datatype,string,long,double`,
+		`#group,false,false,false`,
+		`#default,_result,,`,
+		`,result,table,_value`,
+		`,,0,1`,
+		``,
+		`#datatype,string,string`,
+		`#group,true,true`,
+		`#default,,`,
+		`,error,reference`,
+		`,failed to create physical plan: invalid time bounds from procedure from: bounds contain zero time,897`,
+		``,
+	}, "\r\n")
+	server := newCSVQueryServer(t, csvTable)
+	defer server.Close()
+	queryAPI := NewQueryAPI("org", http2.NewService(server.URL, "a", http2.DefaultOptions()))
+
+	tables, err := queryAPI.QueryTables(context.Background(), "flux")
+	require.Error(t, err)
+	assert.Equal(t, "failed to create physical plan: invalid time bounds from procedure from: bounds contain zero time,897", err.Error())
+	require.Len(t, tables, 1)
+	assert.Equal(t, []float64{1}, tables[0].Floats("_value"))
+}
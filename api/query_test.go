@@ -6,6 +6,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -1066,3 +1067,152 @@ func makeCSVstring(rows []string) string {
 	csvTable := strings.Join(rows, "\r\n")
 	return fmt.Sprintf("%s\r\n", csvTable)
 }
+
+func TestQueryTableResultDecode(t *testing.T) {
+	type temperature struct {
+		Sensor string    `lp:"tag,sensor"`
+		Temp   float64   `lp:"field,temperature"`
+		Time   time.Time `lp:"timestamp"`
+		Table  int       `flux:"table"`
+	}
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,dateTime:RFC3339,double,string,string`,
+		`#group,false,false,false,false,true,true`,
+		`#default,_result,,,,,`,
+		`,result,table,_time,_value,_field,sensor`,
+		`,,0,2020-02-18T10:34:08Z,23.5,temperature,a`,
+		`,,0,2020-02-18T10:35:08Z,24,temperature,a`,
+		`,,1,2020-02-18T10:34:08Z,21,temperature,b`,
+	})
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(csvTable)))
+	var single temperature
+	assert.EqualError(t, queryResult.Decode(&single), "no record, call Next first")
+	require.True(t, queryResult.Next())
+	require.NoError(t, queryResult.Decode(&single))
+	assert.Equal(t, temperature{Sensor: "a", Temp: 23.5, Time: mustParseTime("2020-02-18T10:34:08Z")}, single)
+
+	var rest []*temperature
+	require.NoError(t, queryResult.Decode(&rest))
+	require.Len(t, rest, 2)
+	assert.Equal(t, temperature{Sensor: "a", Temp: 24, Time: mustParseTime("2020-02-18T10:35:08Z")}, *rest[0])
+	assert.Equal(t, temperature{Sensor: "b", Temp: 21, Time: mustParseTime("2020-02-18T10:34:08Z"), Table: 1}, *rest[1])
+
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(csvTable)))
+	var ints []int
+	assert.EqualError(t, queryResult.Decode(&ints), "cannot decode into *[]int, slice of structs is required")
+	assert.EqualError(t, queryResult.Decode(single), "cannot decode into api.temperature, pointer is required")
+	var wrong []struct {
+		Sensor int `lp:"tag,sensor"`
+	}
+	assert.EqualError(t, queryResult.Decode(&wrong), "table 0: cannot scan column 'sensor' into field 'Sensor': string is not convertible to int")
+}
+
+func TestQueryTableResultNextResult(t *testing.T) {
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	var names []string
+	var counts []int
+	for queryResult.NextResult() {
+		names = append(names, queryResult.ResultName())
+		count := 0
+		for queryResult.Next() {
+			if count == 0 {
+				assert.True(t, queryResult.TableChanged())
+			}
+			assert.Equal(t, queryResult.ResultName(), queryResult.Record().Result())
+			count++
+		}
+		counts = append(counts, count)
+	}
+	require.NoError(t, queryResult.Err())
+	assert.Equal(t, []string{"_result", "max"}, names)
+	assert.Equal(t, []int{3, 2}, counts)
+
+	// skipping the rest of a result
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	require.True(t, queryResult.Next())
+	assert.Equal(t, "_result", queryResult.ResultName())
+	require.True(t, queryResult.NextResult())
+	assert.Equal(t, "max", queryResult.ResultName())
+	require.True(t, queryResult.Next())
+	assert.Equal(t, 2.5, queryResult.Record().Value())
+	assert.False(t, queryResult.NextResult())
+	require.NoError(t, queryResult.Err())
+
+	// skipping a whole result
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	require.True(t, queryResult.NextResult())
+	require.True(t, queryResult.NextResult())
+	assert.Equal(t, "max", queryResult.ResultName())
+	require.True(t, queryResult.Next())
+	require.True(t, queryResult.Next())
+	assert.False(t, queryResult.Next())
+	assert.False(t, queryResult.NextResult())
+
+	// without NextResult, Next reads all records
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	assert.Equal(t, "", queryResult.ResultName())
+	count := 0
+	for queryResult.Next() {
+		count++
+	}
+	assert.Equal(t, 5, count)
+	assert.Equal(t, "max", queryResult.ResultName())
+}
+
+func TestQueryTableResultHandleResults(t *testing.T) {
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	var values []interface{}
+	err := queryResult.HandleResults(map[string]RecordHandler{
+		"max": func(record *query.FluxRecord) error {
+			values = append(values, record.Value())
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{2.5, nil}, values)
+
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	calls := 0
+	err = queryResult.HandleResults(map[string]RecordHandler{
+		"_result": func(record *query.FluxRecord) error {
+			calls++
+			return errors.New("failed")
+		},
+		"max": func(record *query.FluxRecord) error {
+			calls++
+			return nil
+		},
+	})
+	assert.EqualError(t, err, "result _result: failed")
+	assert.Equal(t, 1, calls)
+}
+
+func TestQueryTableResultDecodeResults(t *testing.T) {
+	type usage struct {
+		Host  string  `lp:"tag,host"`
+		Value float64 `flux:"_value"`
+	}
+	type maxUsage struct {
+		Host string    `flux:"host"`
+		Time time.Time `flux:"_time"`
+	}
+	queryResult := NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	var usages []usage
+	var maxUsages []*maxUsage
+	require.NoError(t, queryResult.DecodeResults(map[string]interface{}{"_result": &usages, "max": &maxUsages}))
+	assert.Equal(t, []usage{{"a", 1.5}, {"a", 2.5}, {"b", 3.5}}, usages)
+	require.Len(t, maxUsages, 2)
+	assert.Equal(t, maxUsage{"a", mustParseTime("2022-01-01T10:00:10Z")}, *maxUsages[0])
+	assert.Equal(t, "b", maxUsages[1].Host)
+
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	usages = nil
+	require.NoError(t, queryResult.DecodeResults(map[string]interface{}{"max": &usages}))
+	require.Len(t, usages, 2)
+	assert.Equal(t, usage{Host: "b"}, usages[1])
+
+	queryResult = NewQueryTableResult(io.NopCloser(strings.NewReader(multiResultCSV)))
+	var single usage
+	assert.EqualError(t, queryResult.DecodeResults(map[string]interface{}{"_result": &single}),
+		"result _result: cannot decode into *api.usage, pointer to slice is required")
+}
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,3 +31,174 @@ func validFieldType(t reflect.Type) bool {
 		t.Kind() == reflect.String ||
 		t == timeType
 }
+
+// PointValueMarshaler is implemented by types of struct fields, which provide their own value of a measurement, tag or field
+// for DataToPoint and StructEncoder. MarshalPointValue returns a bool, integer, float or string value.
+type PointValueMarshaler interface {
+	MarshalPointValue() (interface{}, error)
+}
+
+// pointValueMarshalerType is the type of PointValueMarshaler interface
+var pointValueMarshalerType = reflect.TypeOf((*PointValueMarshaler)(nil)).Elem()
+
+// structField is a struct field mapped to a part of a point
+type structField struct {
+	// index of the field for reflect.Value.FieldByIndex
+	index []int
+	// name of the struct field
+	fieldName string
+	// name is key of a tag or field
+	name string
+	// key is name escaped for line protocol
+	key       string
+	omitEmpty bool
+	// marshaler is true if the field or pointer to the field implements PointValueMarshaler
+	marshaler bool
+}
+
+// structLayout describes mapping of a struct type to a point, fields and tags are sorted by key
+type structLayout struct {
+	measurement *structField
+	tags        []*structField
+	fields      []*structField
+	timestamp   *structField
+}
+
+// structLayoutEntry is a cached result of compiling struct layout
+type structLayoutEntry struct {
+	layout *structLayout
+	err    error
+}
+
+// structLayouts caches layouts by struct type
+var structLayouts sync.Map
+
+// structLayoutOf returns layout of struct type t, it is compiled once per type
+func structLayoutOf(t reflect.Type) (*structLayout, error) {
+	if e, ok := structLayouts.Load(t); ok {
+		entry := e.(*structLayoutEntry)
+		return entry.layout, entry.err
+	}
+	layout, err := compileStructLayout(t)
+	structLayouts.Store(t, &structLayoutEntry{layout: layout, err: err})
+	return layout, err
+}
+
+// compileStructLayout creates layout of struct type t from lp tags of its fields
+func compileStructLayout(t reflect.Type) (*structLayout, error) {
+	layout := &structLayout{}
+	for _, f := range reflect.VisibleFields(t) {
+		tag, ok := f.Tag.Lookup("lp")
+		if !ok || tag == "-" || f.Anonymous {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		omitEmpty := false
+		if len(parts) == 3 && parts[2] == "omitempty" {
+			omitEmpty = true
+			parts = parts[:2]
+		}
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("multiple tag attributes are not supported")
+		}
+		typ := parts[0]
+		name := f.Name
+		if len(parts) == 2 {
+			name = parts[1]
+		}
+		sf := &structField{index: f.Index, fieldName: f.Name, name: name, key: keyEscaper.Replace(name), omitEmpty: omitEmpty,
+			marshaler: f.Type.Implements(pointValueMarshalerType) || reflect.PtrTo(f.Type).Implements(pointValueMarshalerType)}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if !sf.marshaler && ft.Kind() != reflect.Interface && !validFieldType(ft) {
+			return nil, fmt.Errorf("cannot use field '%s' of type '%v' as to create a point", f.Name, ft)
+		}
+		switch typ {
+		case "measurement":
+			if layout.measurement != nil {
+				return nil, fmt.Errorf("multiple measurement fields")
+			}
+			layout.measurement = sf
+		case "tag":
+			if name == "" {
+				return nil, fmt.Errorf("cannot use field '%s': invalid lp tag name \"\"", f.Name)
+			}
+			layout.tags = append(layout.tags, sf)
+		case "field":
+			if name == "" {
+				return nil, fmt.Errorf("cannot use field '%s': invalid lp field name \"\"", f.Name)
+			}
+			layout.fields = append(layout.fields, sf)
+		case "timestamp":
+			if ft != timeType {
+				return nil, fmt.Errorf("cannot use field '%s' as a timestamp", f.Name)
+			}
+			layout.timestamp = sf
+		default:
+			return nil, fmt.Errorf("invalid tag %s", typ)
+		}
+	}
+	if layout.measurement == nil {
+		return nil, fmt.Errorf("no struct field with tag 'measurement'")
+	}
+	if len(layout.fields) == 0 {
+		return nil, fmt.Errorf("no struct field with tag 'field'")
+	}
+	sort.SliceStable(layout.tags, func(i, j int) bool { return layout.tags[i].name < layout.tags[j].name })
+	sort.SliceStable(layout.fields, func(i, j int) bool { return layout.fields[i].name < layout.fields[j].name })
+	return layout, nil
+}
+
+// value returns value of the struct field in struct v, dereferencing pointers.
+// It returns invalid value if the field or an embedded struct on its path is a nil pointer,
+// or if the field is empty and omitempty is set.
+func (sf *structField) value(v reflect.Value) reflect.Value {
+	for i, x := range sf.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	if sf.omitEmpty && v.IsZero() {
+		return reflect.Value{}
+	}
+	if sf.marshaler {
+		return v
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// marshal returns value of PointValueMarshaler
+func (sf *structField) marshal(v reflect.Value) (interface{}, error) {
+	var m PointValueMarshaler
+	switch {
+	case v.Type().Implements(pointValueMarshalerType):
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, nil
+		}
+		m = v.Interface().(PointValueMarshaler)
+	case v.CanAddr():
+		m = v.Addr().Interface().(PointValueMarshaler)
+	default:
+		// not addressable copy of a value with pointer receiver
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		m = p.Interface().(PointValueMarshaler)
+	}
+	value, err := m.MarshalPointValue()
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal field '%s': %w", sf.fieldName, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// nameEscaper escapes measurement
+	nameEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\f", `\f`, "\r", `\r`, `,`, `\,`, ` `, `\ `)
+	// keyEscaper escapes tag keys, tag values and field keys
+	keyEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\f", `\f`, "\r", `\r`, `,`, `\,`, ` `, `\ `, `=`, `\=`)
+	// stringFieldEscaper escapes string field values
+	stringFieldEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\f", `\f`, "\r", `\r`, `"`, `\"`, `\`, `\\`)
+)
+
+// StructEncoder encodes structs annotated by lp tags, as described at DataToPoint, directly into line protocol.
+// Layout of a struct type is parsed once and cached, values are appended to a buffer without creating a Point.
+// Tags and fields are written sorted by key, so the output is the same as the output of a point created by DataToPoint.
+// StructEncoder is safe for concurrent use.
+//
+//	encoder := api.NewStructEncoder(time.Nanosecond)
+//	var buf []byte
+//	for _, s := range sensors {
+//		if buf, err = encoder.Encode(buf, s); err != nil {
+//			return err
+//		}
+//	}
+//	err = writeAPI.WriteRecord(ctx, string(buf))
+type StructEncoder struct {
+	precision time.Duration
+}
+
+// NewStructEncoder creates StructEncoder writing timestamps in precision, which is one of
+// time.Nanosecond, time.Microsecond, time.Millisecond or time.Second.
+func NewStructEncoder(precision time.Duration) *StructEncoder {
+	return &StructEncoder{precision: precision}
+}
+
+// Precision returns precision of timestamps
+func (e *StructEncoder) Precision() time.Duration {
+	return e.precision
+}
+
+// Encode appends line protocol line of struct or pointer to struct x, terminated by a new line, to buf
+// and returns the extended buffer. In case of an error, buf is returned unchanged.
+func (e *StructEncoder) Encode(buf []byte, x interface{}) ([]byte, error) {
+	t := reflect.TypeOf(x)
+	v := reflect.ValueOf(x)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		v = v.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return buf, fmt.Errorf("cannot use %v as point", t)
+	}
+	if !v.IsValid() {
+		return buf, errors.New("cannot use nil as point")
+	}
+	layout, err := structLayoutOf(t)
+	if err != nil {
+		return buf, err
+	}
+	start := len(buf)
+	if buf, err = e.encode(buf, layout, v); err != nil {
+		return buf[:start], err
+	}
+	return buf, nil
+}
+
+// encode appends line of struct value v with layout to buf
+func (e *StructEncoder) encode(buf []byte, layout *structLayout, v reflect.Value) ([]byte, error) {
+	var measurement string
+	var err error
+	if fv := layout.measurement.value(v); fv.IsValid() {
+		if measurement, err = stringValue(layout.measurement, fv); err != nil {
+			return buf, err
+		}
+	}
+	if measurement == "" {
+		return buf, errors.New("empty measurement")
+	}
+	buf = append(buf, nameEscaper.Replace(measurement)...)
+	for _, sf := range layout.tags {
+		fv := sf.value(v)
+		if !fv.IsValid() {
+			continue
+		}
+		value, err := stringValue(sf, fv)
+		if err != nil {
+			return buf, err
+		}
+		// empty tags are not encodable
+		if value == "" {
+			continue
+		}
+		buf = append(buf, ',')
+		buf = append(buf, sf.key...)
+		buf = append(buf, '=')
+		buf = append(buf, keyEscaper.Replace(value)...)
+	}
+	sep := byte(' ')
+	for _, sf := range layout.fields {
+		fv := sf.value(v)
+		if !fv.IsValid() {
+			continue
+		}
+		if sf.marshaler {
+			value, err := sf.marshal(fv)
+			if err != nil {
+				return buf, err
+			}
+			if value == nil {
+				continue
+			}
+			fv = reflect.ValueOf(value)
+		}
+		buf = append(buf, sep)
+		buf = append(buf, sf.key...)
+		buf = append(buf, '=')
+		if buf, err = appendFieldValue(buf, sf, fv); err != nil {
+			return buf, err
+		}
+		sep = ','
+	}
+	if sep == ' ' {
+		return buf, errors.New("no field value")
+	}
+	if layout.timestamp != nil {
+		if fv := layout.timestamp.value(v); fv.IsValid() {
+			if ts := fv.Interface().(time.Time); !ts.IsZero() {
+				buf = append(buf, ' ')
+				buf = strconv.AppendInt(buf, timestamp(ts, e.precision), 10)
+			}
+		}
+	}
+	return append(buf, '\n'), nil
+}
+
+// timestamp returns ts as number of precision units since Unix epoch
+func timestamp(ts time.Time, precision time.Duration) int64 {
+	switch precision {
+	case time.Microsecond:
+		return ts.UnixNano() / 1000
+	case time.Millisecond:
+		return ts.UnixNano() / 1000000
+	case time.Second:
+		return ts.Unix()
+	default:
+		return ts.UnixNano()
+	}
+}
+
+// appendFieldValue appends line protocol representation of field value v to buf
+func appendFieldValue(buf []byte, sf *structField, v reflect.Value) ([]byte, error) {
+	switch v.Type() {
+	case timeType:
+		return appendStringField(buf, v.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	case durationType:
+		return appendStringField(buf, time.Duration(v.Int()).String()), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(strconv.AppendInt(buf, v.Int(), 10), 'i'), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return append(strconv.AppendUint(buf, v.Uint(), 10), 'u'), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return buf, fmt.Errorf("cannot use field '%s': invalid float value %v", sf.fieldName, f)
+		}
+		return strconv.AppendFloat(buf, f, 'f', -1, 64), nil
+	case reflect.Bool:
+		return strconv.AppendBool(buf, v.Bool()), nil
+	case reflect.String:
+		return appendStringField(buf, v.String()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendStringField(buf, string(v.Bytes())), nil
+		}
+	}
+	return buf, fmt.Errorf("cannot use field '%s' of type '%v' as to create a point", sf.fieldName, v.Type())
+}
+
+// appendStringField appends quoted and escaped string field value to buf
+func appendStringField(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	buf = append(buf, stringFieldEscaper.Replace(s)...)
+	return append(buf, '"')
+}
+
+// durationType is the exact type of time.Duration
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// stringValue returns value of measurement or tag struct field v as a string
+func stringValue(sf *structField, v reflect.Value) (string, error) {
+	if sf.marshaler {
+		value, err := sf.marshal(v)
+		if err != nil || value == nil {
+			return "", err
+		}
+		v = reflect.ValueOf(value)
+	}
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	}
+	return "", fmt.Errorf("cannot use field '%s' of type '%v' as to create a point", sf.fieldName, v.Type())
+}
@@ -0,0 +1,196 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	lp "github.com/influxdata/line-protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Location struct {
+	Building string `lp:"tag,building"`
+	Floor    *int   `lp:"tag,floor"`
+}
+
+type Level int
+
+func (l Level) MarshalPointValue() (interface{}, error) {
+	if l < 0 {
+		return nil, errors.New("negative level")
+	}
+	return []string{"low", "mid", "high"}[l], nil
+}
+
+type Celsius float64
+
+func (c *Celsius) MarshalPointValue() (interface{}, error) {
+	return float64(*c) + 273.15, nil
+}
+
+type Reading struct {
+	*Location
+	Measurement string         `lp:"measurement"`
+	Sensor      string         `lp:"tag,sensor"`
+	Note        string         `lp:"tag,note,omitempty"`
+	Temp        Celsius        `lp:"field,kelvin"`
+	Hum         *int           `lp:"field,humidity"`
+	Count       uint16         `lp:"field,count,omitempty"`
+	Level       Level          `lp:"field,level"`
+	Text        string         `lp:"field,text"`
+	Any         interface{}    `lp:"field,any"`
+	Uptime      time.Duration  `lp:"field,uptime"`
+	Time        *time.Time     `lp:"timestamp"`
+	Ignored     map[string]int `lp:"-"`
+}
+
+func encodePoint(p *write.Point, precision time.Duration) string {
+	var buffer bytes.Buffer
+	e := lp.NewEncoder(&buffer)
+	e.SetFieldTypeSupport(lp.UintSupport)
+	e.FailOnFieldErr(true)
+	e.SetPrecision(precision)
+	if _, err := e.Encode(p); err != nil {
+		panic(err)
+	}
+	return buffer.String()
+}
+
+func TestStructEncoder(t *testing.T) {
+	floor, hum := 2, 55
+	ts := time.Unix(1600000000, 123456789)
+	tests := []struct {
+		name string
+		s    interface{}
+		line string
+	}{
+		{
+			name: "all",
+			s: &Reading{Location: &Location{Building: "A 1", Floor: &floor}, Measurement: "air,in", Sensor: "x=y", Note: "n",
+				Temp: 20, Hum: &hum, Count: 3, Level: 1, Text: `a "b" \c`, Any: 1.5, Uptime: time.Minute, Time: &ts},
+			line: `air\,in,building=A\ 1,floor=2,note=n,sensor=x\=y any=1.5,count=3u,humidity=55i,kelvin=293.15,level="mid",text="a \"b\" \\c",uptime="1m0s" 1600000000123456789` + "\n",
+		},
+		{
+			name: "nil and empty",
+			s:    Reading{Measurement: "air", Sensor: "s", Temp: 1.5},
+			line: "air,sensor=s kelvin=274.65,level=\"low\",text=\"\",uptime=\"0s\"\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf, err := NewStructEncoder(time.Nanosecond).Encode([]byte("prefix\n"), tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, "prefix\n"+tc.line, string(buf))
+			p, err := DataToPoint(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.line, encodePoint(p, time.Nanosecond))
+		})
+	}
+}
+
+func TestStructEncoderPrecision(t *testing.T) {
+	ts := time.Unix(1600000000, 123456789)
+	s := struct {
+		Measurement string    `lp:"measurement"`
+		Value       float64   `lp:"field,v"`
+		Time        time.Time `lp:"timestamp"`
+	}{"m", 1, ts}
+	p, err := DataToPoint(s)
+	require.NoError(t, err)
+	for _, precision := range []time.Duration{time.Nanosecond, time.Microsecond, time.Millisecond, time.Second} {
+		buf, err := NewStructEncoder(precision).Encode(nil, s)
+		require.NoError(t, err)
+		assert.Equal(t, encodePoint(p, precision), string(buf), precision)
+	}
+}
+
+func TestStructEncoderErrors(t *testing.T) {
+	m := -1
+	tests := []struct {
+		name string
+		s    interface{}
+		err  string
+	}{
+		{"map", map[string]int{}, "cannot use map[string]int as point"},
+		{"nil", nil, "cannot use <nil> as point"},
+		{"nil pointer", (*Reading)(nil), "cannot use nil as point"},
+		{"empty measurement", Reading{Temp: 1}, "empty measurement"},
+		{"marshaler error", Reading{Measurement: "m", Level: Level(m)}, "cannot marshal field 'Level': negative level"},
+		{"invalid any", Reading{Measurement: "m", Any: []int{1}}, "cannot use field 'Any' of type '[]int' as to create a point"},
+		{"NaN", struct {
+			Measurement string  `lp:"measurement"`
+			Value       float64 `lp:"field,v"`
+		}{"m", math.NaN()}, "cannot use field 'Value': invalid float value NaN"},
+		{"no field", struct {
+			Measurement string `lp:"measurement"`
+			Value       *int   `lp:"field,v"`
+		}{"m", nil}, "no field value"},
+		{"layout", struct {
+			Measurement string     `lp:"measurement"`
+			Value       complex128 `lp:"field,v"`
+		}{"m", 1}, "cannot use field 'Value' of type 'complex128' as to create a point"},
+	}
+	encoder := NewStructEncoder(time.Nanosecond)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf, err := encoder.Encode([]byte("x"), tc.s)
+			require.Error(t, err)
+			assert.Equal(t, tc.err, err.Error())
+			assert.Equal(t, "x", string(buf))
+		})
+	}
+}
+
+func TestStructEncoderConcurrent(t *testing.T) {
+	encoder := NewStructEncoder(time.Second)
+	lines := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		go func(i int) {
+			buf, err := encoder.Encode(nil, &Reading{Measurement: "m", Sensor: fmt.Sprint(i), Temp: 1})
+			if err != nil {
+				lines <- err.Error()
+				return
+			}
+			lines <- string(buf)
+		}(i)
+	}
+	for i := 0; i < 100; i++ {
+		line := <-lines
+		assert.True(t, strings.HasPrefix(line, "m,sensor="), line)
+	}
+}
+
+func BenchmarkStructEncoder(b *testing.B) {
+	hum := 55
+	ts := time.Now()
+	r := &Reading{Location: &Location{Building: "A"}, Measurement: "air", Sensor: "s1", Temp: 20, Hum: &hum, Text: "t", Time: &ts}
+	encoder := NewStructEncoder(time.Nanosecond)
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = encoder.Encode(buf[:0], r)
+	}
+}
+
+func BenchmarkDataToPoint(b *testing.B) {
+	hum := 55
+	ts := time.Now()
+	r := &Reading{Location: &Location{Building: "A"}, Measurement: "air", Sensor: "s1", Temp: 20, Hum: &hum, Text: "t", Time: &ts}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = DataToPoint(r)
+	}
+}
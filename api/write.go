@@ -6,6 +6,9 @@ package api
 
 import (
 	"context"
+	"errors"
+	"hash/fnv"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,6 +24,8 @@ import (
 // batch contains complete payload, error holds detailed error information,
 // retryAttempts means number of retries, 0 if it failed during first write.
 // It must return true if WriteAPI should continue with retrying, false will discard the batch.
+// The callback is also notified about lines rejected by the server because of invalid data, which are never retried.
+// In such case, error holds *write.WriteError as the nested error and the returned value is ignored.
 type WriteFailedCallback func(batch string, error http2.Error, retryAttempts uint) bool
 
 // WriteAPI is Write client interface with non-blocking methods for writing time series data asynchronously in batches into an InfluxDB server.
@@ -35,11 +40,25 @@ type WriteAPI interface {
 	// WritePoint adds Point into the buffer which is sent on the background when it reaches the batch size.
 	// Blocking alternative is available in the WriteAPIBlocking interface
 	WritePoint(point *write.Point)
+	// TryWriteRecord adds line protocol record into the buffer only if it can be done without waiting.
+	// It returns ErrBufferFull if the buffer cannot accept the record, regardless of the overflow policy.
+	TryWriteRecord(line string) error
+	// TryWritePoint adds Point into the buffer only if it can be done without waiting.
+	// It returns ErrBufferFull if the buffer cannot accept the point, regardless of the overflow policy.
+	TryWritePoint(point *write.Point) error
+	// DroppedPoints returns number of points dropped by the overflow policy so far
+	DroppedPoints() uint64
+	// Stats returns statistics of writing, e.g. number of accepted and written lines, retries or request latencies
+	Stats() write.Stats
+	// CircuitState returns state of the circuit breaker, it is always CircuitClosed when the circuit breaker is disabled
+	CircuitState() write.CircuitState
 	// Flush forces all pending writes from the buffer to be sent
 	Flush()
 	// Errors returns a channel for reading errors which occurs during async writes.
 	// Must be called before performing any writes for errors to be collected.
 	// The chan is unbuffered and must be drained or the writer will block.
+	// Lines rejected by the server because of invalid data are reported as http.Error with nested *write.WriteError.
+	// Points and records rejected by validation (see write.Options.SetValidationLevel) are reported as *write.ValidationError.
 	Errors() <-chan error
 	// SetWriteFailedCallback sets callback allowing custom handling of failed writes.
 	// If callback returns true, failed batch will be retried, otherwise discarded.
@@ -48,8 +67,24 @@ type WriteAPI interface {
 
 // WriteAPIImpl provides main implementation for WriteAPI
 type WriteAPIImpl struct {
+	// statistics counters, see write.Stats.
+	// They are updated atomically and must stay at the beginning of the struct to be 64-bit aligned.
+	droppedPoints  uint64
+	pointsAccepted uint64
+	linesAccepted  uint64
+	bytesAccepted  uint64
+
 	service     *iwrite.Service
 	writeBuffer []string
+	// number of batches sent to write workers and not written yet, guarded by inFlightMu
+	inFlight   int
+	inFlightMu sync.Mutex
+	// inFlightDone is signalled when all sent batches are written
+	inFlightDone *sync.Cond
+	// channels of write workers when series order is preserved, otherwise workers share writeCh
+	workerChs []chan *iwrite.Batch
+	// size of lines in writeBuffer in bytes
+	writeBufferBytes int
 
 	errCh        chan error
 	writeCh      chan *iwrite.Batch
@@ -66,18 +101,36 @@ type WriteAPIImpl struct {
 	isErrChReader int32
 }
 
+// ErrBufferFull is returned or reported when the WriteAPI buffer cannot accept a new point
+var ErrBufferFull = errors.New("write buffer is full")
+
 type writeBuffInfoReq struct {
 	writeBuffLen int
 }
 
 // NewWriteAPI returns new non-blocking write client for writing data to  bucket belonging to org
 func NewWriteAPI(org string, bucket string, service http2.Service, writeOptions *write.Options) *WriteAPIImpl {
+	return newWriteAPI(iwrite.NewService(org, bucket, service, writeOptions), writeOptions)
+}
+
+// NewWriteAPIV1 returns new non-blocking write client for writing data to database db and retention policy rp
+// using the v1 compatibility endpoint. Empty rp means the default retention policy.
+func NewWriteAPIV1(db string, rp string, service http2.Service, writeOptions *write.Options) *WriteAPIImpl {
+	return newWriteAPI(iwrite.NewServiceV1(db, rp, service, writeOptions), writeOptions)
+}
+
+// newWriteAPI returns new non-blocking write client writing by the write service
+func newWriteAPI(service *iwrite.Service, writeOptions *write.Options) *WriteAPIImpl {
+	pendingBufferSize := writeOptions.PendingBufferSize()
+	if pendingBufferSize == 0 && writeOptions.OverflowPolicy() != write.OverflowBlock {
+		pendingBufferSize = writeOptions.BatchSize()
+	}
 	w := &WriteAPIImpl{
-		service:      iwrite.NewService(org, bucket, service, writeOptions),
+		service:      service,
 		errCh:        make(chan error, 1),
 		writeBuffer:  make([]string, 0, writeOptions.BatchSize()+1),
 		writeCh:      make(chan *iwrite.Batch),
-		bufferCh:     make(chan string),
+		bufferCh:     make(chan string, pendingBufferSize),
 		bufferStop:   make(chan struct{}),
 		writeStop:    make(chan struct{}),
 		bufferFlush:  make(chan struct{}),
@@ -87,13 +140,33 @@ func NewWriteAPI(org string, bucket string, service http2.Service, writeOptions
 		writeOptions: writeOptions,
 		closingMu:    &sync.Mutex{},
 	}
+	w.inFlightDone = sync.NewCond(&w.inFlightMu)
+	if err := w.service.OpenRetryQueue(); err != nil {
+		log.Errorf("Cannot open persistent retry queue, using in-memory queue: %s", err.Error())
+	}
+	if writeOptions.WriteWorkers() > 1 && writeOptions.PreserveSeriesOrder() {
+		w.workerChs = make([]chan *iwrite.Batch, writeOptions.WriteWorkers())
+		for i := range w.workerChs {
+			w.workerChs[i] = make(chan *iwrite.Batch)
+		}
+	}
 
 	go w.bufferProc()
-	go w.writeProc()
+	for i := 0; i < w.writeWorkers(); i++ {
+		go w.writeProc(i)
+	}
 
 	return w
 }
 
+// writeWorkers returns number of write procs
+func (w *WriteAPIImpl) writeWorkers() int {
+	if w.writeOptions.WriteWorkers() == 0 {
+		return 1
+	}
+	return int(w.writeOptions.WriteWorkers())
+}
+
 // SetWriteFailedCallback sets callback allowing custom handling of failed writes.
 // If callback returns true, failed batch will be retried, otherwise discarded.
 func (w *WriteAPIImpl) SetWriteFailedCallback(cb WriteFailedCallback) {
@@ -137,6 +210,29 @@ func (w *WriteAPIImpl) waitForFlushing() {
 		log.Info("Waiting buffer is flushed")
 		<-time.After(time.Millisecond)
 	}
+	w.waitInFlight()
+}
+
+// addInFlight changes number of batches sent to write workers and not written yet by delta
+func (w *WriteAPIImpl) addInFlight(delta int) {
+	w.inFlightMu.Lock()
+	defer w.inFlightMu.Unlock()
+	w.inFlight += delta
+	if w.inFlight == 0 {
+		w.inFlightDone.Broadcast()
+	}
+}
+
+// waitInFlight waits until all batches sent to write workers are written
+func (w *WriteAPIImpl) waitInFlight() {
+	w.inFlightMu.Lock()
+	defer w.inFlightMu.Unlock()
+	if w.inFlight > 0 {
+		log.Info("Waiting batches are written")
+	}
+	for w.inFlight > 0 {
+		w.inFlightDone.Wait()
+	}
 }
 
 func (w *WriteAPIImpl) bufferProc() {
@@ -146,16 +242,15 @@ x:
 	for {
 		select {
 		case line := <-w.bufferCh:
-			w.writeBuffer = append(w.writeBuffer, line)
-			if len(w.writeBuffer) == int(w.writeOptions.BatchSize()) {
-				w.flushBuffer()
-			}
+			w.addToBuffer(line)
 		case <-ticker.C:
 			w.flushBuffer()
 		case <-w.bufferFlush:
+			w.drainPending()
 			w.flushBuffer()
 		case <-w.bufferStop:
 			ticker.Stop()
+			w.drainPending()
 			w.flushBuffer()
 			break x
 		case buffInfo := <-w.bufferInfoCh:
@@ -167,13 +262,77 @@ x:
 	w.doneCh <- struct{}{}
 }
 
+// addToBuffer adds line into the buffer and sends the buffer when it reaches the batch size or the maximum batch bytes
+func (w *WriteAPIImpl) addToBuffer(line string) {
+	maxBytes := int(w.writeOptions.MaxBatchBytes())
+	if maxBytes > 0 && len(w.writeBuffer) > 0 && w.writeBufferBytes+len(line) > maxBytes {
+		w.flushBuffer()
+	}
+	w.writeBuffer = append(w.writeBuffer, line)
+	w.writeBufferBytes += len(line)
+	if len(w.writeBuffer) == int(w.writeOptions.BatchSize()) || (maxBytes > 0 && w.writeBufferBytes >= maxBytes) {
+		w.flushBuffer()
+	}
+}
+
+// drainPending moves points waiting for processing into the buffer
+func (w *WriteAPIImpl) drainPending() {
+	for {
+		select {
+		case line := <-w.bufferCh:
+			w.addToBuffer(line)
+		default:
+			return
+		}
+	}
+}
+
 func (w *WriteAPIImpl) flushBuffer() {
 	if len(w.writeBuffer) > 0 {
 		log.Info("sending batch")
-		batch := iwrite.NewBatch(buffer(w.writeBuffer), w.writeOptions.MaxRetryTime())
-		w.writeCh <- batch
+		if w.workerChs != nil {
+			w.sendPartitioned()
+		} else {
+			w.send(w.writeCh, buffer(w.writeBuffer))
+		}
 		w.writeBuffer = w.writeBuffer[:0]
+		w.writeBufferBytes = 0
+	}
+}
+
+// sendPartitioned splits the buffer by series, so that points of a series are always sent to the same write worker
+func (w *WriteAPIImpl) sendPartitioned() {
+	parts := make([][]string, len(w.workerChs))
+	for _, line := range w.writeBuffer {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(seriesKey(line)))
+		i := h.Sum32() % uint32(len(parts))
+		parts[i] = append(parts[i], line)
+	}
+	for i, lines := range parts {
+		if len(lines) > 0 {
+			w.send(w.workerChs[i], buffer(lines))
+		}
+	}
+}
+
+// send sends batch to a write worker
+func (w *WriteAPIImpl) send(ch chan *iwrite.Batch, data string) {
+	w.addInFlight(1)
+	ch <- iwrite.NewBatch(data, w.writeOptions.MaxRetryTime())
+}
+
+// seriesKey returns measurement and tags part of the line protocol line
+func seriesKey(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ' ':
+			return line[:i]
+		}
 	}
+	return line
 }
 func (w *WriteAPIImpl) isErrChanRead() bool {
 	return atomic.LoadInt32(&w.isErrChReader) > 0
@@ -183,25 +342,28 @@ func (w *WriteAPIImpl) setErrChanRead() {
 	atomic.StoreInt32(&w.isErrChReader, 1)
 }
 
-func (w *WriteAPIImpl) writeProc() {
+// writeProc writes batches, it is started for each write worker
+func (w *WriteAPIImpl) writeProc(worker int) {
 	log.Info("Write proc started")
+	writeCh := w.writeCh
+	if w.workerChs != nil {
+		writeCh = w.workerChs[worker]
+	}
+	if worker == 0 && w.service.HasPendingBatches() {
+		log.Info("Write proc: writing batches from persistent retry queue")
+		w.handleWrite(nil)
+	}
 x:
 	for {
 		select {
-		case batch := <-w.writeCh:
-			err := w.service.HandleWrite(context.Background(), batch)
-			if err != nil && w.isErrChanRead() {
-				select {
-				case w.errCh <- err:
-				default:
-					log.Warn("Cannot write error to error channel, it is not read")
-				}
-			}
+		case batch := <-writeCh:
+			w.handleWrite(batch)
+			w.addInFlight(-1)
 		case <-w.writeStop:
 			log.Info("Write proc: received stop")
 			break x
 		case buffInfo := <-w.writeInfoCh:
-			buffInfo.writeBuffLen = len(w.writeCh)
+			buffInfo.writeBuffLen = len(writeCh)
 			w.writeInfoCh <- buffInfo
 		}
 	}
@@ -209,6 +371,24 @@ x:
 	w.doneCh <- struct{}{}
 }
 
+// handleWrite writes batch using write service and reports error
+func (w *WriteAPIImpl) handleWrite(batch *iwrite.Batch) {
+	if err := w.service.HandleWrite(context.Background(), batch); err != nil {
+		w.reportError(err)
+	}
+}
+
+// reportError sends error to the errors channel, if it is read
+func (w *WriteAPIImpl) reportError(err error) {
+	if w.isErrChanRead() {
+		select {
+		case w.errCh <- err:
+		default:
+			log.Warn("Cannot write error to error channel, it is not read")
+		}
+	}
+}
+
 // Close finishes outstanding write operations,
 // stop background routines and closes all channels
 func (w *WriteAPIImpl) Close() {
@@ -225,11 +405,20 @@ func (w *WriteAPIImpl) Close() {
 		close(w.bufferFlush)
 		close(w.bufferCh)
 
-		// stop and wait for write proc
+		// stop and wait for write procs
 		close(w.writeStop)
-		<-w.doneCh
+		for i := 0; i < w.writeWorkers(); i++ {
+			<-w.doneCh
+		}
+
+		if err := w.service.Close(); err != nil {
+			log.Errorf("Error closing retry queue: %s", err.Error())
+		}
 
 		close(w.writeCh)
+		for _, ch := range w.workerChs {
+			close(ch)
+		}
 		close(w.writeInfoCh)
 		close(w.bufferInfoCh)
 		w.writeCh = nil
@@ -243,24 +432,152 @@ func (w *WriteAPIImpl) Close() {
 // WriteRecord adds record into the buffer which is sent on the background when it reaches the batch size.
 // Blocking alternative is available in the WriteAPIBlocking interface
 func (w *WriteAPIImpl) WriteRecord(line string) {
+	if _, err := w.service.ValidateRecords(line); err != nil {
+		log.Errorf("record validation error: %s\n", err.Error())
+		w.reportError(err)
+		return
+	}
+	b := []byte(line)
+	b = append(b, 0xa)
+	w.enqueue(string(b))
+}
+
+// TryWriteRecord adds line protocol record into the buffer only if it can be done without waiting.
+// It returns ErrBufferFull if the buffer cannot accept the record, regardless of the overflow policy.
+// Record rejected by validation is reported as *write.ValidationError.
+func (w *WriteAPIImpl) TryWriteRecord(line string) error {
+	if _, err := w.service.ValidateRecords(line); err != nil {
+		return err
+	}
 	b := []byte(line)
 	b = append(b, 0xa)
-	w.bufferCh <- string(b)
+	return w.tryEnqueue(string(b))
 }
 
 // WritePoint writes asynchronously Point into bucket.
 // WritePoint adds Point into the buffer which is sent on the background when it reaches the batch size.
 // Blocking alternative is available in the WriteAPIBlocking interface
 func (w *WriteAPIImpl) WritePoint(point *write.Point) {
-	line, err := w.service.EncodePoints(point)
+	line, err := w.service.EncodePoints(w.service.ProcessPoints(point)...)
 	if err != nil {
 		log.Errorf("point encoding error: %s\n", err.Error())
-		if w.errCh != nil {
-			w.errCh <- err
+		w.reportError(err)
+	} else if line != "" && w.enqueue(line) {
+		atomic.AddUint64(&w.pointsAccepted, 1)
+	}
+}
+
+// TryWritePoint adds Point into the buffer only if it can be done without waiting.
+// It returns ErrBufferFull if the buffer cannot accept the point, regardless of the overflow policy.
+func (w *WriteAPIImpl) TryWritePoint(point *write.Point) error {
+	line, err := w.service.EncodePoints(w.service.ProcessPoints(point)...)
+	if err != nil || line == "" {
+		return err
+	}
+	if err := w.tryEnqueue(line); err != nil {
+		return err
+	}
+	atomic.AddUint64(&w.pointsAccepted, 1)
+	return nil
+}
+
+// DroppedPoints returns number of points dropped by the overflow policy so far
+func (w *WriteAPIImpl) DroppedPoints() uint64 {
+	return atomic.LoadUint64(&w.droppedPoints)
+}
+
+// Stats returns statistics of writing, e.g. number of accepted and written lines, retries or request latencies
+func (w *WriteAPIImpl) Stats() write.Stats {
+	stats := write.Stats{
+		PointsAccepted: atomic.LoadUint64(&w.pointsAccepted),
+		LinesAccepted:  atomic.LoadUint64(&w.linesAccepted),
+		BytesAccepted:  atomic.LoadUint64(&w.bytesAccepted),
+		DroppedPoints:  atomic.LoadUint64(&w.droppedPoints),
+	}
+	w.service.Stats(&stats)
+	return stats
+}
+
+// CircuitState returns state of the circuit breaker, it is always CircuitClosed when the circuit breaker is disabled
+func (w *WriteAPIImpl) CircuitState() write.CircuitState {
+	return w.service.CircuitState()
+}
+
+func (w *WriteAPIImpl) tryEnqueue(line string) error {
+	select {
+	case w.bufferCh <- line:
+		w.accepted(line)
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// enqueue adds line into the buffer, applying the overflow policy when the buffer cannot accept it.
+// It returns false if the line was dropped.
+func (w *WriteAPIImpl) enqueue(line string) bool {
+	switch w.writeOptions.OverflowPolicy() {
+	case write.OverflowBlockWithTimeout:
+		select {
+		case w.bufferCh <- line:
+		default:
+			timer := time.NewTimer(time.Duration(w.writeOptions.OverflowTimeout()) * time.Millisecond)
+			defer timer.Stop()
+			select {
+			case w.bufferCh <- line:
+			case <-timer.C:
+				w.drop(line, "Write buffer full, dropping point after timeout")
+				w.reportError(ErrBufferFull)
+				return false
+			}
+		}
+	case write.OverflowDropNewest:
+		if w.tryEnqueue(line) != nil {
+			w.drop(line, "Write buffer full, dropping newest point")
+			return false
+		}
+		return true
+	case write.OverflowDropOldest:
+		for w.tryEnqueue(line) != nil {
+			select {
+			case oldest := <-w.bufferCh:
+				// the oldest line was counted when it was accepted
+				atomic.AddUint64(&w.linesAccepted, ^uint64(0))
+				atomic.AddUint64(&w.bytesAccepted, ^uint64(len(oldest)-1))
+				w.drop(oldest, "Write buffer full, dropping oldest point")
+			default:
+				// the writer took lines from the buffer meanwhile, let it proceed and try again
+				runtime.Gosched()
+			}
+		}
+		return true
+	case write.OverflowError:
+		if w.tryEnqueue(line) != nil {
+			w.drop(line, "Write buffer full, dropping point")
+			w.reportError(ErrBufferFull)
+			return false
 		}
-	} else {
+		return true
+	default:
 		w.bufferCh <- line
 	}
+	w.accepted(line)
+	return true
+}
+
+// accepted counts line accepted into the buffer
+func (w *WriteAPIImpl) accepted(line string) {
+	atomic.AddUint64(&w.linesAccepted, 1)
+	atomic.AddUint64(&w.bytesAccepted, uint64(len(line)))
+}
+
+// drop counts line dropped by the overflow policy and passes it to the dead-letter handler, if set
+func (w *WriteAPIImpl) drop(line string, msg string) {
+	atomic.AddUint64(&w.droppedPoints, 1)
+	log.Debug(msg)
+	if h := w.writeOptions.DeadLetterHandler(); h != nil {
+		h.HandleDiscarded(line, write.DiscardOverflow, nil)
+	}
 }
 
 func buffer(lines []string) string {
@@ -0,0 +1,39 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import "errors"
+
+// CircuitState is state of the circuit breaker of the non-blocking WriteAPI
+type CircuitState int
+
+const (
+	// CircuitClosed means the server is considered healthy and batches are written
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the server is considered unhealthy, batches are kept in the retry queue without sending
+	CircuitOpen
+	// CircuitHalfOpen means a single probe request is allowed to check whether the server recovered
+	CircuitHalfOpen
+)
+
+// String returns name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerCallback is synchronously notified when the circuit breaker changes its state
+type CircuitBreakerCallback func(from, to CircuitState)
+
+// ErrCircuitOpen is reported by the WriteAPI when a batch is not written, because the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open, server is unhealthy")
@@ -0,0 +1,456 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVError describes an invalid row of annotated CSV
+type CSVError struct {
+	// Line is number of the line where the row starts, starting from 1
+	Line int
+	// Msg describes the error
+	Msg string
+}
+
+// Error fulfils error interface
+func (e *CSVError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// column roles
+const (
+	csvIgnored = iota
+	csvMeasurement
+	csvTag
+	csvField
+	csvTime
+	csvFieldKey
+	csvFieldValue
+)
+
+// csvColumn describes a column of annotated CSV, or a constant
+type csvColumn struct {
+	label        string
+	datatype     string
+	format       string
+	group        bool
+	defaultValue string
+	role         int
+	// index is index of the column in a row, -1 for a constant
+	index int
+	// value of a constant
+	value string
+}
+
+// AnnotatedCSVReader reads points from annotated CSV, as returned by queries or accepted by the influx write command.
+//
+// Columns are mapped according to the #datatype annotation:
+//   - measurement, tag and field set measurement, tags and string fields
+//   - dateTime sets timestamp, dateTime:RFC3339, dateTime:RFC3339Nano and dateTime:number (nanoseconds) formats are supported,
+//     other formats are Go time layouts, e.g. dateTime:2006-01-02. Timestamp without zone is in the location set by #timezone
+//   - double, long, unsignedLong, boolean, string and base64Binary set fields of the respective type
+//   - duration sets integer field holding the duration in nanoseconds, e.g. 1m30s as 90000000000i
+//   - ignored (or ignore) columns are skipped
+//
+// Columns of query results are recognized by their names: _measurement, _time, _field and _value,
+// string columns of the group key become tags, _start, _stop, result and table columns are skipped.
+//
+// Supported annotations are #datatype, #group, #default, #timezone (e.g. #timezone -0600 or #timezone Europe/Prague)
+// and #constant, which adds a column with a fixed value to all rows: #constant measurement,cpu or #constant tag,host,server01.
+// The first value of an annotation can follow its name in the first cell, e.g. #datatype measurement,tag,double.
+// Other rows starting with # are comments. Annotations are reset by annotations following data rows,
+// which start a new table.
+type AnnotatedCSVReader struct {
+	reader *csv.Reader
+	// annotations of the next table
+	datatypes []string
+	groups    []string
+	defaults  []string
+	constants []*csvColumn
+	timezone  *time.Location
+	// columns of the current table, nil before the header row
+	columns     []*csvColumn
+	annotations bool
+	records     int
+	line        int
+	err         error
+}
+
+// NewAnnotatedCSVReader creates reader of points from annotated CSV read from r
+func NewAnnotatedCSVReader(r io.Reader) *AnnotatedCSVReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &AnnotatedCSVReader{reader: reader, timezone: time.UTC}
+}
+
+// Records returns number of CSV records read so far, including annotations and headers
+func (c *AnnotatedCSVReader) Records() int {
+	return c.records
+}
+
+// Line returns number of the line where the last read record starts
+func (c *AnnotatedCSVReader) Line() int {
+	return c.line
+}
+
+// Next returns the next point. It returns io.EOF when there are no more points.
+// Invalid row is reported as *CSVError, reading can continue by calling Next again.
+// Other errors, e.g. invalid annotations or errors of the reader, stop reading.
+func (c *AnnotatedCSVReader) Next() (*Point, error) {
+	for {
+		if c.err != nil {
+			return nil, c.err
+		}
+		row, err := c.reader.Read()
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				c.records++
+				c.line = perr.StartLine
+				return nil, &CSVError{Line: perr.StartLine, Msg: perr.Err.Error()}
+			}
+			c.err = err
+			return nil, err
+		}
+		c.records++
+		c.line, _ = c.reader.FieldPos(0)
+		if len(row) == 0 || (len(row) == 1 && strings.TrimSpace(row[0]) == "") {
+			continue
+		}
+		if strings.HasPrefix(row[0], "#") {
+			if err := c.annotation(row); err != nil {
+				c.err = err
+				return nil, err
+			}
+			continue
+		}
+		if c.columns == nil {
+			if err := c.header(row); err != nil {
+				c.err = err
+				return nil, err
+			}
+			continue
+		}
+		return c.point(row)
+	}
+}
+
+// annotation processes annotation row
+func (c *AnnotatedCSVReader) annotation(row []string) error {
+	name := row[0]
+	var values []string
+	if i := strings.IndexAny(name, " \t"); i > 0 {
+		values = append(values, strings.TrimSpace(name[i:]))
+		name = name[:i]
+	} else {
+		values = append(values, "")
+	}
+	values = append(values, row[1:]...)
+	switch name {
+	case "#datatype", "#group", "#default", "#timezone", "#constant":
+		if !c.annotations {
+			// annotations of a new table
+			c.datatypes, c.groups, c.defaults, c.constants = nil, nil, nil, nil
+			c.timezone = time.UTC
+			c.columns = nil
+			c.annotations = true
+		}
+	default:
+		// comment
+		return nil
+	}
+	switch name {
+	case "#datatype":
+		c.datatypes = values
+	case "#group":
+		c.groups = values
+	case "#default":
+		c.defaults = values
+	case "#timezone":
+		tz, err := parseTimezone(values[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", c.line, err)
+		}
+		c.timezone = tz
+	case "#constant":
+		values = nonEmpty(values)
+		if len(values) < 2 || len(values) > 3 {
+			return fmt.Errorf("line %d: #constant requires data type, optional label and value", c.line)
+		}
+		col := &csvColumn{index: -1, value: values[len(values)-1]}
+		col.datatype, col.format = splitDatatype(values[0])
+		col.label = col.datatype
+		if len(values) == 3 {
+			col.label = values[1]
+		}
+		if err := col.setRole(false); err != nil {
+			return fmt.Errorf("line %d: %w", c.line, err)
+		}
+		c.constants = append(c.constants, col)
+	}
+	return nil
+}
+
+// header creates columns from the header row and annotations
+func (c *AnnotatedCSVReader) header(row []string) error {
+	c.annotations = false
+	queryResult := row[0] == "" && len(c.datatypes) > 0 && c.datatypes[0] == ""
+	columns := make([]*csvColumn, 0, len(row)+len(c.constants))
+	for i, label := range row {
+		col := &csvColumn{label: strings.TrimSpace(label), index: i}
+		col.datatype, col.format = splitDatatype(item(c.datatypes, i))
+		col.group = item(c.groups, i) == "true"
+		col.defaultValue = item(c.defaults, i)
+		if queryResult && (col.label == "result" || col.label == "table") {
+			continue
+		}
+		if err := col.setRole(true); err != nil {
+			return fmt.Errorf("line %d: %w", c.line, err)
+		}
+		if col.role != csvIgnored {
+			columns = append(columns, col)
+		}
+	}
+	c.columns = append(columns, c.constants...)
+	return nil
+}
+
+// point creates point from a data row
+func (c *AnnotatedCSVReader) point(row []string) (*Point, error) {
+	point := NewPointWithMeasurement("")
+	var fieldKey string
+	var fieldValue interface{}
+	for _, col := range c.columns {
+		value := col.value
+		if col.index >= 0 {
+			value = item(row, col.index)
+			if value == "" {
+				value = col.defaultValue
+			}
+		}
+		if value == "" {
+			continue
+		}
+		var err error
+		switch col.role {
+		case csvMeasurement:
+			point.measurement = value
+		case csvTag:
+			point.AddTag(col.label, value)
+		case csvTime:
+			var t time.Time
+			t, err = parseDateTime(value, col.format, c.timezone)
+			point.SetTime(t)
+		case csvFieldKey:
+			fieldKey = value
+		case csvFieldValue:
+			fieldValue, err = csvValue(value, col.datatype)
+		case csvField:
+			var v interface{}
+			v, err = csvValue(value, col.datatype)
+			point.AddField(col.label, v)
+		}
+		if err != nil {
+			return nil, &CSVError{Line: c.line, Msg: fmt.Sprintf("column %s: %v", col.label, err)}
+		}
+	}
+	if fieldKey != "" && fieldValue != nil {
+		point.AddField(fieldKey, fieldValue)
+	}
+	if point.Name() == "" {
+		return nil, &CSVError{Line: c.line, Msg: "missing measurement"}
+	}
+	if len(point.FieldList()) == 0 {
+		return nil, &CSVError{Line: c.line, Msg: "no field"}
+	}
+	return point, nil
+}
+
+// setRole determines role of the column from its data type and label.
+// Labels of query result columns are recognized only for columns of a header.
+func (col *csvColumn) setRole(header bool) error {
+	switch {
+	case col.label == "" && col.index >= 0:
+		col.role = csvIgnored
+	case col.datatype == "ignored" || col.datatype == "ignore":
+		col.role = csvIgnored
+	case col.datatype == "measurement":
+		col.role = csvMeasurement
+	case col.datatype == "tag":
+		col.role = csvTag
+	case col.datatype == "dateTime":
+		col.role = csvTime
+	case header && col.label == "_measurement":
+		col.role = csvMeasurement
+	case header && col.label == "_time":
+		col.role = csvTime
+	case header && col.label == "_field":
+		col.role = csvFieldKey
+	case header && col.label == "_value":
+		col.role = csvFieldValue
+	case header && (col.label == "_start" || col.label == "_stop"):
+		col.role = csvIgnored
+	case col.datatype == "string" && col.group:
+		col.role = csvTag
+	case col.datatype == "", col.datatype == "field":
+		col.datatype = "string"
+		col.role = csvField
+	case csvDatatypes[col.datatype]:
+		col.role = csvField
+	default:
+		return fmt.Errorf("column %s: unsupported data type %s", col.label, col.datatype)
+	}
+	return nil
+}
+
+// csvDatatypes holds data types of field values
+var csvDatatypes = map[string]bool{
+	"string":       true,
+	"double":       true,
+	"long":         true,
+	"unsignedLong": true,
+	"boolean":      true,
+	"duration":     true,
+	"base64Binary": true,
+}
+
+// csvValue converts value according to CSV data type
+func csvValue(value, datatype string) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch datatype {
+	case "string":
+		v = value
+	case "double":
+		v, err = strconv.ParseFloat(value, 64)
+	case "long":
+		v, err = strconv.ParseInt(value, 10, 64)
+	case "unsignedLong":
+		v, err = strconv.ParseUint(value, 10, 64)
+	case "boolean":
+		switch value {
+		case "true", "True", "TRUE", "t", "T", "1":
+			v = true
+		case "false", "False", "FALSE", "f", "F", "0":
+			v = false
+		default:
+			err = strconv.ErrSyntax
+		}
+	case "duration":
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		v = int64(d)
+	case "base64Binary":
+		v, err = base64.StdEncoding.DecodeString(value)
+	default:
+		return nil, fmt.Errorf("unsupported data type %s", datatype)
+	}
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = numErr.Err
+		}
+		return nil, fmt.Errorf("invalid %s value %q: %w", datatype, value, err)
+	}
+	return v, nil
+}
+
+// parseDateTime parses timestamp in the format of dateTime data type
+func parseDateTime(value, format string, tz *time.Location) (time.Time, error) {
+	switch format {
+	case "":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(0, n), nil
+		}
+		return time.Parse(time.RFC3339Nano, value)
+	case "RFC3339", "RFC3339Nano":
+		return time.Parse(time.RFC3339Nano, value)
+	case "number":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+		}
+		return time.Unix(0, n), nil
+	default:
+		return time.ParseInLocation(format, value, tz)
+	}
+}
+
+// parseTimezone parses location given by name or offset, e.g. -0600
+func parseTimezone(value string) (*time.Location, error) {
+	if len(value) == 5 && (value[0] == '+' || value[0] == '-') {
+		hours, herr := strconv.Atoi(value[1:3])
+		minutes, merr := strconv.Atoi(value[3:])
+		if herr == nil && merr == nil {
+			offset := (hours*60 + minutes) * 60
+			if value[0] == '-' {
+				offset = -offset
+			}
+			return time.FixedZone(value, offset), nil
+		}
+	}
+	if value == "" {
+		return time.UTC, nil
+	}
+	tz, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", value)
+	}
+	return tz, nil
+}
+
+// splitDatatype splits data type from its format, e.g. dateTime:RFC3339
+func splitDatatype(datatype string) (string, string) {
+	datatype = strings.TrimSpace(datatype)
+	if i := strings.IndexByte(datatype, ':'); i >= 0 {
+		return datatype[:i], datatype[i+1:]
+	}
+	return datatype, ""
+}
+
+// item returns i-th item of values or empty string
+func item(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// nonEmpty filters empty values
+func nonEmpty(values []string) []string {
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// ParseAnnotatedCSV parses all points from annotated CSV, it stops at the first invalid row
+func ParseAnnotatedCSV(data string) ([]*Point, error) {
+	var points []*Point
+	reader := NewAnnotatedCSVReader(strings.NewReader(data))
+	for {
+		point, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return points, nil
+			}
+			return points, err
+		}
+		points = append(points, point)
+	}
+}
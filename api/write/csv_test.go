@@ -0,0 +1,148 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write_test
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+func TestParseAnnotatedCSVExtended(t *testing.T) {
+	points, err := write.ParseAnnotatedCSV(`#constant measurement,cpu
+#constant tag,region,us-west
+#timezone -0600
+#datatype tag,double,long,boolean,dateTime:2006-01-02 15:04:05,ignored,unsignedLong
+#default ,,,,,,7
+host,usage,count,ok,time,note,total
+# comment
+a,0.5,10,true,2020-01-01 10:00:00,x,
+b,,,false,2020-01-01 11:00:00,y,8
+`)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	tz := time.FixedZone("", -6*3600)
+	assert.Equal(t, "cpu,host=a,region=us-west count=10i,ok=true,total=7u,usage=0.5 "+
+		strconv.FormatInt(time.Date(2020, 1, 1, 10, 0, 0, 0, tz).UnixNano(), 10)+"\n",
+		write.PointToLineProtocol(points[0].SortTags().SortFields(), time.Nanosecond))
+	assert.Equal(t, "cpu,host=b,region=us-west ok=false,total=8u "+
+		strconv.FormatInt(time.Date(2020, 1, 1, 11, 0, 0, 0, tz).UnixNano(), 10)+"\n",
+		write.PointToLineProtocol(points[1].SortTags().SortFields(), time.Nanosecond))
+}
+
+func TestParseAnnotatedCSVDuration(t *testing.T) {
+	points, err := write.ParseAnnotatedCSV(`#constant measurement,job
+#datatype tag,duration,dateTime:number
+name,elapsed,time
+backup,1m30s,1600000000000000000
+`)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, "job,name=backup elapsed=90000000000i 1600000000000000000\n", write.PointToLineProtocol(points[0], time.Nanosecond))
+}
+
+func TestParseAnnotatedCSVMeasurementColumn(t *testing.T) {
+	points, err := write.ParseAnnotatedCSV(`#datatype measurement,tag,field,double,dateTime:RFC3339,dateTime:number
+m,t,s,d,ignored,time
+weather,"a,b","hello, world",1.5,,1600000000000000000
+weather,,"",2,,1600000000000000001
+`)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, `weather,t=a\,b d=1.5,s="hello, world" 1600000000000000000`+"\n", write.PointToLineProtocol(points[0].SortFields(), time.Nanosecond))
+	assert.Equal(t, "weather", points[1].Name())
+	assert.Len(t, points[1].TagList(), 0)
+	require.Len(t, points[1].FieldList(), 1)
+	assert.Equal(t, 2.0, points[1].FieldList()[0].Value)
+	assert.Equal(t, time.Unix(0, 1600000000000000001), points[1].Time())
+}
+
+func TestParseAnnotatedCSVQueryResult(t *testing.T) {
+	points, err := write.ParseAnnotatedCSV(`#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string,string
+#group,false,false,true,true,false,false,true,true,true,true
+#default,_result,,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,a,b
+,,0,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T10:34:08.135814545Z,1.4,f,test,1,adsfasdf
+,,0,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.850214724Z,6.6,f,test,1,adsfasdf
+
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string,string,string
+#group,false,false,true,true,false,false,true,true,true
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,a
+,,1,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T10:34:08.135814545Z,4,i,test,1
+`)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, "test,a=1,b=adsfasdf f=1.4 1582022048135814545\n", write.PointToLineProtocol(points[0], time.Nanosecond))
+	assert.Equal(t, "test,a=1,b=adsfasdf f=6.6 1582063724850214724\n", write.PointToLineProtocol(points[1], time.Nanosecond))
+	assert.Equal(t, "test,a=1 i=4i 1582022048135814545\n", write.PointToLineProtocol(points[2], time.Nanosecond))
+}
+
+func TestAnnotatedCSVReaderRowErrors(t *testing.T) {
+	reader := write.NewAnnotatedCSVReader(strings.NewReader(`#datatype measurement,long,boolean
+m,i,b
+m,1,true
+m,x,true
+,2,true
+m,,
+m,3,yes
+m,"4,false
+`))
+	p, err := reader.Next()
+	require.NoError(t, err)
+	require.Len(t, p.FieldList(), 2)
+	assert.Equal(t, int64(1), p.FieldList()[0].Value)
+	assert.Equal(t, true, p.FieldList()[1].Value)
+	assert.Equal(t, 3, reader.Line())
+
+	for _, msg := range []string{
+		`line 4: column i: invalid long value "x": invalid syntax`,
+		"line 5: missing measurement",
+		"line 6: no field",
+		`line 7: column b: invalid boolean value "yes": invalid syntax`,
+	} {
+		_, err = reader.Next()
+		var csvErr *write.CSVError
+		require.True(t, errors.As(err, &csvErr), err)
+		assert.EqualError(t, err, msg)
+	}
+	_, err = reader.Next()
+	var csvErr *write.CSVError
+	require.True(t, errors.As(err, &csvErr), err)
+	assert.Equal(t, 8, csvErr.Line)
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 8, reader.Records())
+}
+
+func TestAnnotatedCSVReaderErrors(t *testing.T) {
+	testCases := []struct {
+		csv string
+		err string
+	}{
+		{"#datatype measurement,float\nm,f\nm,1", "line 2: column f: unsupported data type float"},
+		{"#timezone Mars/Olympus\n#datatype measurement,long\nm,f\nm,1", `line 1: invalid timezone "Mars/Olympus"`},
+		{"#constant tag\n#datatype measurement,long\nm,f\nm,1", "line 1: #constant requires data type, optional label and value"},
+	}
+	for _, tc := range testCases {
+		reader := write.NewAnnotatedCSVReader(strings.NewReader(tc.csv))
+		_, err := reader.Next()
+		assert.EqualError(t, err, tc.err)
+		var csvErr *write.CSVError
+		assert.False(t, errors.As(err, &csvErr))
+		// reading stops
+		_, err2 := reader.Next()
+		assert.Equal(t, err, err2)
+	}
+}
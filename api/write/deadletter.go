@@ -0,0 +1,97 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/internal/log"
+)
+
+// DiscardReason describes why data were discarded
+type DiscardReason string
+
+const (
+	// DiscardExpired means the batch was waiting in the retry queue longer than MaxRetryTime
+	DiscardExpired DiscardReason = "expired"
+	// DiscardMaxRetries means writing of the batch failed MaxRetries times
+	DiscardMaxRetries DiscardReason = "max retries"
+	// DiscardRetryBufferFull means the batch was removed from the full retry queue to make room for a newer batch
+	DiscardRetryBufferFull DiscardReason = "retry buffer full"
+	// DiscardRejectedByCallback means WriteFailedCallback decided not to retry the batch
+	DiscardRejectedByCallback DiscardReason = "rejected by callback"
+	// DiscardWriteFailed means the batch failed with an error, which is not retried
+	DiscardWriteFailed DiscardReason = "write failed"
+	// DiscardRejectedData means the server rejected lines because of invalid data, only rejected lines are discarded
+	DiscardRejectedData DiscardReason = "rejected data"
+	// DiscardOverflow means the point was dropped by the overflow policy
+	DiscardOverflow DiscardReason = "overflow"
+)
+
+// DeadLetterHandler receives data discarded by the non-blocking WriteAPI
+type DeadLetterHandler interface {
+	// HandleDiscarded is synchronously called with line protocol of discarded data,
+	// the reason and the last write error, which can be nil.
+	HandleDiscarded(lines string, reason DiscardReason, err error)
+}
+
+// DeadLetterHandlerFunc is a function implementing DeadLetterHandler
+type DeadLetterHandlerFunc func(lines string, reason DiscardReason, err error)
+
+// HandleDiscarded calls f(lines, reason, err)
+func (f DeadLetterHandlerFunc) HandleDiscarded(lines string, reason DiscardReason, err error) {
+	f(lines, reason, err)
+}
+
+// FileDeadLetterHandler appends discarded data to a file as line protocol, so it can be written again later,
+// e.g. using the influx CLI. Each discarded batch is preceded by a comment line with time, reason and error.
+// FileDeadLetterHandler can be used concurrently.
+type FileDeadLetterHandler struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+// NewFileDeadLetterHandler creates FileDeadLetterHandler appending to the file at path.
+// The file is created if it doesn't exist.
+func NewFileDeadLetterHandler(path string) (*FileDeadLetterHandler, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterHandler{file: f}, nil
+}
+
+// HandleDiscarded appends lines to the file
+func (h *FileDeadLetterHandler) HandleDiscarded(lines string, reason DiscardReason, err error) {
+	var sb strings.Builder
+	sb.WriteString("# discarded ")
+	sb.WriteString(time.Now().UTC().Format(time.RFC3339Nano))
+	sb.WriteString(" reason=")
+	sb.WriteString(string(reason))
+	if err != nil {
+		sb.WriteString(" error=")
+		sb.WriteString(strings.ReplaceAll(err.Error(), "\n", " "))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(lines)
+	if !strings.HasSuffix(lines, "\n") {
+		sb.WriteString("\n")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, werr := h.file.WriteString(sb.String()); werr != nil {
+		log.Errorf("Cannot write discarded data to %s: %s", h.file.Name(), werr.Error())
+	}
+}
+
+// Close closes the file
+func (h *FileDeadLetterHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.file.Close()
+}
@@ -0,0 +1,52 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+func TestFileDeadLetterHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "discarded.lp")
+	h, err := write.NewFileDeadLetterHandler(path)
+	require.NoError(t, err)
+
+	h.HandleDiscarded("test,a=1 f=1i 1\ntest,a=2 f=2i 2\n", write.DiscardMaxRetries, errors.New("service\nunavailable"))
+	h.HandleDiscarded("test,a=3 f=3i 3", write.DiscardExpired, nil)
+	require.NoError(t, h.Close())
+
+	// reopening appends
+	h, err = write.NewFileDeadLetterHandler(path)
+	require.NoError(t, err)
+	h.HandleDiscarded("test,a=4 f=4i 4\n", write.DiscardOverflow, nil)
+	require.NoError(t, h.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	require.Len(t, lines, 7)
+	assert.True(t, strings.HasPrefix(lines[0], "# discarded "))
+	assert.True(t, strings.HasSuffix(lines[0], " reason=max retries error=service unavailable"))
+	assert.Equal(t, "test,a=1 f=1i 1", lines[1])
+	assert.Equal(t, "test,a=2 f=2i 2", lines[2])
+	assert.True(t, strings.HasSuffix(lines[3], " reason=expired"))
+	assert.Equal(t, "test,a=3 f=3i 3", lines[4])
+	assert.True(t, strings.HasSuffix(lines[5], " reason=overflow"))
+	assert.Equal(t, "test,a=4 f=4i 4", lines[6])
+}
+
+func TestNewFileDeadLetterHandlerError(t *testing.T) {
+	_, err := write.NewFileDeadLetterHandler(filepath.Join(t.TempDir(), "missing", "discarded.lp"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// RejectedLine describes a line of a batch rejected by the server
+type RejectedLine struct {
+	// Number of the line in the batch, starting from 1
+	Number int
+	// Line holds text of the rejected line
+	Line string
+	// Reason why the server rejected the line
+	Reason string
+}
+
+// WriteError describes a write, which was partially or completely rejected by the server because of invalid data,
+// e.g. a partial write or a line protocol parsing error.
+// Lines holds rejected lines, as far as they can be determined from the server response.
+//
+// WriteError is the nested error of the http.Error returned or reported by write APIs, use errors.As to obtain it:
+//
+//	var writeErr *write.WriteError
+//	if errors.As(err, &writeErr) {
+//		for _, l := range writeErr.Lines {
+//			fmt.Printf("line %d rejected: %s\n", l.Number, l.Reason)
+//		}
+//	}
+type WriteError struct {
+	// StatusCode is HTTP status code of the response
+	StatusCode int
+	// Code is error code sent by the server
+	Code string
+	// Message is error message sent by the server
+	Message string
+	// Dropped is number of points dropped by the server, if reported, otherwise -1
+	Dropped int
+	// Lines holds rejected lines
+	Lines []RejectedLine
+}
+
+// Error fulfils error interface
+func (e *WriteError) Error() string {
+	switch {
+	case e.Code != "" && e.Message != "":
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	case e.Message != "":
+		return e.Message
+	default:
+		return "Unexpected status code " + strconv.Itoa(e.StatusCode)
+	}
+}
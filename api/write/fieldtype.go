@@ -0,0 +1,140 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Line protocol field types, as named by InfluxDB
+const (
+	FieldTypeFloat    = "float"
+	FieldTypeInteger  = "integer"
+	FieldTypeUnsigned = "unsigned"
+	FieldTypeBoolean  = "boolean"
+	FieldTypeString   = "string"
+)
+
+// FieldTypeError describes a field with a value of a type not supported by line protocol,
+// or a field which type differs from the type registered by FieldTypeRegistry.
+type FieldTypeError struct {
+	// Measurement of the point
+	Measurement string
+	// Field is key of the field
+	Field string
+	// Type is Go type of an unsupported value, or line protocol type of the value in case of a conflict
+	Type string
+	// Expected is the line protocol type registered for the field, empty for unsupported value types
+	Expected string
+}
+
+// Error fulfils error interface
+func (e *FieldTypeError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("field '%s' of measurement '%s': unsupported value type %s", e.Field, e.Measurement, e.Type)
+	}
+	return fmt.Sprintf("field '%s' of measurement '%s': type conflict, %s value, but %s expected", e.Field, e.Measurement, e.Type, e.Expected)
+}
+
+// FieldType returns line protocol type of a field value, i.e. one of FieldTypeFloat, FieldTypeInteger, FieldTypeUnsigned,
+// FieldTypeBoolean or FieldTypeString, or empty string if v is not a line protocol value.
+func FieldType(v interface{}) string {
+	switch v.(type) {
+	case float64, float32:
+		return FieldTypeFloat
+	case int64, int, int32, int16, int8:
+		return FieldTypeInteger
+	case uint64, uint, uint32, uint16, uint8:
+		return FieldTypeUnsigned
+	case bool:
+		return FieldTypeBoolean
+	case string, []byte:
+		return FieldTypeString
+	default:
+		return ""
+	}
+}
+
+// fieldKey identifies a field of a measurement
+type fieldKey struct {
+	measurement string
+	field       string
+}
+
+// FieldTypeRegistry remembers types of fields of written points per measurement and detects fields changing their type,
+// which would be rejected by the server as a field type conflict.
+// Type of a field is registered by the first point containing the field.
+// It is used by write APIs when set by Options.SetFieldTypeRegistry and it is safe for concurrent use.
+// Lines written as records are not checked.
+type FieldTypeRegistry struct {
+	mu    sync.RWMutex
+	types map[fieldKey]string
+}
+
+// NewFieldTypeRegistry creates an empty FieldTypeRegistry
+func NewFieldTypeRegistry() *FieldTypeRegistry {
+	return &FieldTypeRegistry{types: make(map[fieldKey]string)}
+}
+
+// Register sets type of field of measurement, overriding the previously registered type.
+// It can be used to preload field types of existing data.
+func (r *FieldTypeRegistry) Register(measurement, field, fieldType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[fieldKey{measurement, field}] = fieldType
+}
+
+// Type returns type registered for field of measurement, or empty string if the field is unknown
+func (r *FieldTypeRegistry) Type(measurement, field string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.types[fieldKey{measurement, field}]
+}
+
+// Check verifies that types of fields of point p match registered types and registers types of new fields.
+// It returns FieldTypeError for the first field of a different type, nothing is registered in such case.
+func (r *FieldTypeRegistry) Check(p *Point) error {
+	fields := p.FieldList()
+	r.mu.RLock()
+	known := true
+	for _, f := range fields {
+		expected, ok := r.types[fieldKey{p.Name(), f.Key}]
+		if !ok {
+			known = false
+			continue
+		}
+		if t := FieldType(f.Value); t != expected {
+			r.mu.RUnlock()
+			return &FieldTypeError{Measurement: p.Name(), Field: f.Key, Type: t, Expected: expected}
+		}
+	}
+	r.mu.RUnlock()
+	if known {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// check again, fields could be registered concurrently
+	for _, f := range fields {
+		if expected, ok := r.types[fieldKey{p.Name(), f.Key}]; ok && FieldType(f.Value) != expected {
+			return &FieldTypeError{Measurement: p.Name(), Field: f.Key, Type: FieldType(f.Value), Expected: expected}
+		}
+	}
+	for _, f := range fields {
+		key := fieldKey{p.Name(), f.Key}
+		if _, ok := r.types[key]; !ok {
+			r.types[key] = FieldType(f.Value)
+		}
+	}
+	return nil
+}
+
+// Reset forgets all registered types
+func (r *FieldTypeRegistry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types = make(map[fieldKey]string)
+}
@@ -0,0 +1,83 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldType(t *testing.T) {
+	assert.Equal(t, FieldTypeFloat, FieldType(1.0))
+	assert.Equal(t, FieldTypeFloat, FieldType(float32(1)))
+	assert.Equal(t, FieldTypeInteger, FieldType(1))
+	assert.Equal(t, FieldTypeInteger, FieldType(int64(1)))
+	assert.Equal(t, FieldTypeUnsigned, FieldType(uint8(1)))
+	assert.Equal(t, FieldTypeBoolean, FieldType(false))
+	assert.Equal(t, FieldTypeString, FieldType("a"))
+	assert.Equal(t, FieldTypeString, FieldType([]byte("a")))
+	assert.Equal(t, "", FieldType(time.Second))
+	assert.Equal(t, "", FieldType(nil))
+}
+
+func TestFieldTypeRegistry(t *testing.T) {
+	r := NewFieldTypeRegistry()
+	require.NoError(t, r.Check(NewPointWithMeasurement("cpu").AddFloatField("usage", 1).AddIntField("cores", 4)))
+	assert.Equal(t, FieldTypeFloat, r.Type("cpu", "usage"))
+	assert.Equal(t, FieldTypeInteger, r.Type("cpu", "cores"))
+	assert.Equal(t, "", r.Type("mem", "usage"))
+
+	// other measurement is independent
+	require.NoError(t, r.Check(NewPointWithMeasurement("mem").AddIntField("usage", 1)))
+
+	err := r.Check(NewPointWithMeasurement("cpu").AddFloatField("usage", 2).AddStringField("state", "ok").AddFloatField("cores", 4))
+	require.Error(t, err)
+	assert.Equal(t, "field 'cores' of measurement 'cpu': type conflict, float value, but integer expected", err.Error())
+	var fieldErr *FieldTypeError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, FieldTypeError{Measurement: "cpu", Field: "cores", Type: FieldTypeFloat, Expected: FieldTypeInteger}, *fieldErr)
+	// nothing is registered from a conflicting point
+	assert.Equal(t, "", r.Type("cpu", "state"))
+
+	require.NoError(t, r.Check(NewPointWithMeasurement("cpu").AddStringField("state", "ok")))
+	assert.Equal(t, FieldTypeString, r.Type("cpu", "state"))
+
+	r.Register("cpu", "state", FieldTypeBoolean)
+	assert.Error(t, r.Check(NewPointWithMeasurement("cpu").AddStringField("state", "ok")))
+
+	r.Reset()
+	assert.Equal(t, "", r.Type("cpu", "usage"))
+	assert.NoError(t, r.Check(NewPointWithMeasurement("cpu").AddStringField("usage", "x")))
+}
+
+func TestFieldTypeRegistryConcurrent(t *testing.T) {
+	r := NewFieldTypeRegistry()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conflicts := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := NewPointWithMeasurement("m")
+			if i%2 == 0 {
+				p.AddIntField("v", int64(i))
+			} else {
+				p.AddFloatField("v", float64(i))
+			}
+			if r.Check(p) != nil {
+				mu.Lock()
+				conflicts++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 25, conflicts)
+}
@@ -12,6 +12,8 @@ import (
 type Options struct {
 	// Maximum number of points sent to server in single request. Default 5000
 	batchSize uint
+	// Maximum size in bytes of line protocol sent to server in single request. Default 0, unlimited
+	maxBatchBytes uint
 	// Interval, in ms, in which is buffer flushed if it has not been already written (by reaching batch size) . Default 1000ms
 	flushInterval uint
 	// Precision to use in writes for timestamp. In unit of duration: time.Nanosecond, time.Microsecond, time.Millisecond, time.Second
@@ -35,6 +37,46 @@ type Options struct {
 	exponentialBase uint
 	// InfluxDB Enterprise write consistency as explained in https://docs.influxdata.com/enterprise_influxdb/v1.9/concepts/clustering/#write-consistency
 	consistency Consistency
+	// Directory for persisting the retry queue. Default "", retry queue is kept only in memory.
+	retryQueueDir string
+	// Maximum size in bytes of a single retry queue segment file. Default 16MiB.
+	retryQueueSegmentSize uint
+	// When the persistent retry queue files are synced to the stable storage. Default FsyncAlways.
+	retryQueueFsyncPolicy FsyncPolicy
+	// Minimal interval in ms between syncs of the persistent retry queue when FsyncInterval policy is used. Default 1,000ms.
+	retryQueueFsyncInterval uint
+	// What happens with a new point when the WriteAPI buffer cannot accept it. Default OverflowBlock.
+	overflowPolicy OverflowPolicy
+	// Maximum time in ms to wait for the WriteAPI buffer when OverflowBlockWithTimeout policy is used. Default 1,000ms.
+	overflowTimeout uint
+	// Number of points that can wait for the WriteAPI buffer processing. Default 0.
+	pendingBufferSize uint
+	// Receives data discarded by the WriteAPI. Default nil.
+	deadLetterHandler DeadLetterHandler
+	// Controls retrying of failed writes. Default nil, exponential strategy created from retry options.
+	retryStrategy RetryStrategy
+	// Number of consecutive failed writes opening the circuit breaker. Default 0, circuit breaker is disabled.
+	circuitBreakerThreshold uint
+	// Time in ms the circuit breaker stays open before a probe request. Default 30,000.
+	circuitBreakerOpenTimeout uint
+	// Notified about state changes of the circuit breaker. Default nil.
+	circuitBreakerCallback CircuitBreakerCallback
+	// Number of goroutines writing batches concurrently. Default 1.
+	writeWorkers uint
+	// Whether points of the same series are always written by the same write worker. Default false.
+	preserveSeriesOrder bool
+	// Whether points with field values of types not supported by line protocol are rejected. Default false, such values are written as strings.
+	strictFieldTypes bool
+	// Detects fields changing their type. Default nil.
+	fieldTypeRegistry *FieldTypeRegistry
+	// Checks performed on points and records before writing. Default ValidationNone.
+	validationLevel ValidationLevel
+	// Processors applied to written points in order. Default none.
+	pointProcessors []PointProcessor
+	// Username for basic authentication of v1 compatibility writes. Default "", the client authorization is used.
+	v1Username string
+	// Password for basic authentication of v1 compatibility writes
+	v1Password string
 }
 
 const (
@@ -54,6 +96,36 @@ const (
 // Consistency defines enum for allows consistency values for InfluxDB Enterprise, as explained  https://docs.influxdata.com/enterprise_influxdb/v1.9/concepts/clustering/#write-consistency
 type Consistency string
 
+const (
+	// FsyncAlways syncs persistent retry queue files after each change of the queue.
+	FsyncAlways FsyncPolicy = iota
+	// FsyncInterval syncs persistent retry queue files at most once per RetryQueueFsyncInterval.
+	// Changes not synced yet are synced when the queue is closed.
+	FsyncInterval
+	// FsyncNever leaves syncing of persistent retry queue files to the operating system.
+	FsyncNever
+)
+
+// FsyncPolicy defines when the persistent retry queue syncs its files to the stable storage
+type FsyncPolicy int
+
+const (
+	// OverflowBlock blocks the writing goroutine until the point is accepted.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowBlockWithTimeout blocks the writing goroutine at most OverflowTimeout, then the point is dropped and an error is reported.
+	OverflowBlockWithTimeout
+	// OverflowDropNewest drops the point being written.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest point waiting for processing to make room for the point being written.
+	OverflowDropOldest
+	// OverflowError drops the point being written and reports an error.
+	OverflowError
+)
+
+// OverflowPolicy defines how the non-blocking WriteAPI handles a new point when its buffer cannot accept it,
+// typically because a write to the server takes too long.
+type OverflowPolicy int
+
 // BatchSize returns size of batch
 func (o *Options) BatchSize() uint {
 	return o.batchSize
@@ -65,6 +137,19 @@ func (o *Options) SetBatchSize(batchSize uint) *Options {
 	return o
 }
 
+// MaxBatchBytes returns maximum size in bytes of line protocol sent to server in single request. Default 0, unlimited
+func (o *Options) MaxBatchBytes() uint {
+	return o.maxBatchBytes
+}
+
+// SetMaxBatchBytes sets maximum size in bytes of line protocol sent to server in single request.
+// Batch is sent when it reaches the batch size or the maximum size in bytes, whatever comes first.
+// A single point bigger than the maximum size is sent in a batch of its own. Zero means unlimited.
+func (o *Options) SetMaxBatchBytes(maxBatchBytes uint) *Options {
+	o.maxBatchBytes = maxBatchBytes
+	return o
+}
+
 // FlushInterval returns flush interval in ms
 func (o *Options) FlushInterval() uint {
 	return o.flushInterval
@@ -192,8 +277,256 @@ func (o *Options) SetConsistency(consistency Consistency) *Options {
 	return o
 }
 
+// RetryQueueDir returns directory where the retry queue is persisted. Empty string means the retry queue is kept only in memory.
+func (o *Options) RetryQueueDir() string {
+	return o.retryQueueDir
+}
+
+// SetRetryQueueDir sets directory for persisting the retry queue of the non-blocking WriteAPI.
+// Batches kept for retrying are then stored in segment files and survive restarts of the application,
+// they are written first when a WriteAPI for the same org and bucket is created again.
+// Each org/bucket pair uses its own subdirectory. Empty string keeps the retry queue only in memory.
+func (o *Options) SetRetryQueueDir(dir string) *Options {
+	o.retryQueueDir = dir
+	return o
+}
+
+// RetryQueueSegmentSize returns maximum size in bytes of a single persistent retry queue segment file. Default 16MiB.
+func (o *Options) RetryQueueSegmentSize() uint {
+	return o.retryQueueSegmentSize
+}
+
+// SetRetryQueueSegmentSize sets maximum size in bytes of a single persistent retry queue segment file.
+// A batch bigger than the segment size is stored in a segment of its own.
+func (o *Options) SetRetryQueueSegmentSize(segmentSize uint) *Options {
+	o.retryQueueSegmentSize = segmentSize
+	return o
+}
+
+// RetryQueueFsyncPolicy returns when the persistent retry queue files are synced to the stable storage. Default FsyncAlways.
+func (o *Options) RetryQueueFsyncPolicy() FsyncPolicy {
+	return o.retryQueueFsyncPolicy
+}
+
+// SetRetryQueueFsyncPolicy sets when the persistent retry queue files are synced to the stable storage.
+func (o *Options) SetRetryQueueFsyncPolicy(policy FsyncPolicy) *Options {
+	o.retryQueueFsyncPolicy = policy
+	return o
+}
+
+// RetryQueueFsyncInterval returns minimal interval in ms between syncs of the persistent retry queue files
+// when FsyncInterval policy is used. Default 1,000ms.
+func (o *Options) RetryQueueFsyncInterval() uint {
+	return o.retryQueueFsyncInterval
+}
+
+// SetRetryQueueFsyncInterval sets minimal interval in ms between syncs of the persistent retry queue files
+// when FsyncInterval policy is used.
+func (o *Options) SetRetryQueueFsyncInterval(fsyncIntervalMs uint) *Options {
+	o.retryQueueFsyncInterval = fsyncIntervalMs
+	return o
+}
+
+// OverflowPolicy returns how the non-blocking WriteAPI handles a new point when its buffer cannot accept it. Default OverflowBlock.
+func (o *Options) OverflowPolicy() OverflowPolicy {
+	return o.overflowPolicy
+}
+
+// SetOverflowPolicy sets how the non-blocking WriteAPI handles a new point when its buffer cannot accept it.
+func (o *Options) SetOverflowPolicy(policy OverflowPolicy) *Options {
+	o.overflowPolicy = policy
+	return o
+}
+
+// OverflowTimeout returns maximum time in ms to wait for the WriteAPI buffer when OverflowBlockWithTimeout policy is used. Default 1,000ms.
+func (o *Options) OverflowTimeout() uint {
+	return o.overflowTimeout
+}
+
+// SetOverflowTimeout sets maximum time in ms to wait for the WriteAPI buffer when OverflowBlockWithTimeout policy is used.
+func (o *Options) SetOverflowTimeout(overflowTimeoutMs uint) *Options {
+	o.overflowTimeout = overflowTimeoutMs
+	return o
+}
+
+// PendingBufferSize returns number of points that can wait for the WriteAPI buffer processing. Default 0.
+func (o *Options) PendingBufferSize() uint {
+	return o.pendingBufferSize
+}
+
+// SetPendingBufferSize sets number of points that can wait for the WriteAPI buffer processing, e.g. while a batch is being sent.
+// Overflow policy is applied only when this buffer is full.
+// Zero means no waiting points for OverflowBlock policy and BatchSize waiting points for other policies.
+func (o *Options) SetPendingBufferSize(pendingBufferSize uint) *Options {
+	o.pendingBufferSize = pendingBufferSize
+	return o
+}
+
+// DeadLetterHandler returns handler receiving data discarded by the non-blocking WriteAPI
+func (o *Options) DeadLetterHandler() DeadLetterHandler {
+	return o.deadLetterHandler
+}
+
+// SetDeadLetterHandler sets handler receiving data discarded by the non-blocking WriteAPI,
+// e.g. batches expired in the retry queue or batches that reached maximum retries.
+// Use FileDeadLetterHandler to store discarded data in a file.
+func (o *Options) SetDeadLetterHandler(handler DeadLetterHandler) *Options {
+	o.deadLetterHandler = handler
+	return o
+}
+
+// RetryStrategy returns strategy controlling retrying of failed writes of the non-blocking WriteAPI.
+// Unless set by SetRetryStrategy, it is ExponentialRetryStrategy created from RetryInterval, ExponentialBase and MaxRetryInterval.
+func (o *Options) RetryStrategy() RetryStrategy {
+	if o.retryStrategy == nil {
+		return NewExponentialRetryStrategy(o.retryInterval, o.exponentialBase, o.maxRetryInterval)
+	}
+	return o.retryStrategy
+}
+
+// SetRetryStrategy sets strategy deciding which failed writes are retried and how long to wait before a retry.
+// RetryInterval, ExponentialBase and MaxRetryInterval are not used by a custom strategy,
+// MaxRetries and MaxRetryTime still apply.
+func (o *Options) SetRetryStrategy(strategy RetryStrategy) *Options {
+	o.retryStrategy = strategy
+	return o
+}
+
+// CircuitBreakerThreshold returns number of consecutive failed writes opening the circuit breaker of the non-blocking WriteAPI.
+// Default 0, circuit breaker is disabled.
+func (o *Options) CircuitBreakerThreshold() uint {
+	return o.circuitBreakerThreshold
+}
+
+// SetCircuitBreakerThreshold sets number of consecutive failed writes opening the circuit breaker of the non-blocking WriteAPI.
+// Writes failed with retryable errors are counted. While the circuit breaker is open, batches are kept in the retry queue
+// without sending them to the server. 0 disables the circuit breaker.
+func (o *Options) SetCircuitBreakerThreshold(threshold uint) *Options {
+	o.circuitBreakerThreshold = threshold
+	return o
+}
+
+// CircuitBreakerOpenTimeout returns time in ms the circuit breaker stays open before a probe request. Default 30,000.
+func (o *Options) CircuitBreakerOpenTimeout() uint {
+	return o.circuitBreakerOpenTimeout
+}
+
+// SetCircuitBreakerOpenTimeout sets time in ms the circuit breaker stays open before it allows a probe request.
+// Successful probe closes the circuit breaker, failed probe opens it again.
+func (o *Options) SetCircuitBreakerOpenTimeout(timeoutMs uint) *Options {
+	o.circuitBreakerOpenTimeout = timeoutMs
+	return o
+}
+
+// CircuitBreakerCallback returns callback notified about state changes of the circuit breaker
+func (o *Options) CircuitBreakerCallback() CircuitBreakerCallback {
+	return o.circuitBreakerCallback
+}
+
+// SetCircuitBreakerCallback sets callback synchronously notified about state changes of the circuit breaker
+func (o *Options) SetCircuitBreakerCallback(cb CircuitBreakerCallback) *Options {
+	o.circuitBreakerCallback = cb
+	return o
+}
+
+// WriteWorkers returns number of goroutines of the non-blocking WriteAPI writing batches concurrently. Default 1.
+func (o *Options) WriteWorkers() uint {
+	return o.writeWorkers
+}
+
+// SetWriteWorkers sets number of goroutines of the non-blocking WriteAPI writing batches concurrently.
+// Batches are written in parallel only while the retry queue is empty, retrying is always done by one worker at a time.
+// Without PreserveSeriesOrder, points of the same series can be written in a different order than they were written to the WriteAPI.
+func (o *Options) SetWriteWorkers(writeWorkers uint) *Options {
+	if writeWorkers == 0 {
+		writeWorkers = 1
+	}
+	o.writeWorkers = writeWorkers
+	return o
+}
+
+// PreserveSeriesOrder returns whether points of the same series are always written by the same write worker. Default false.
+func (o *Options) PreserveSeriesOrder() bool {
+	return o.preserveSeriesOrder
+}
+
+// SetPreserveSeriesOrder sets whether points of the same series (measurement and tags) are always written by the same write worker,
+// so they are written in the same order as they were written to the WriteAPI.
+// Each batch is then split into per worker batches. It has effect only with more write workers.
+func (o *Options) SetPreserveSeriesOrder(preserveSeriesOrder bool) *Options {
+	o.preserveSeriesOrder = preserveSeriesOrder
+	return o
+}
+
+// StrictFieldTypes returns whether points with field values of types not supported by line protocol are rejected
+func (o *Options) StrictFieldTypes() bool {
+	return o.strictFieldTypes
+}
+
+// SetStrictFieldTypes sets whether points with field values of types not supported by line protocol, see Point.Err, are rejected
+// by write APIs with FieldTypeError, instead of writing such values as strings.
+func (o *Options) SetStrictFieldTypes(strictFieldTypes bool) *Options {
+	o.strictFieldTypes = strictFieldTypes
+	return o
+}
+
+// FieldTypeRegistry returns registry of field types, or nil if not set
+func (o *Options) FieldTypeRegistry() *FieldTypeRegistry {
+	return o.fieldTypeRegistry
+}
+
+// SetFieldTypeRegistry sets registry of field types per measurement. Points with fields changing their type
+// are then rejected by write APIs with FieldTypeError. The registry can be shared by more write APIs.
+func (o *Options) SetFieldTypeRegistry(registry *FieldTypeRegistry) *Options {
+	o.fieldTypeRegistry = registry
+	return o
+}
+
+// ValidationLevel returns which checks are performed on points and records before writing
+func (o *Options) ValidationLevel() ValidationLevel {
+	return o.validationLevel
+}
+
+// SetValidationLevel sets which checks are performed on points and records before writing.
+// Invalid points and records are not written and they are described by ValidationError, other data are written.
+func (o *Options) SetValidationLevel(level ValidationLevel) *Options {
+	o.validationLevel = level
+	return o
+}
+
+// AddPointProcessor adds a processor to the end of the chain of processors applied to written points.
+// Processors are applied by WritePoint methods of write APIs before default tags, they get a copy of the written point.
+// Points dropped by processors are not written. Records are not processed.
+func (o *Options) AddPointProcessor(processor PointProcessor) *Options {
+	o.pointProcessors = append(o.pointProcessors, processor)
+	return o
+}
+
+// PointProcessors returns processors applied to written points
+func (o *Options) PointProcessors() []PointProcessor {
+	return o.pointProcessors
+}
+
+// V1Credentials returns username and password used by v1 compatibility write APIs
+func (o *Options) V1Credentials() (string, string) {
+	return o.v1Username, o.v1Password
+}
+
+// SetV1Credentials sets username and password for basic authentication of writes by v1 compatibility write APIs
+// (Client.WriteAPIV1, Client.WriteAPIBlockingV1). They replace authorization of the client for these writes.
+// For InfluxDB 2 v1 compatibility endpoint, password is the token or password of a v1 authorization.
+// Other write APIs ignore the credentials.
+func (o *Options) SetV1Credentials(username, password string) *Options {
+	o.v1Username = username
+	o.v1Password = password
+	return o
+}
+
 // DefaultOptions returns Options object with default values
 func DefaultOptions() *Options {
 	return &Options{batchSize: 5_000, flushInterval: 1_000, precision: time.Nanosecond, useGZip: false, retryBufferLimit: 50_000, defaultTags: make(map[string]string),
-		maxRetries: 5, retryInterval: 5_000, maxRetryInterval: 125_000, maxRetryTime: 180_000, exponentialBase: 2}
+		maxRetries: 5, retryInterval: 5_000, maxRetryInterval: 125_000, maxRetryTime: 180_000, exponentialBase: 2,
+		retryQueueSegmentSize: 16 * 1024 * 1024, retryQueueFsyncPolicy: FsyncAlways, retryQueueFsyncInterval: 1_000,
+		overflowPolicy: OverflowBlock, overflowTimeout: 1_000, circuitBreakerOpenTimeout: 30_000,
+		writeWorkers: 1}
 }
@@ -15,6 +15,7 @@ import (
 func TestDefaultOptions(t *testing.T) {
 	opts := write.DefaultOptions()
 	assert.EqualValues(t, 5_000, opts.BatchSize())
+	assert.EqualValues(t, 0, opts.MaxBatchBytes())
 	assert.EqualValues(t, false, opts.UseGZip())
 	assert.EqualValues(t, 1_000, opts.FlushInterval())
 	assert.EqualValues(t, time.Nanosecond, opts.Precision())
@@ -26,11 +27,33 @@ func TestDefaultOptions(t *testing.T) {
 	assert.EqualValues(t, 2, opts.ExponentialBase())
 	assert.EqualValues(t, "", opts.Consistency())
 	assert.Len(t, opts.DefaultTags(), 0)
+	assert.EqualValues(t, "", opts.RetryQueueDir())
+	assert.EqualValues(t, 16*1024*1024, opts.RetryQueueSegmentSize())
+	assert.EqualValues(t, write.FsyncAlways, opts.RetryQueueFsyncPolicy())
+	assert.EqualValues(t, 1_000, opts.RetryQueueFsyncInterval())
+	assert.EqualValues(t, write.OverflowBlock, opts.OverflowPolicy())
+	assert.EqualValues(t, 1_000, opts.OverflowTimeout())
+	assert.EqualValues(t, 0, opts.PendingBufferSize())
+	assert.Nil(t, opts.DeadLetterHandler())
+	assert.Equal(t, write.NewExponentialRetryStrategy(5_000, 2, 125_000), opts.RetryStrategy())
+	assert.EqualValues(t, 0, opts.CircuitBreakerThreshold())
+	assert.EqualValues(t, 30_000, opts.CircuitBreakerOpenTimeout())
+	assert.Nil(t, opts.CircuitBreakerCallback())
+	assert.EqualValues(t, 1, opts.WriteWorkers())
+	assert.False(t, opts.PreserveSeriesOrder())
+	assert.False(t, opts.StrictFieldTypes())
+	assert.Nil(t, opts.FieldTypeRegistry())
+	assert.Equal(t, write.ValidationNone, opts.ValidationLevel())
+	assert.Len(t, opts.PointProcessors(), 0)
+	username, password := opts.V1Credentials()
+	assert.Equal(t, "", username)
+	assert.Equal(t, "", password)
 }
 
 func TestSettingsOptions(t *testing.T) {
 	opts := write.DefaultOptions().
 		SetBatchSize(5).
+		SetMaxBatchBytes(1024).
 		SetUseGZip(true).
 		SetFlushInterval(5_000).
 		SetPrecision(time.Millisecond).
@@ -42,8 +65,29 @@ func TestSettingsOptions(t *testing.T) {
 		SetMaxRetryTime(200_000).
 		AddDefaultTag("a", "1").
 		AddDefaultTag("b", "2").
-		SetConsistency(write.ConsistencyOne)
+		SetConsistency(write.ConsistencyOne).
+		SetRetryQueueDir("/var/lib/app/retry").
+		SetRetryQueueSegmentSize(1024).
+		SetRetryQueueFsyncPolicy(write.FsyncInterval).
+		SetRetryQueueFsyncInterval(500).
+		SetOverflowPolicy(write.OverflowDropOldest).
+		SetOverflowTimeout(200).
+		SetPendingBufferSize(100).
+		SetDeadLetterHandler(write.DeadLetterHandlerFunc(func(string, write.DiscardReason, error) {})).
+		SetRetryStrategy(write.NewConstantRetryStrategy(1_000)).
+		SetCircuitBreakerThreshold(3).
+		SetCircuitBreakerOpenTimeout(10_000).
+		SetCircuitBreakerCallback(func(_, _ write.CircuitState) {}).
+		SetWriteWorkers(4).
+		SetPreserveSeriesOrder(true).
+		SetStrictFieldTypes(true).
+		SetFieldTypeRegistry(write.NewFieldTypeRegistry()).
+		SetValidationLevel(write.ValidationStrict).
+		AddPointProcessor(write.DropTags("a")).
+		AddPointProcessor(write.Sample(time.Second)).
+		SetV1Credentials("user", "pass")
 	assert.EqualValues(t, 5, opts.BatchSize())
+	assert.EqualValues(t, 1024, opts.MaxBatchBytes())
 	assert.EqualValues(t, true, opts.UseGZip())
 	assert.EqualValues(t, 5000, opts.FlushInterval())
 	assert.EqualValues(t, time.Millisecond, opts.Precision())
@@ -55,4 +99,26 @@ func TestSettingsOptions(t *testing.T) {
 	assert.EqualValues(t, 3, opts.ExponentialBase())
 	assert.EqualValues(t, "one", opts.Consistency())
 	assert.Len(t, opts.DefaultTags(), 2)
+	assert.EqualValues(t, "/var/lib/app/retry", opts.RetryQueueDir())
+	assert.EqualValues(t, 1024, opts.RetryQueueSegmentSize())
+	assert.EqualValues(t, write.FsyncInterval, opts.RetryQueueFsyncPolicy())
+	assert.EqualValues(t, 500, opts.RetryQueueFsyncInterval())
+	assert.EqualValues(t, write.OverflowDropOldest, opts.OverflowPolicy())
+	assert.EqualValues(t, 200, opts.OverflowTimeout())
+	assert.EqualValues(t, 100, opts.PendingBufferSize())
+	assert.NotNil(t, opts.DeadLetterHandler())
+	assert.Equal(t, write.NewConstantRetryStrategy(1_000), opts.RetryStrategy())
+	assert.EqualValues(t, 3, opts.CircuitBreakerThreshold())
+	assert.EqualValues(t, 10_000, opts.CircuitBreakerOpenTimeout())
+	assert.NotNil(t, opts.CircuitBreakerCallback())
+	assert.EqualValues(t, 4, opts.WriteWorkers())
+	assert.True(t, opts.PreserveSeriesOrder())
+	assert.True(t, opts.StrictFieldTypes())
+	assert.NotNil(t, opts.FieldTypeRegistry())
+	assert.Equal(t, write.ValidationStrict, opts.ValidationLevel())
+	assert.Len(t, opts.PointProcessors(), 2)
+	username, password := opts.V1Credentials()
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+	assert.EqualValues(t, 1, opts.SetWriteWorkers(0).WriteWorkers())
 }
@@ -0,0 +1,346 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError describes invalid line protocol
+type ParseError struct {
+	// Line is number of the line, starting from 1
+	Line int
+	// Column is position of the error in the line in bytes, starting from 1
+	Column int
+	// Text is the invalid line
+	Text string
+	// Msg describes the error
+	Msg string
+}
+
+// Error fulfils error interface
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// LineProtocolParser reads points from line protocol.
+// Empty lines and comments starting with # are skipped.
+// String field values can contain new lines, such point spans multiple lines.
+type LineProtocolParser struct {
+	reader    *bufio.Reader
+	precision time.Duration
+	line      int
+	err       error
+}
+
+// NewLineProtocolParser creates parser reading line protocol from r.
+// precision is precision of timestamps, time.Nanosecond, time.Microsecond, time.Millisecond or time.Second.
+func NewLineProtocolParser(r io.Reader, precision time.Duration) *LineProtocolParser {
+	return &LineProtocolParser{reader: bufio.NewReader(r), precision: precision}
+}
+
+// Next returns the next point. It returns io.EOF when there are no more points.
+// Invalid line is reported as *ParseError, parsing can continue by calling Next again.
+// Other errors are errors of the reader.
+func (p *LineProtocolParser) Next() (*Point, error) {
+	for {
+		if p.err != nil {
+			return nil, p.err
+		}
+		start := p.line + 1
+		text, err := p.readLine()
+		if err != nil {
+			p.err = err
+			if text == "" {
+				return nil, err
+			}
+		}
+		point, perr := parseText(text, p.precision)
+		if perr != nil {
+			perr.Line = start
+			return nil, perr
+		}
+		if point != nil {
+			return point, nil
+		}
+	}
+}
+
+// readLine reads a line, including following lines when a string field value contains a new line
+func (p *LineProtocolParser) readLine() (string, error) {
+	var sb strings.Builder
+	for {
+		part, err := p.reader.ReadString('\n')
+		if part != "" {
+			p.line++
+		}
+		sb.WriteString(part)
+		if err != nil {
+			if errors.Is(err, io.EOF) && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return sb.String(), err
+		}
+		if !inString(sb.String()) {
+			return sb.String(), nil
+		}
+	}
+}
+
+// inString returns true if line ends inside a string field value
+func inString(line string) bool {
+	spaces := 0
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\':
+			i++
+		case c == '"' && spaces == 1:
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			spaces++
+		}
+	}
+	return quoted
+}
+
+// ParseLineProtocol parses all points from line protocol, it stops at the first invalid line
+func ParseLineProtocol(lines string, precision time.Duration) ([]*Point, error) {
+	var points []*Point
+	parser := NewLineProtocolParser(strings.NewReader(lines), precision)
+	for {
+		point, err := parser.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return points, nil
+			}
+			return points, err
+		}
+		points = append(points, point)
+	}
+}
+
+// ParseLine parses a single line of line protocol. It returns nil point and nil error for an empty line or a comment.
+// Invalid line is reported as *ParseError.
+func ParseLine(line string, precision time.Duration) (*Point, error) {
+	point, perr := parseText(line, precision)
+	if perr != nil {
+		return nil, perr
+	}
+	return point, nil
+}
+
+// parseText parses point from a line, it returns nil point for an empty line or a comment
+func parseText(line string, precision time.Duration) (*Point, *ParseError) {
+	text := strings.TrimRight(line, "\r\n")
+	trimmed := strings.TrimLeft(text, " \t")
+	if trimmed == "" || trimmed[0] == '#' {
+		return nil, nil
+	}
+	point, column, msg := parseLine(text, len(text)-len(trimmed), precision)
+	if msg != "" {
+		return nil, &ParseError{Line: 1, Column: column + 1, Text: text, Msg: msg}
+	}
+	return point, nil
+}
+
+// lineScanner holds position in a parsed line
+type lineScanner struct {
+	text string
+	pos  int
+}
+
+// parseLine parses point from text starting at pos. It returns position and description of an error, if any.
+func parseLine(text string, pos int, precision time.Duration) (*Point, int, string) {
+	s := &lineScanner{text: text, pos: pos}
+	measurement := s.token(" ,", false)
+	if measurement == "" {
+		return nil, s.pos, "missing measurement"
+	}
+	point := NewPointWithMeasurement(measurement)
+	for s.peek() == ',' {
+		s.pos++
+		key := s.token("=, ", true)
+		if key == "" {
+			return nil, s.pos, "missing tag key"
+		}
+		if s.peek() != '=' {
+			return nil, s.pos, "missing tag value"
+		}
+		s.pos++
+		value := s.token(", ", true)
+		if value == "" {
+			return nil, s.pos, "missing tag value"
+		}
+		point.AddTag(key, value)
+	}
+	if !s.skipSpaces() {
+		return nil, s.pos, "missing fields"
+	}
+	for {
+		key := s.token("=, ", true)
+		if key == "" {
+			return nil, s.pos, "missing field key"
+		}
+		if s.peek() != '=' {
+			return nil, s.pos, "missing field value"
+		}
+		s.pos++
+		valuePos := s.pos
+		value, msg := s.fieldValue()
+		if msg != "" {
+			return nil, valuePos, msg
+		}
+		point.AddField(key, value)
+		if s.peek() != ',' {
+			break
+		}
+		s.pos++
+	}
+	if s.skipSpaces() && s.pos < len(s.text) {
+		tsPos := s.pos
+		ts := s.token(" ", false)
+		n, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, tsPos, "invalid timestamp"
+		}
+		t, ok := timestamp(n, precision)
+		if !ok {
+			return nil, tsPos, "timestamp out of range"
+		}
+		point.SetTime(t)
+		s.skipSpaces()
+	}
+	if s.pos < len(s.text) {
+		return nil, s.pos, "unexpected text after timestamp"
+	}
+	return point, 0, ""
+}
+
+// peek returns the current byte, or 0 at the end of the line
+func (s *lineScanner) peek() byte {
+	if s.pos < len(s.text) {
+		return s.text[s.pos]
+	}
+	return 0
+}
+
+// skipSpaces moves after spaces, it returns false if there are no spaces
+func (s *lineScanner) skipSpaces() bool {
+	start := s.pos
+	for s.pos < len(s.text) && s.text[s.pos] == ' ' {
+		s.pos++
+	}
+	return s.pos > start
+}
+
+// token reads unescaped text until one of the unescaped stop bytes.
+// Backslash escapes space and comma, and also equal sign if escapeEqual is true.
+// Backslash before other characters is kept.
+func (s *lineScanner) token(stop string, escapeEqual bool) string {
+	var sb strings.Builder
+	for s.pos < len(s.text) {
+		c := s.text[s.pos]
+		if c == '\\' && s.pos+1 < len(s.text) {
+			next := s.text[s.pos+1]
+			if next == ' ' || next == ',' || (next == '=' && escapeEqual) {
+				sb.WriteByte(next)
+				s.pos += 2
+				continue
+			}
+		}
+		if strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		sb.WriteByte(c)
+		s.pos++
+	}
+	return sb.String()
+}
+
+// fieldValue reads field value
+func (s *lineScanner) fieldValue() (interface{}, string) {
+	if s.peek() == '"' {
+		return s.stringValue()
+	}
+	start := s.pos
+	for s.pos < len(s.text) && s.text[s.pos] != ',' && s.text[s.pos] != ' ' {
+		s.pos++
+	}
+	value := s.text[start:s.pos]
+	if value == "" {
+		return nil, "missing field value"
+	}
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return true, ""
+	case "f", "F", "false", "False", "FALSE":
+		return false, ""
+	}
+	switch value[len(value)-1] {
+	case 'i':
+		n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		if err != nil {
+			return nil, "invalid integer field value"
+		}
+		return n, ""
+	case 'u':
+		n, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+		if err != nil {
+			return nil, "invalid unsigned integer field value"
+		}
+		return n, ""
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, "invalid field value"
+	}
+	return f, ""
+}
+
+// stringValue reads quoted string, backslash escapes double quote and backslash
+func (s *lineScanner) stringValue() (interface{}, string) {
+	var sb strings.Builder
+	s.pos++
+	for s.pos < len(s.text) {
+		c := s.text[s.pos]
+		switch {
+		case c == '\\' && s.pos+1 < len(s.text) && (s.text[s.pos+1] == '"' || s.text[s.pos+1] == '\\'):
+			sb.WriteByte(s.text[s.pos+1])
+			s.pos += 2
+			continue
+		case c == '"':
+			s.pos++
+			return sb.String(), ""
+		}
+		sb.WriteByte(c)
+		s.pos++
+	}
+	return nil, "unterminated string field value"
+}
+
+// timestamp converts timestamp in precision to time.
+// It returns false if the timestamp cannot be represented in nanoseconds.
+func timestamp(n int64, precision time.Duration) (time.Time, bool) {
+	switch precision {
+	case time.Microsecond, time.Millisecond:
+		if n > math.MaxInt64/int64(precision) || n < math.MinInt64/int64(precision) {
+			return time.Time{}, false
+		}
+		return time.Unix(0, n*int64(precision)), true
+	case time.Second:
+		return time.Unix(n, 0), true
+	default:
+		return time.Unix(0, n), true
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+func TestParseLineProtocol(t *testing.T) {
+	points, err := write.ParseLineProtocol(`# comment
+cpu,host=server\ 1,region=us\,west usage=0.5,count=10i,total=20u,ok=true,down=F,msg="say \"hi\" \\ bye" 1600000000000000000
+
+  mem\ used,a\=b=c\=d value=-1.5e3
+weather temp=21 1600000000
+`, time.Nanosecond)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+
+	p := points[0]
+	assert.Equal(t, "cpu", p.Name())
+	require.Len(t, p.TagList(), 2)
+	assert.Equal(t, "host", p.TagList()[0].Key)
+	assert.Equal(t, "server 1", p.TagList()[0].Value)
+	assert.Equal(t, "us,west", p.TagList()[1].Value)
+	fields := make(map[string]interface{})
+	for _, f := range p.FieldList() {
+		fields[f.Key] = f.Value
+	}
+	assert.Equal(t, map[string]interface{}{
+		"usage": 0.5,
+		"count": int64(10),
+		"total": uint64(20),
+		"ok":    true,
+		"down":  false,
+		"msg":   `say "hi" \ bye`,
+	}, fields)
+	assert.Equal(t, time.Unix(0, 1600000000000000000), p.Time())
+
+	p = points[1]
+	assert.Equal(t, "mem used", p.Name())
+	assert.Equal(t, "a=b", p.TagList()[0].Key)
+	assert.Equal(t, "c=d", p.TagList()[0].Value)
+	assert.Equal(t, -1500.0, p.FieldList()[0].Value)
+	assert.True(t, p.Time().IsZero())
+
+	assert.Equal(t, time.Unix(0, 1600000000), points[2].Time())
+}
+
+func TestParseLineProtocolPrecision(t *testing.T) {
+	for _, precision := range []time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond} {
+		points, err := write.ParseLineProtocol("m f=1 1600000000", precision)
+		require.NoError(t, err)
+		require.Len(t, points, 1)
+		assert.Equal(t, time.Unix(0, 1600000000*int64(precision)), points[0].Time())
+	}
+}
+
+func TestParseLineTimestampRange(t *testing.T) {
+	_, err := write.ParseLine("m f=1 9300000000000", time.Millisecond)
+	var perr *write.ParseError
+	require.True(t, errors.As(err, &perr), err)
+	assert.Equal(t, "timestamp out of range", perr.Msg)
+	assert.Equal(t, 7, perr.Column)
+
+	for _, precision := range []time.Duration{time.Millisecond, time.Microsecond} {
+		max := math.MaxInt64 / int64(precision)
+		min := math.MinInt64 / int64(precision)
+		p, err := write.ParseLine(fmt.Sprintf("m f=1 %d", max), precision)
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(0, max*int64(precision)), p.Time())
+		p, err = write.ParseLine(fmt.Sprintf("m f=1 %d", min), precision)
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(0, min*int64(precision)), p.Time())
+		_, err = write.ParseLine(fmt.Sprintf("m f=1 %d", max+1), precision)
+		assert.EqualError(t, err, "line 1, column 7: timestamp out of range")
+		_, err = write.ParseLine(fmt.Sprintf("m f=1 %d", min-1), precision)
+		assert.Error(t, err)
+	}
+}
+
+func TestParseLineProtocolRoundTrip(t *testing.T) {
+	p := write.NewPoint("test m,1",
+		map[string]string{"t 1": "v,1", "t=2": "v=2"},
+		map[string]interface{}{"f 1": "a\"b\\c\nd", "f2": int64(-3), "f3": uint64(3), "f4": 1.25, "f5": true},
+		time.Unix(1600000000, 123456789))
+	line := write.PointToLineProtocol(p, time.Nanosecond)
+	points, err := write.ParseLineProtocol(line+line, time.Nanosecond)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	for _, parsed := range points {
+		assert.Equal(t, line, write.PointToLineProtocol(parsed.SortTags().SortFields(), time.Nanosecond))
+	}
+}
+
+func TestParseLineProtocolErrors(t *testing.T) {
+	testCases := []struct {
+		line   string
+		column int
+		msg    string
+	}{
+		{",a=b f=1", 1, "missing measurement"},
+		{"m", 2, "missing fields"},
+		{"m,a f=1", 4, "missing tag value"},
+		{"m,a= f=1", 5, "missing tag value"},
+		{"m,=b f=1", 3, "missing tag key"},
+		{"m f", 4, "missing field value"},
+		{"m f=", 5, "missing field value"},
+		{"m =1", 3, "missing field key"},
+		{"m f=1,", 7, "missing field key"},
+		{"m f=abc", 5, "invalid field value"},
+		{"m f=1.5i", 5, "invalid integer field value"},
+		{"m f=-1u", 5, "invalid unsigned integer field value"},
+		{"m f=NaN", 5, "invalid field value"},
+		{`m f="abc`, 5, "unterminated string field value"},
+		{"m f=1 abc", 7, "invalid timestamp"},
+		{"m f=1 1 x", 9, "unexpected text after timestamp"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			_, err := write.ParseLineProtocol("m f=1\n"+tc.line, time.Nanosecond)
+			var perr *write.ParseError
+			require.True(t, errors.As(err, &perr), err)
+			assert.Equal(t, 2, perr.Line)
+			assert.Equal(t, tc.column, perr.Column)
+			assert.Equal(t, tc.msg, perr.Msg)
+			assert.Equal(t, tc.line, perr.Text)
+		})
+	}
+}
+
+func TestLineProtocolParserContinues(t *testing.T) {
+	parser := write.NewLineProtocolParser(strings.NewReader("m f=1\r\nm f=x\n\nm f=\"multi\nline\" 2\nm f=3"), time.Nanosecond)
+	p, err := parser.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, p.FieldList()[0].Value)
+
+	_, err = parser.Next()
+	assert.EqualError(t, err, "line 2, column 5: invalid field value")
+
+	p, err = parser.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "multi\nline", p.FieldList()[0].Value)
+	assert.Equal(t, time.Unix(0, 2), p.Time())
+
+	_, err = parser.Next()
+	require.NoError(t, err)
+	_, err = parser.Next()
+	assert.Equal(t, io.EOF, err)
+	_, err = parser.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParseLine(t *testing.T) {
+	p, err := write.ParseLine("m,t=a f=1i 10\n", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "m", p.Name())
+	assert.Equal(t, time.Unix(10, 0), p.Time())
+
+	for _, line := range []string{"", "  \r\n", "# comment"} {
+		p, err = write.ParseLine(line, time.Nanosecond)
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+	}
+
+	_, err = write.ParseLine("m f=x", time.Nanosecond)
+	assert.EqualError(t, err, "line 1, column 5: invalid field value")
+}
@@ -19,6 +19,8 @@ type Point struct {
 	tags        []*lp.Tag
 	fields      []*lp.Field
 	timestamp   time.Time
+	// unsupported holds keys and original types of fields with values not supported by line protocol
+	unsupported map[string]string
 }
 
 // TagList returns a slice containing tags of a Point.
@@ -68,17 +70,134 @@ func (m *Point) AddTag(k, v string) *Point {
 }
 
 // AddField adds a field to a point.
+// Values of types not supported by line protocol are converted to strings, such points are reported by Err.
 func (m *Point) AddField(k string, v interface{}) *Point {
+	return m.setField(k, m.convertField(k, v))
+}
+
+// AddFloatField adds a float field to a point.
+func (m *Point) AddFloatField(k string, v float64) *Point {
+	delete(m.unsupported, k)
+	return m.setField(k, v)
+}
+
+// AddIntField adds an integer field to a point.
+func (m *Point) AddIntField(k string, v int64) *Point {
+	delete(m.unsupported, k)
+	return m.setField(k, v)
+}
+
+// AddUintField adds an unsigned integer field to a point.
+func (m *Point) AddUintField(k string, v uint64) *Point {
+	delete(m.unsupported, k)
+	return m.setField(k, v)
+}
+
+// AddBoolField adds a boolean field to a point.
+func (m *Point) AddBoolField(k string, v bool) *Point {
+	delete(m.unsupported, k)
+	return m.setField(k, v)
+}
+
+// AddStringField adds a string field to a point.
+func (m *Point) AddStringField(k string, v string) *Point {
+	delete(m.unsupported, k)
+	return m.setField(k, v)
+}
+
+// convertField converts value v of field k to a type supported by line protocol and records fields of unsupported types
+func (m *Point) convertField(k string, v interface{}) interface{} {
+	cv, ok := convertFieldStrict(v)
+	if ok {
+		delete(m.unsupported, k)
+		return cv
+	}
+	if m.unsupported == nil {
+		m.unsupported = make(map[string]string)
+	}
+	m.unsupported[k] = fmt.Sprintf("%T", v)
+	return convertField(v)
+}
+
+// setField sets value of the field with key k, or adds a new field
+func (m *Point) setField(k string, v interface{}) *Point {
 	for i, field := range m.fields {
 		if k == field.Key {
 			m.fields[i].Value = v
 			return m
 		}
 	}
-	m.fields = append(m.fields, &lp.Field{Key: k, Value: convertField(v)})
+	m.fields = append(m.fields, &lp.Field{Key: k, Value: v})
+	return m
+}
+
+// Err returns FieldTypeError for the first field, by key, which value had a type not supported by line protocol and was converted to a string.
+// It returns nil if all field values are supported. Such points are rejected by write APIs with strict field types, see Options.SetStrictFieldTypes.
+func (m *Point) Err() error {
+	if len(m.unsupported) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m.unsupported))
+	for k := range m.unsupported {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &FieldTypeError{Measurement: m.measurement, Field: keys[0], Type: m.unsupported[keys[0]]}
+}
+
+// RemoveTag removes tag with key k from a point.
+func (m *Point) RemoveTag(k string) *Point {
+	for i, tag := range m.tags {
+		if k == tag.Key {
+			m.tags = append(m.tags[:i], m.tags[i+1:]...)
+			break
+		}
+	}
+	return m
+}
+
+// RemoveField removes field with key k from a point.
+func (m *Point) RemoveField(k string) *Point {
+	for i, field := range m.fields {
+		if k == field.Key {
+			m.fields = append(m.fields[:i], m.fields[i+1:]...)
+			delete(m.unsupported, k)
+			break
+		}
+	}
+	return m
+}
+
+// SetMeasurement sets the name of measurement of a point.
+func (m *Point) SetMeasurement(measurement string) *Point {
+	m.measurement = measurement
 	return m
 }
 
+// Copy returns a copy of a point, which can be modified without affecting the original point.
+func (m *Point) Copy() *Point {
+	c := &Point{measurement: m.measurement, timestamp: m.timestamp}
+	if m.tags != nil {
+		c.tags = make([]*lp.Tag, len(m.tags))
+		for i, tag := range m.tags {
+			c.tags[i] = &lp.Tag{Key: tag.Key, Value: tag.Value}
+		}
+	}
+	if m.fields != nil {
+		c.fields = make([]*lp.Field, len(m.fields))
+		for i, field := range m.fields {
+			c.fields[i] = &lp.Field{Key: field.Key, Value: field.Value}
+		}
+	}
+	if len(m.unsupported) > 0 {
+		c.unsupported = make(map[string]string, len(m.unsupported))
+		for k, v := range m.unsupported {
+			c.unsupported[k] = v
+		}
+	}
+	return c
+}
+
 // Name returns the name of measurement of a point.
 func (m *Point) Name() string {
 	return m.measurement
@@ -114,7 +233,7 @@ func NewPoint(
 
 	m.fields = make([]*lp.Field, 0, len(fields))
 	for k, v := range fields {
-		v := convertField(v)
+		v := m.convertField(k, v)
 		if v == nil {
 			continue
 		}
@@ -125,38 +244,49 @@ func NewPoint(
 	return m
 }
 
-// convertField converts any primitive type to types supported by line protocol
+// convertField converts any primitive type to types supported by line protocol, other types are formatted as strings
 func convertField(v interface{}) interface{} {
+	if cv, ok := convertFieldStrict(v); ok {
+		return cv
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// convertFieldStrict converts any primitive type to types supported by line protocol.
+// It returns false if v is of other type, including time.Time and time.Duration, which have no line protocol type.
+func convertFieldStrict(v interface{}) (interface{}, bool) {
 	switch v := v.(type) {
 	case bool, int64, string, float64:
-		return v
+		return v, true
 	case int:
-		return int64(v)
+		return int64(v), true
 	case uint:
-		return uint64(v)
+		return uint64(v), true
 	case uint64:
-		return v
+		return v, true
 	case []byte:
-		return string(v)
+		return string(v), true
 	case int32:
-		return int64(v)
+		return int64(v), true
 	case int16:
-		return int64(v)
+		return int64(v), true
 	case int8:
-		return int64(v)
+		return int64(v), true
 	case uint32:
-		return uint64(v)
+		return uint64(v), true
 	case uint16:
-		return uint64(v)
+		return uint64(v), true
 	case uint8:
-		return uint64(v)
+		return uint64(v), true
 	case float32:
-		return float64(v)
-	case time.Time:
-		return v.Format(time.RFC3339Nano)
-	case time.Duration:
-		return v.String()
+		return float64(v), true
 	default:
-		return fmt.Sprintf("%v", v)
+		return nil, false
 	}
 }
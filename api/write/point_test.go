@@ -239,6 +239,83 @@ func TestPointFluent(t *testing.T) {
 	verifyPoint(t, p)
 }
 
+func TestPointTypedFields(t *testing.T) {
+	p := NewPointWithMeasurement("test").
+		AddFloatField("f", 1.5).
+		AddIntField("i", -2).
+		AddUintField("u", 3).
+		AddBoolField("b", true).
+		AddStringField("s", "x").
+		AddIntField("i", 4).
+		SortFields()
+	require.Len(t, p.FieldList(), 5)
+	assert.Equal(t, []interface{}{true, 1.5, int64(4), "x", uint64(3)},
+		[]interface{}{p.FieldList()[0].Value, p.FieldList()[1].Value, p.FieldList()[2].Value, p.FieldList()[3].Value, p.FieldList()[4].Value})
+	assert.NoError(t, p.Err())
+}
+
+func TestPointErr(t *testing.T) {
+	p := NewPointWithMeasurement("test").
+		AddField("v", 1).
+		AddField("s", st{1.22, true}).
+		AddField("a", ia(4))
+	err := p.Err()
+	require.Error(t, err)
+	assert.Equal(t, "field 'a' of measurement 'test': unsupported value type write.ia", err.Error())
+	// values are still converted to strings
+	assert.Equal(t, "1.22 d true", p.FieldList()[1].Value)
+
+	// replaced values clear the error
+	p.AddIntField("a", 4)
+	assert.Equal(t, "field 's' of measurement 'test': unsupported value type write.st", p.Err().Error())
+	p.AddField("s", "x")
+	assert.NoError(t, p.Err())
+
+	p = NewPoint("test", nil, map[string]interface{}{"v": 1, "p": &st{}}, time.Time{})
+	var fieldErr *FieldTypeError
+	require.ErrorAs(t, p.Err(), &fieldErr)
+	assert.Equal(t, FieldTypeError{Measurement: "test", Field: "p", Type: "*write.st"}, *fieldErr)
+
+	// time values are formatted as strings
+	p = NewPointWithMeasurement("test").AddField("t", time.Unix(0, 0).UTC()).AddField("d", time.Minute)
+	require.ErrorAs(t, p.Err(), &fieldErr)
+	assert.Equal(t, FieldTypeError{Measurement: "test", Field: "d", Type: "time.Duration"}, *fieldErr)
+	assert.Equal(t, "1970-01-01T00:00:00Z", p.FieldList()[0].Value)
+	assert.Equal(t, "1m0s", p.FieldList()[1].Value)
+	p.RemoveField("d")
+	require.ErrorAs(t, p.Err(), &fieldErr)
+	assert.Equal(t, "time.Time", fieldErr.Type)
+
+	// replacing existing field converts the value
+	p = NewPoint("test", nil, map[string]interface{}{"v": 1, "p": &st{}}, time.Time{})
+	p.AddField("v", int8(2))
+	assert.Equal(t, int64(2), p.FieldList()[1].Value)
+}
+
+func TestPointCopy(t *testing.T) {
+	p := NewPointWithMeasurement("test").AddTag("a", "1").AddTag("b", "2").AddField("f", 1).AddField("s", st{}).SetTime(time.Unix(60, 70))
+	c := p.Copy()
+	assert.Equal(t, p, c)
+	c.SetMeasurement("copy").AddTag("a", "10").RemoveTag("b").RemoveField("s").AddIntField("f", 2)
+	assert.Equal(t, "copy", c.Name())
+	require.Len(t, c.TagList(), 1)
+	assert.Equal(t, "10", c.TagList()[0].Value)
+	require.Len(t, c.FieldList(), 1)
+	assert.Equal(t, int64(2), c.FieldList()[0].Value)
+	assert.NoError(t, c.Err())
+
+	assert.Equal(t, "test", p.Name())
+	assert.Equal(t, "1", p.TagList()[0].Value)
+	assert.Len(t, p.TagList(), 2)
+	assert.Len(t, p.FieldList(), 2)
+	assert.Equal(t, int64(1), p.FieldList()[0].Value)
+	assert.Error(t, p.Err())
+
+	// removing missing keys does nothing
+	c.RemoveTag("x").RemoveField("x")
+	assert.Len(t, c.TagList(), 1)
+}
+
 func TestPrecision(t *testing.T) {
 	p := NewPointWithMeasurement("test")
 	p.AddTag("id", "10")
@@ -0,0 +1,207 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// PointProcessor transforms points before they are written, see Options.AddPointProcessor.
+type PointProcessor interface {
+	// Process returns the point to be written, which can be p, possibly modified, or another point.
+	// Returning nil drops the point.
+	Process(p *Point) *Point
+}
+
+// PointProcessorFunc is an adapter allowing to use a function as a PointProcessor
+type PointProcessorFunc func(p *Point) *Point
+
+// Process calls f(p)
+func (f PointProcessorFunc) Process(p *Point) *Point {
+	return f(p)
+}
+
+// ProcessPoint applies processors to a copy of point p in the order they are listed.
+// It returns nil if a processor dropped the point, and p itself if there are no processors.
+func ProcessPoint(p *Point, processors []PointProcessor) *Point {
+	if len(processors) == 0 {
+		return p
+	}
+	p = p.Copy()
+	for _, processor := range processors {
+		if p = processor.Process(p); p == nil {
+			return nil
+		}
+	}
+	return p
+}
+
+// RenameMeasurement creates processor renaming measurements, mapping holds new names by old names.
+func RenameMeasurement(mapping map[string]string) PointProcessor {
+	return PointProcessorFunc(func(p *Point) *Point {
+		if name, ok := mapping[p.Name()]; ok {
+			p.SetMeasurement(name)
+		}
+		return p
+	})
+}
+
+// RenameTags creates processor renaming tag keys, mapping holds new keys by old keys.
+// A renamed tag replaces a tag with the new key, if the point already has such tag.
+func RenameTags(mapping map[string]string) PointProcessor {
+	return PointProcessorFunc(func(p *Point) *Point {
+		for _, tag := range append([]*lp.Tag(nil), p.TagList()...) {
+			if key, ok := mapping[tag.Key]; ok {
+				p.RemoveTag(tag.Key)
+				p.AddTag(key, tag.Value)
+			}
+		}
+		return p
+	})
+}
+
+// DropTags creates processor removing tags with given keys
+func DropTags(keys ...string) PointProcessor {
+	drop := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		drop[k] = true
+	}
+	return filterTags(func(key string) bool { return !drop[key] })
+}
+
+// KeepTags creates processor removing all tags except tags with given keys
+func KeepTags(keys ...string) PointProcessor {
+	keep := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		keep[k] = true
+	}
+	return filterTags(func(key string) bool { return keep[key] })
+}
+
+// filterTags creates processor removing tags for which keep returns false
+func filterTags(keep func(key string) bool) PointProcessor {
+	return PointProcessorFunc(func(p *Point) *Point {
+		for _, tag := range append([]*lp.Tag(nil), p.TagList()...) {
+			if !keep(tag.Key) {
+				p.RemoveTag(tag.Key)
+			}
+		}
+		return p
+	})
+}
+
+// AddTags creates processor adding tags to points for which predicate returns true, or to all points if predicate is nil.
+// Existing tags with the same keys are overwritten.
+func AddTags(tags map[string]string, predicate func(p *Point) bool) PointProcessor {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return PointProcessorFunc(func(p *Point) *Point {
+		if predicate == nil || predicate(p) {
+			for _, k := range keys {
+				p.AddTag(k, tags[k])
+			}
+			p.SortTags()
+		}
+		return p
+	})
+}
+
+// DropIf creates processor dropping points for which predicate returns true
+func DropIf(predicate func(p *Point) bool) PointProcessor {
+	return PointProcessorFunc(func(p *Point) *Point {
+		if predicate(p) {
+			return nil
+		}
+		return p
+	})
+}
+
+// Sample creates processor writing at most one point of each series (measurement and tag set) per interval.
+// Intervals are measured by timestamps of points, or by the current time for points without timestamp.
+// Other points of the series within the interval, and older points, are dropped. The processor remembers the last written time of each series.
+// Series without points for the interval (measured by the current time) are forgotten, so that memory is not held by series
+// which are no longer written. The next point of a forgotten series is written.
+func Sample(interval time.Duration) PointProcessor {
+	return &sampler{interval: interval, series: make(map[string]*sampledSeries), now: time.Now}
+}
+
+// sampler is PointProcessor keeping a point per series per interval
+type sampler struct {
+	interval time.Duration
+	mu       sync.Mutex
+	series   map[string]*sampledSeries
+	// swept is the time of the last removal of inactive series
+	swept time.Time
+	now   func() time.Time
+}
+
+// sampledSeries holds state of a series
+type sampledSeries struct {
+	// last is the time of the last written point
+	last time.Time
+	// seen is the current time when the last point of the series was processed
+	seen time.Time
+}
+
+// Process fulfils PointProcessor interface
+func (s *sampler) Process(p *Point) *Point {
+	now := s.now()
+	ts := p.Time()
+	if ts.IsZero() {
+		ts = now
+	}
+	key := seriesKey(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	if series, ok := s.series[key]; ok {
+		series.seen = now
+		if ts.Sub(series.last) < s.interval {
+			return nil
+		}
+		series.last = ts
+		return p
+	}
+	s.series[key] = &sampledSeries{last: ts, seen: now}
+	return p
+}
+
+// sweep removes series without points for the interval, it runs at most once per interval.
+// s.mu must be held.
+func (s *sampler) sweep(now time.Time) {
+	if now.Sub(s.swept) < s.interval {
+		return
+	}
+	s.swept = now
+	for key, series := range s.series {
+		if now.Sub(series.seen) >= s.interval {
+			delete(s.series, key)
+		}
+	}
+}
+
+// seriesKey returns identification of series of point p
+func seriesKey(p *Point) string {
+	tags := make([]string, 0, len(p.TagList()))
+	for _, tag := range p.TagList() {
+		tags = append(tags, tag.Key+"="+tag.Value)
+	}
+	sort.Strings(tags)
+	var sb strings.Builder
+	sb.WriteString(p.Name())
+	for _, tag := range tags {
+		sb.WriteByte(',')
+		sb.WriteString(tag)
+	}
+	return sb.String()
+}
@@ -0,0 +1,122 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tagMap(p *Point) map[string]string {
+	tags := make(map[string]string)
+	for _, t := range p.TagList() {
+		tags[t.Key] = t.Value
+	}
+	return tags
+}
+
+func TestProcessPoint(t *testing.T) {
+	p := NewPointWithMeasurement("cpu").AddTag("host", "a").AddTag("pid", "1").AddTag("region", "eu").AddFloatField("v", 1)
+	assert.Same(t, p, ProcessPoint(p, nil))
+
+	processors := []PointProcessor{
+		RenameMeasurement(map[string]string{"cpu": "system_cpu"}),
+		DropTags("pid"),
+		RenameTags(map[string]string{"region": "zone", "none": "x"}),
+		AddTags(map[string]string{"env": "prod"}, func(p *Point) bool { return tagMap(p)["zone"] == "eu" }),
+		AddTags(map[string]string{"dc": "1"}, func(p *Point) bool { return tagMap(p)["zone"] == "us" }),
+	}
+	r := ProcessPoint(p, processors)
+	require.NotNil(t, r)
+	assert.Equal(t, "system_cpu", r.Name())
+	assert.Equal(t, map[string]string{"host": "a", "env": "prod", "zone": "eu"}, tagMap(r))
+	// original point is not modified
+	assert.Equal(t, "cpu", p.Name())
+	assert.Len(t, p.TagList(), 3)
+
+	r = ProcessPoint(p, []PointProcessor{KeepTags("host", "none"), AddTags(map[string]string{"b": "2", "a": "1"}, nil)})
+	require.NotNil(t, r)
+	require.Len(t, r.TagList(), 3)
+	assert.Equal(t, []string{"a", "b", "host"}, []string{r.TagList()[0].Key, r.TagList()[1].Key, r.TagList()[2].Key})
+
+	dropped := 0
+	processors = []PointProcessor{
+		DropIf(func(p *Point) bool { return tagMap(p)["host"] == "a" }),
+		PointProcessorFunc(func(p *Point) *Point {
+			dropped++
+			return p
+		}),
+	}
+	assert.Nil(t, ProcessPoint(p, processors))
+	assert.Equal(t, 0, dropped)
+	assert.NotNil(t, ProcessPoint(NewPointWithMeasurement("cpu").AddTag("host", "b").AddFloatField("v", 1), processors))
+	assert.Equal(t, 1, dropped)
+}
+
+func TestSample(t *testing.T) {
+	sample := Sample(time.Second)
+	start := time.Unix(1600000000, 0)
+	var kept []string
+	for i := 0; i < 10; i++ {
+		for _, host := range []string{"a", "b"} {
+			p := NewPointWithMeasurement("cpu").AddTag("host", host).AddIntField("v", int64(i)).SetTime(start.Add(time.Duration(i) * 300 * time.Millisecond))
+			if sample.Process(p) != nil {
+				kept = append(kept, fmt.Sprintf("%s%d", host, i))
+			}
+		}
+	}
+	assert.Equal(t, []string{"a0", "b0", "a4", "b4", "a8", "b8"}, kept)
+	// older point is dropped
+	assert.Nil(t, sample.Process(NewPointWithMeasurement("cpu").AddTag("host", "a").AddIntField("v", 0).SetTime(start)))
+	// points without timestamp use the current time
+	assert.NotNil(t, sample.Process(NewPointWithMeasurement("cpu").AddIntField("v", 0)))
+	assert.Nil(t, sample.Process(NewPointWithMeasurement("cpu").AddIntField("v", 0)))
+}
+
+func TestSampleForgetsInactiveSeries(t *testing.T) {
+	sample := Sample(time.Second).(*sampler)
+	now := time.Unix(1600000000, 0)
+	sample.now = func() time.Time { return now }
+	start := now
+	for i := 0; i < 100; i++ {
+		assert.NotNil(t, sample.Process(NewPointWithMeasurement("cpu").AddTag("host", fmt.Sprint(i)).AddIntField("v", 1).SetTime(start)))
+	}
+	assert.Len(t, sample.series, 100)
+
+	// active series is kept
+	now = now.Add(600 * time.Millisecond)
+	assert.Nil(t, sample.Process(NewPointWithMeasurement("cpu").AddTag("host", "0").AddIntField("v", 1).SetTime(start)))
+	now = now.Add(600 * time.Millisecond)
+	assert.Nil(t, sample.Process(NewPointWithMeasurement("cpu").AddTag("host", "0").AddIntField("v", 1).SetTime(start)))
+	assert.Len(t, sample.series, 1)
+	// forgotten series is written again
+	assert.NotNil(t, sample.Process(NewPointWithMeasurement("cpu").AddTag("host", "1").AddIntField("v", 1).SetTime(start)))
+	assert.Len(t, sample.series, 2)
+}
+
+func TestSampleConcurrent(t *testing.T) {
+	sample := Sample(time.Hour)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	kept := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sample.Process(NewPointWithMeasurement("m").AddTag("t", "x").AddIntField("v", 1)) != nil {
+				mu.Lock()
+				kept++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, kept)
+}
@@ -0,0 +1,136 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"math/rand"
+	"net/http"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+)
+
+// RetryStrategy controls retrying of failed writes of the non-blocking WriteAPI.
+// A delay sent by the server in the Retry-After header takes precedence over RetryDelay.
+// RetryStrategy can be called from multiple write workers (see Options.SetWriteWorkers), but the calls are serialized.
+// It doesn't need to be safe for concurrent use unless it is shared by multiple WriteAPI instances.
+type RetryStrategy interface {
+	// Retryable returns true if a write that failed with err should be retried
+	Retryable(err *http2.Error) bool
+	// RetryDelay returns delay in milliseconds before the next write attempt.
+	// attempts is number of consecutive failed retries, 0 after the first failure,
+	// previousDelay is the current retry delay, which is the retry interval of Options until a write fails.
+	RetryDelay(attempts uint, previousDelay uint) uint
+}
+
+// IsRetryableError returns true for connection errors and HTTP status codes 429 and higher.
+// It is used by the built-in retry strategies.
+func IsRetryableError(err *http2.Error) bool {
+	return err.StatusCode == 0 || err.StatusCode >= http.StatusTooManyRequests
+}
+
+// ExponentialRetryStrategy computes retry delay as a random value within the interval
+// [Interval * Base^attempts, Interval * Base^(attempts+1)], limited by MaxInterval.
+// It is the default strategy, created from retry settings of Options.
+type ExponentialRetryStrategy struct {
+	// Interval is the first retry delay in milliseconds
+	Interval uint
+	// Base is the base for the exponential retry delay
+	Base uint
+	// MaxInterval is the maximum retry delay in milliseconds
+	MaxInterval uint
+}
+
+// NewExponentialRetryStrategy creates ExponentialRetryStrategy
+func NewExponentialRetryStrategy(intervalMs, base, maxIntervalMs uint) *ExponentialRetryStrategy {
+	return &ExponentialRetryStrategy{Interval: intervalMs, Base: base, MaxInterval: maxIntervalMs}
+}
+
+// Retryable returns true for connection errors and HTTP status codes 429 and higher
+func (s *ExponentialRetryStrategy) Retryable(err *http2.Error) bool {
+	return IsRetryableError(err)
+}
+
+// RetryDelay returns exponentially growing random delay
+func (s *ExponentialRetryStrategy) RetryDelay(attempts uint, _ uint) uint {
+	minDelay := int(s.Interval * pow(s.Base, attempts))
+	maxDelay := int(s.Interval * pow(s.Base, attempts+1))
+	diff := maxDelay - minDelay
+	if diff <= 0 { //check overflows
+		return s.MaxInterval
+	}
+	retryDelay := uint(rand.Intn(diff) + minDelay)
+	if retryDelay > s.MaxInterval {
+		retryDelay = s.MaxInterval
+	}
+	return retryDelay
+}
+
+// DecorrelatedJitterRetryStrategy computes retry delay as a random value within the interval
+// [Interval, previous delay * 3], limited by MaxInterval.
+// Random delays spread retries of many clients, e.g. when they hit rate limits of InfluxDB Cloud at the same time.
+type DecorrelatedJitterRetryStrategy struct {
+	// Interval is the minimum retry delay in milliseconds
+	Interval uint
+	// MaxInterval is the maximum retry delay in milliseconds
+	MaxInterval uint
+}
+
+// NewDecorrelatedJitterRetryStrategy creates DecorrelatedJitterRetryStrategy
+func NewDecorrelatedJitterRetryStrategy(intervalMs, maxIntervalMs uint) *DecorrelatedJitterRetryStrategy {
+	return &DecorrelatedJitterRetryStrategy{Interval: intervalMs, MaxInterval: maxIntervalMs}
+}
+
+// Retryable returns true for connection errors and HTTP status codes 429 and higher
+func (s *DecorrelatedJitterRetryStrategy) Retryable(err *http2.Error) bool {
+	return IsRetryableError(err)
+}
+
+// RetryDelay returns random delay based on the previous delay
+func (s *DecorrelatedJitterRetryStrategy) RetryDelay(_ uint, previousDelay uint) uint {
+	if previousDelay < s.Interval {
+		previousDelay = s.Interval
+	}
+	maxDelay := previousDelay * 3
+	if maxDelay < previousDelay || maxDelay > s.MaxInterval { //check overflows
+		maxDelay = s.MaxInterval
+	}
+	if maxDelay <= s.Interval {
+		return maxDelay
+	}
+	return s.Interval + uint(rand.Int63n(int64(maxDelay-s.Interval+1)))
+}
+
+// ConstantRetryStrategy waits the same time before each retry
+type ConstantRetryStrategy struct {
+	// Interval is the retry delay in milliseconds
+	Interval uint
+}
+
+// NewConstantRetryStrategy creates ConstantRetryStrategy
+func NewConstantRetryStrategy(intervalMs uint) *ConstantRetryStrategy {
+	return &ConstantRetryStrategy{Interval: intervalMs}
+}
+
+// Retryable returns true for connection errors and HTTP status codes 429 and higher
+func (s *ConstantRetryStrategy) Retryable(err *http2.Error) bool {
+	return IsRetryableError(err)
+}
+
+// RetryDelay returns Interval
+func (s *ConstantRetryStrategy) RetryDelay(_ uint, _ uint) uint {
+	return s.Interval
+}
+
+// pow computes x**y
+func pow(x, y uint) uint {
+	p := uint(1)
+	if y == 0 {
+		return 1
+	}
+	for i := uint(1); i <= y; i++ {
+		p = p * x
+	}
+	return p
+}
@@ -0,0 +1,75 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/http"
+)
+
+func assertBetween(t *testing.T, val, min, max uint) {
+	t.Helper()
+	assert.True(t, val >= min && val <= max, fmt.Sprintf("%d is outside <%d;%d>", val, min, max))
+}
+
+func TestPow(t *testing.T) {
+	assert.EqualValues(t, 1, pow(10, 0))
+	assert.EqualValues(t, 10, pow(10, 1))
+	assert.EqualValues(t, 4, pow(2, 2))
+	assert.EqualValues(t, 1, pow(1, 2))
+	assert.EqualValues(t, 125, pow(5, 3))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, IsRetryableError(&http.Error{Err: errors.New("connection refused")}))
+	assert.True(t, IsRetryableError(&http.Error{StatusCode: 429}))
+	assert.True(t, IsRetryableError(&http.Error{StatusCode: 503}))
+	assert.False(t, IsRetryableError(&http.Error{StatusCode: 400}))
+	assert.False(t, IsRetryableError(&http.Error{StatusCode: 401}))
+}
+
+func TestExponentialRetryStrategy(t *testing.T) {
+	s := NewExponentialRetryStrategy(5_000, 2, 125_000)
+	assertBetween(t, s.RetryDelay(0, 0), 5_000, 10_000)
+	assertBetween(t, s.RetryDelay(1, 0), 10_000, 20_000)
+	assertBetween(t, s.RetryDelay(2, 0), 20_000, 40_000)
+	assertBetween(t, s.RetryDelay(3, 0), 40_000, 80_000)
+	assertBetween(t, s.RetryDelay(4, 0), 80_000, 125_000)
+	for i := uint(5); i < 200; i++ {
+		assert.EqualValues(t, 125_000, s.RetryDelay(i, 0))
+	}
+}
+
+func TestDecorrelatedJitterRetryStrategy(t *testing.T) {
+	s := NewDecorrelatedJitterRetryStrategy(1_000, 60_000)
+	delay := uint(0)
+	for i := uint(0); i < 100; i++ {
+		prev := delay
+		if prev < 1_000 {
+			prev = 1_000
+		}
+		delay = s.RetryDelay(i, delay)
+		max := prev * 3
+		if max > 60_000 {
+			max = 60_000
+		}
+		assertBetween(t, delay, 1_000, max)
+	}
+	assert.EqualValues(t, 60_000, NewDecorrelatedJitterRetryStrategy(100_000, 60_000).RetryDelay(0, 0))
+	assertBetween(t, s.RetryDelay(0, ^uint(0)), 1_000, 60_000)
+}
+
+func TestConstantRetryStrategy(t *testing.T) {
+	s := NewConstantRetryStrategy(2_000)
+	assert.EqualValues(t, 2_000, s.RetryDelay(0, 0))
+	assert.EqualValues(t, 2_000, s.RetryDelay(10, 50_000))
+	assert.True(t, s.Retryable(&http.Error{StatusCode: 503}))
+	assert.False(t, s.Retryable(&http.Error{StatusCode: 400}))
+}
@@ -0,0 +1,77 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import "time"
+
+// Stats holds statistics of the non-blocking WriteAPI.
+// Counters are cumulative since the WriteAPI was created, gauges hold the current state.
+type Stats struct {
+	// PointsAccepted is number of points accepted by WritePoint and TryWritePoint
+	PointsAccepted uint64
+	// LinesAccepted is number of lines accepted into the buffer, both points and records.
+	// Lines dropped from the buffer by OverflowDropOldest are not counted.
+	LinesAccepted uint64
+	// BytesAccepted is size of lines accepted into the buffer in bytes, lines dropped by OverflowDropOldest are not counted
+	BytesAccepted uint64
+	// DroppedPoints is number of points dropped by the overflow policy
+	DroppedPoints uint64
+
+	// BatchesWritten is number of batches written to the server,
+	// including batches partially rejected because of invalid data
+	BatchesWritten uint64
+	// LinesWritten is number of lines written to the server, lines rejected because of invalid data are not counted
+	LinesWritten uint64
+	// BytesWritten is size of lines written to the server in bytes
+	BytesWritten uint64
+	// Retries is number of write attempts of batches taken from the retry queue
+	Retries uint64
+	// FailedRequests is number of write requests which failed
+	FailedRequests uint64
+	// DiscardedBatches is number of batches discarded because of errors or the full retry queue, see DiscardReason
+	DiscardedBatches uint64
+	// ExpiredBatches is number of batches discarded because they were not written within MaxRetryTime
+	ExpiredBatches uint64
+
+	// RetryQueueBatches is number of batches in the retry queue
+	RetryQueueBatches int
+	// RetryQueueBytes is size of batches in the retry queue in bytes
+	RetryQueueBytes int64
+
+	// LastError is the last error of a write request, nil if no request failed
+	LastError error
+	// LastErrorTime is time of the last failed write request
+	LastErrorTime time.Time
+	// LastWriteTime is time of the last successful write request
+	LastWriteTime time.Time
+
+	// RequestLatency is histogram of durations of write requests
+	RequestLatency LatencyHistogram
+
+	// CircuitState is the current state of the circuit breaker
+	CircuitState CircuitState
+}
+
+// LatencyHistogram is a histogram of durations.
+// Counts[i] is number of durations less than or equal to Bounds[i] and greater than the previous bound,
+// the last item of Counts is number of durations greater than the last bound.
+type LatencyHistogram struct {
+	// Bounds holds upper bounds of buckets in ascending order
+	Bounds []time.Duration
+	// Counts holds number of durations in buckets, it has one more item than Bounds
+	Counts []uint64
+	// Count is total number of durations
+	Count uint64
+	// Sum is total of all durations
+	Sum time.Duration
+}
+
+// Mean returns average duration, or zero if there are no durations
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
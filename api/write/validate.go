@@ -0,0 +1,172 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationLevel defines which checks are performed on points and records before writing
+type ValidationLevel int
+
+const (
+	// ValidationNone performs no checks, invalid data are rejected by the server. This is the default.
+	ValidationNone ValidationLevel = iota
+	// ValidationBasic rejects data the server would reject: syntax errors of records, empty measurements, points without fields,
+	// empty keys, NaN or Inf float values, strings which are not valid UTF-8 and timestamps out of the range supported by InfluxDB.
+	ValidationBasic
+	// ValidationStrict rejects also tag and field keys beginning with '_', which are reserved for system use, keys named 'time'
+	// and tags with empty value.
+	ValidationStrict
+)
+
+var (
+	// MinTime is the minimum timestamp supported by InfluxDB
+	MinTime = time.Unix(0, math.MinInt64+2)
+	// MaxTime is the maximum timestamp supported by InfluxDB
+	MaxTime = time.Unix(0, math.MaxInt64-1)
+)
+
+// InvalidData describes a point or record which was not written because it is invalid
+type InvalidData struct {
+	// Index of the point or record among points or records passed to a write call, starting from 0
+	Index int
+	// Line holds the invalid record, it is empty for points
+	Line string
+	// Err describes why the point or record is invalid
+	Err error
+}
+
+// ValidationError is returned or reported by write APIs for points or records, which were not written because they are invalid.
+// Valid points and records passed to the same write call are written.
+type ValidationError struct {
+	// Invalid holds the invalid points or records in the order they were written
+	Invalid []InvalidData
+}
+
+// Error fulfils error interface
+func (e *ValidationError) Error() string {
+	if len(e.Invalid) == 0 {
+		return "no invalid data"
+	}
+	first := e.Invalid[0]
+	kind := "point"
+	if first.Line != "" {
+		kind = "record"
+	}
+	msg := fmt.Sprintf("invalid %s %d: %v", kind, first.Index, first.Err)
+	if len(e.Invalid) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Invalid)-1)
+	}
+	return msg
+}
+
+// Unwrap returns the cause of the first invalid point or record
+func (e *ValidationError) Unwrap() error {
+	if len(e.Invalid) == 0 {
+		return nil
+	}
+	return e.Invalid[0].Err
+}
+
+// ValidatePoint checks point p according to level and returns error describing the first problem found
+func ValidatePoint(p *Point, level ValidationLevel) error {
+	if level == ValidationNone {
+		return nil
+	}
+	if p.Name() == "" {
+		return errors.New("empty measurement")
+	}
+	if !utf8.ValidString(p.Name()) {
+		return errors.New("measurement is not valid UTF-8")
+	}
+	for _, t := range p.TagList() {
+		if err := validateKey("tag", t.Key, level); err != nil {
+			return err
+		}
+		if !utf8.ValidString(t.Value) {
+			return fmt.Errorf("value of tag '%s' is not valid UTF-8", t.Key)
+		}
+		if level >= ValidationStrict && t.Value == "" {
+			return fmt.Errorf("empty value of tag '%s'", t.Key)
+		}
+	}
+	if len(p.FieldList()) == 0 {
+		return errors.New("no fields")
+	}
+	for _, f := range p.FieldList() {
+		if err := validateKey("field", f.Key, level); err != nil {
+			return err
+		}
+		switch v := f.Value.(type) {
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("field '%s': unsupported float value %v", f.Key, v)
+			}
+		case float32:
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				return fmt.Errorf("field '%s': unsupported float value %v", f.Key, v)
+			}
+		case string:
+			if !utf8.ValidString(v) {
+				return fmt.Errorf("field '%s': value is not valid UTF-8", f.Key)
+			}
+		}
+	}
+	if ts := p.Time(); !ts.IsZero() && (ts.Before(MinTime) || ts.After(MaxTime)) {
+		return fmt.Errorf("timestamp %s is out of range", ts.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// validateKey checks tag or field key
+func validateKey(kind, key string, level ValidationLevel) error {
+	if key == "" {
+		return fmt.Errorf("empty %s key", kind)
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("%s key %q is not valid UTF-8", kind, key)
+	}
+	if level >= ValidationStrict {
+		if strings.HasPrefix(key, "_") {
+			return fmt.Errorf("%s key '%s' begins with '_'", kind, key)
+		}
+		if key == "time" {
+			return fmt.Errorf("%s key 'time' is not allowed", kind)
+		}
+	}
+	return nil
+}
+
+// ValidateRecord checks line protocol record according to level and returns error describing the first problem found.
+// The record can hold more lines, timestamps are parsed in precision. Syntax errors are reported as *ParseError.
+func ValidateRecord(record string, precision time.Duration, level ValidationLevel) error {
+	if level == ValidationNone {
+		return nil
+	}
+	multiline := strings.Contains(strings.TrimRight(record, "\n"), "\n")
+	parser := NewLineProtocolParser(strings.NewReader(record), precision)
+	for {
+		p, err := parser.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ValidatePoint(p, level); err != nil {
+			if multiline {
+				return fmt.Errorf("line %d: %w", parser.line, err)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePoint(t *testing.T) {
+	ts := time.Unix(1600000000, 0)
+	testCases := []struct {
+		name   string
+		point  *Point
+		basic  string
+		strict string
+	}{
+		{"valid", NewPointWithMeasurement("m").AddTag("t", "a").AddFloatField("v", 1).SetTime(ts), "", ""},
+		{"empty measurement", NewPointWithMeasurement("").AddFloatField("v", 1), "empty measurement", "empty measurement"},
+		{"invalid measurement", NewPointWithMeasurement("m\xff").AddFloatField("v", 1), "measurement is not valid UTF-8", "measurement is not valid UTF-8"},
+		{"no fields", NewPointWithMeasurement("m").AddTag("t", "a"), "no fields", "no fields"},
+		{"empty tag key", NewPointWithMeasurement("m").AddTag("", "a").AddFloatField("v", 1), "empty tag key", "empty tag key"},
+		{"empty field key", NewPointWithMeasurement("m").AddFloatField("", 1), "empty field key", "empty field key"},
+		{"invalid tag value", NewPointWithMeasurement("m").AddTag("t", "\xc3\x28").AddFloatField("v", 1),
+			"value of tag 't' is not valid UTF-8", "value of tag 't' is not valid UTF-8"},
+		{"invalid string", NewPointWithMeasurement("m").AddStringField("s", "\xff"), "field 's': value is not valid UTF-8", "field 's': value is not valid UTF-8"},
+		{"NaN", NewPointWithMeasurement("m").AddFloatField("v", math.NaN()), "field 'v': unsupported float value NaN", "field 'v': unsupported float value NaN"},
+		{"Inf", NewPointWithMeasurement("m").AddField("v", float32(math.Inf(-1))), "field 'v': unsupported float value -Inf", "field 'v': unsupported float value -Inf"},
+		{"time out of range", NewPointWithMeasurement("m").AddFloatField("v", 1).SetTime(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)),
+			"timestamp 2300-01-01T00:00:00Z is out of range", "timestamp 2300-01-01T00:00:00Z is out of range"},
+		{"reserved tag key", NewPointWithMeasurement("m").AddTag("_field", "a").AddFloatField("v", 1), "", "tag key '_field' begins with '_'"},
+		{"reserved field key", NewPointWithMeasurement("m").AddFloatField("_v", 1), "", "field key '_v' begins with '_'"},
+		{"time key", NewPointWithMeasurement("m").AddFloatField("time", 1), "", "field key 'time' is not allowed"},
+		{"empty tag value", NewPointWithMeasurement("m").AddTag("t", "").AddFloatField("v", 1), "", "empty value of tag 't'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NoError(t, ValidatePoint(tc.point, ValidationNone))
+			for level, expected := range map[ValidationLevel]string{ValidationBasic: tc.basic, ValidationStrict: tc.strict} {
+				err := ValidatePoint(tc.point, level)
+				if expected == "" {
+					assert.NoError(t, err)
+				} else if assert.Error(t, err) {
+					assert.Equal(t, expected, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	assert.NoError(t, ValidateRecord("m f=", time.Nanosecond, ValidationNone))
+	assert.NoError(t, ValidateRecord("m,t=a f=1 1600000000\n\n# comment\nm f=\"a\nb\"", time.Second, ValidationStrict))
+
+	err := ValidateRecord("m f=", time.Nanosecond, ValidationBasic)
+	var perr *ParseError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 1, perr.Line)
+
+	err = ValidateRecord("m f=1\nm f=\nm f=2", time.Nanosecond, ValidationBasic)
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 2, perr.Line)
+
+	err = ValidateRecord("m f=1 99999999999", time.Second, ValidationBasic)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is out of range")
+
+	// overflows nanoseconds
+	err = ValidateRecord("m f=1 9300000000000", time.Millisecond, ValidationBasic)
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, "timestamp out of range", perr.Msg)
+	err = ValidateRecord("m f=1 9300000000000000", time.Microsecond, ValidationBasic)
+	require.True(t, errors.As(err, &perr))
+	assert.NoError(t, ValidateRecord("m f=1 9200000000000", time.Millisecond, ValidationBasic))
+
+	err = ValidateRecord("m f=1\nm _f=1", time.Second, ValidationStrict)
+	require.Error(t, err)
+	assert.Equal(t, "line 2: field key '_f' begins with '_'", err.Error())
+}
+
+func TestValidationError(t *testing.T) {
+	cause := errors.New("no fields")
+	err := &ValidationError{Invalid: []InvalidData{{Index: 1, Err: cause}}}
+	assert.Equal(t, "invalid point 1: no fields", err.Error())
+	assert.True(t, errors.Is(err, cause))
+	err.Invalid = append(err.Invalid, InvalidData{Index: 3, Line: "m", Err: cause})
+	assert.Equal(t, "invalid point 1: no fields (and 1 more)", err.Error())
+	err = &ValidationError{Invalid: []InvalidData{{Index: 0, Line: "m", Err: cause}}}
+	assert.Equal(t, "invalid record 0: no fields", err.Error())
+}
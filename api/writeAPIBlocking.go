@@ -5,7 +5,10 @@
 package api
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"io"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,7 +23,8 @@ import (
 //
 // Implicit batching is enabled with EnableBatching(). In this mode, each call to WritePoint or WriteRecord adds a line
 // to internal buffer. If length of the buffer is equal to the batch-size (set in write.Options), the buffer is sent to the server
-// and the result of the operation is returned.
+// and the result of the operation is returned. The buffer is also sent when its size reaches max-batch-bytes (set in write.Options),
+// or before adding a line which would exceed it.
 // When a point is written to the buffer, nil error is always returned.
 // Flush() can be used to trigger sending of batch when it doesn't have the batch-size.
 //
@@ -34,11 +38,14 @@ type WriteAPIBlocking interface {
 	// WriteRecord writes lines without implicit batching by default, batch is created from given number of records.
 	// Automatic batching can be enabled by EnableBatching()
 	// Individual arguments can also be batches (multiple records separated by newline).
-	// Non-blocking alternative is available in the WriteAPI interface
+	// Non-blocking alternative is available in the WriteAPI interface.
+	// Lines rejected by the server because of invalid data are reported as http.Error with nested *write.WriteError.
+	// Records rejected by validation (see write.Options.SetValidationLevel) are reported as *write.ValidationError, valid records are written.
 	WriteRecord(ctx context.Context, line ...string) error
 	// WritePoint data point into bucket.
 	// WriteRecord writes points without implicit batching by default, batch is created from given number of points.
 	// Automatic batching can be enabled by EnableBatching().
+	// Invalid points are reported as *write.ValidationError, valid points are written.
 	// Non-blocking alternative is available in the WriteAPI interface
 	WritePoint(ctx context.Context, point ...*write.Point) error
 	// EnableBatching turns on implicit batching
@@ -46,8 +53,35 @@ type WriteAPIBlocking interface {
 	EnableBatching()
 	// Flush forces write of buffer if batching is enabled, even buffer doesn't have the batch-size.
 	Flush(ctx context.Context) error
+	// WriteFrom writes line protocol read from reader, one record per line, into bucket.
+	// Lines are streamed in batches limited by the batch-size and max-batch-bytes (set in write.Options),
+	// so the whole input is never held in memory. Batches are compressed on the fly when gzip is enabled in write.Options.
+	// Empty lines and comments are skipped. The implicit batching buffer is not used.
+	// Lines rejected by the server or by validation because of invalid data are counted as failed and writing continues,
+	// other errors stop writing and are returned together with the summary of lines written so far.
+	WriteFrom(ctx context.Context, reader io.Reader) (WriteSummary, error)
+	// SetWriteProgressCallback sets callback notified about progress of WriteFrom after each batch
+	SetWriteProgressCallback(cb WriteProgressCallback)
+}
+
+// WriteSummary summarizes lines written by WriteAPIBlocking.WriteFrom
+type WriteSummary struct {
+	// Lines is number of lines read, excluding empty lines and comments
+	Lines int64
+	// LinesWritten is number of lines accepted by the server
+	LinesWritten int64
+	// LinesFailed is number of lines rejected by the server or not written because of an error
+	LinesFailed int64
+	// Batches is number of batches sent to the server
+	Batches int64
+	// BytesRead is number of bytes read from the reader, up to the end of the last line of the last sent batch
+	BytesRead int64
 }
 
+// WriteProgressCallback is synchronously notified about progress of WriteAPIBlocking.WriteFrom after each batch.
+// err is the error of the batch, nil if the batch was written.
+type WriteProgressCallback func(summary WriteSummary, err error)
+
 // writeAPIBlocking implements WriteAPIBlocking interface
 type writeAPIBlocking struct {
 	service      *iwrite.Service
@@ -55,7 +89,10 @@ type writeAPIBlocking struct {
 	// more appropriate Bool type from sync/atomic cannot be used because it is available since go 1.19
 	batching int32
 	batch    []string
-	mu       sync.Mutex
+	// size of the batch in bytes, including separators
+	batchBytes int
+	mu         sync.Mutex
+	progressCb WriteProgressCallback
 }
 
 // NewWriteAPIBlocking creates new instance of blocking write client for writing data to bucket belonging to org
@@ -70,6 +107,12 @@ func NewWriteAPIBlockingWithBatching(org string, bucket string, service http2.Se
 	return api
 }
 
+// NewWriteAPIBlockingV1 creates new instance of blocking write client for writing data to database db and retention policy rp
+// using the v1 compatibility endpoint. Empty rp means the default retention policy.
+func NewWriteAPIBlockingV1(db string, rp string, service http2.Service, writeOptions *write.Options) WriteAPIBlocking {
+	return &writeAPIBlocking{service: iwrite.NewServiceV1(db, rp, service, writeOptions), writeOptions: writeOptions}
+}
+
 func (w *writeAPIBlocking) EnableBatching() {
 	if atomic.LoadInt32(&w.batching) == 0 {
 		w.mu.Lock()
@@ -83,11 +126,19 @@ func (w *writeAPIBlocking) write(ctx context.Context, line string) error {
 	if atomic.LoadInt32(&w.batching) > 0 {
 		w.mu.Lock()
 		defer w.mu.Unlock()
+		var err error
+		maxBytes := int(w.writeOptions.MaxBatchBytes())
+		if maxBytes > 0 && len(w.batch) > 0 && w.batchBytes+len(line)+1 > maxBytes {
+			err = w.flush(ctx)
+		}
 		w.batch = append(w.batch, line)
-		if len(w.batch) == int(w.writeOptions.BatchSize()) {
-			return w.flush(ctx)
+		w.batchBytes += len(line) + 1
+		if len(w.batch) == int(w.writeOptions.BatchSize()) || (maxBytes > 0 && w.batchBytes >= maxBytes) {
+			if ferr := w.flush(ctx); ferr != nil {
+				return ferr
+			}
 		}
-		return nil
+		return err
 	}
 	err := w.service.WriteBatch(ctx, iwrite.NewBatch(line, w.writeOptions.MaxRetryTime()))
 	if err != nil {
@@ -100,15 +151,23 @@ func (w *writeAPIBlocking) WriteRecord(ctx context.Context, line ...string) erro
 	if len(line) == 0 {
 		return nil
 	}
-	return w.write(ctx, strings.Join(line, "\n"))
+	valid, verr := w.service.ValidateRecords(line...)
+	if len(valid) > 0 {
+		if err := w.write(ctx, strings.Join(valid, "\n")); err != nil {
+			return err
+		}
+	}
+	return verr
 }
 
 func (w *writeAPIBlocking) WritePoint(ctx context.Context, point ...*write.Point) error {
-	line, err := w.service.EncodePoints(point...)
-	if err != nil {
-		return err
+	line, verr := w.service.EncodePoints(w.service.ProcessPoints(point...)...)
+	if line != "" {
+		if err := w.write(ctx, line); err != nil {
+			return err
+		}
 	}
-	return w.write(ctx, line)
+	return verr
 }
 
 // flush is unsychronized helper for creating and sending batch
@@ -117,6 +176,7 @@ func (w *writeAPIBlocking) flush(ctx context.Context) error {
 	if len(w.batch) > 0 {
 		body := strings.Join(w.batch, "\n")
 		w.batch = w.batch[:0]
+		w.batchBytes = 0
 		b := iwrite.NewBatch(body, w.writeOptions.MaxRetryTime())
 		if err:= w.service.WriteBatch(ctx, b); err != nil {
 			return err
@@ -133,3 +193,98 @@ func (w *writeAPIBlocking) Flush(ctx context.Context) error {
 	}
 	return nil
 }
+
+// SetWriteProgressCallback sets callback notified about progress of WriteFrom after each batch
+func (w *writeAPIBlocking) SetWriteProgressCallback(cb WriteProgressCallback) {
+	w.progressCb = cb
+}
+
+// WriteFrom writes line protocol read from reader in batches
+func (w *writeAPIBlocking) WriteFrom(ctx context.Context, reader io.Reader) (WriteSummary, error) {
+	var summary WriteSummary
+	r := bufio.NewReader(reader)
+	batch := make([]string, 0, w.writeOptions.BatchSize())
+	batchBytes := 0
+	maxBytes := int(w.writeOptions.MaxBatchBytes())
+	// bytes read, including lines not sent yet
+	read := int64(0)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := w.writeLines(ctx, batch, &summary)
+		summary.BytesRead = read
+		batch = batch[:0]
+		batchBytes = 0
+		if w.progressCb != nil {
+			w.progressCb(summary, err)
+		}
+		var werr *write.WriteError
+		var verr *write.ValidationError
+		if err != nil && !errors.As(err, &werr) && !errors.As(err, &verr) {
+			return err
+		}
+		return nil
+	}
+	for {
+		line, rerr := r.ReadString('\n')
+		if rerr != nil && !errors.Is(rerr, io.EOF) {
+			return summary, rerr
+		}
+		text := strings.TrimSpace(line)
+		if text != "" && text[0] != '#' {
+			if maxBytes > 0 && len(batch) > 0 && batchBytes+len(text)+1 > maxBytes {
+				if err := send(); err != nil {
+					return summary, err
+				}
+			}
+			summary.Lines++
+			batch = append(batch, text)
+			batchBytes += len(text) + 1
+		}
+		read += int64(len(line))
+		if len(batch) == int(w.writeOptions.BatchSize()) || (maxBytes > 0 && batchBytes >= maxBytes) || rerr != nil {
+			if err := send(); err != nil {
+				return summary, err
+			}
+		}
+		if rerr != nil {
+			summary.BytesRead = read
+			return summary, nil
+		}
+	}
+}
+
+// countLines returns number of lines of batch, the last line doesn't need to end with new line
+func countLines(batch string) int {
+	batch = strings.TrimSuffix(batch, "\n")
+	if batch == "" {
+		return 0
+	}
+	return strings.Count(batch, "\n") + 1
+}
+
+// writeLines writes lines as a batch and updates summary
+func (w *writeAPIBlocking) writeLines(ctx context.Context, lines []string, summary *WriteSummary) error {
+	valid, verr := w.service.ValidateRecords(lines...)
+	summary.LinesFailed += int64(len(lines) - len(valid))
+	if len(valid) == 0 {
+		return verr
+	}
+	summary.Batches++
+	b := iwrite.NewBatch(strings.Join(valid, "\n"), w.writeOptions.MaxRetryTime())
+	perror := w.service.WriteBatch(ctx, b)
+	if perror == nil {
+		summary.LinesWritten += int64(len(valid))
+		return verr
+	}
+	// batch holds only lines not written, when it was partially written after splitting
+	failed := int64(countLines(b.Batch))
+	var werr *write.WriteError
+	if errors.As(perror, &werr) && len(werr.Lines) > 0 && len(werr.Lines) < len(valid) {
+		failed = int64(len(werr.Lines))
+	}
+	summary.LinesWritten += int64(len(valid)) - failed
+	summary.LinesFailed += failed
+	return perror
+}
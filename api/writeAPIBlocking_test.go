@@ -6,6 +6,9 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
@@ -158,3 +161,199 @@ func TestWriteBatchIng(t *testing.T) {
 	assert.Equal(t, 1, service.Requests())
 	require.Len(t, service.Lines(), 4)
 }
+
+func TestWriteBatchingMaxBytes(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlockingWithBatching("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(5).SetMaxBatchBytes(25))
+	// each record has 10 bytes including separator
+	for i := 0; i < 4; i++ {
+		err := writeAPI.WriteRecord(context.Background(), fmt.Sprintf("test f=%di", i))
+		require.Nil(t, err)
+	}
+	// first two records sent, before adding the third one
+	assert.Equal(t, 1, service.Requests())
+	assert.Len(t, service.Lines(), 2)
+	err := writeAPI.WriteRecord(context.Background(), "test f=4i")
+	require.Nil(t, err)
+	assert.Equal(t, 2, service.Requests())
+	assert.Len(t, service.Lines(), 4)
+	err = writeAPI.Flush(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, 3, service.Requests())
+	assert.Len(t, service.Lines(), 5)
+}
+
+func TestWriteBlockingRejectedLines(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetReplyError(&http2.Error{
+		StatusCode: 400,
+		Code:       "invalid",
+		Message:    "unable to parse 'm f=': missing field value",
+	})
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions())
+	err := writeAPI.WriteRecord(context.Background(), "m f=1", "m f=", "m f=3")
+	require.Error(t, err)
+	assert.Equal(t, "invalid: unable to parse 'm f=': missing field value", err.Error())
+	var werr *write.WriteError
+	require.True(t, errors.As(err, &werr))
+	assert.Equal(t, []write.RejectedLine{{Number: 2, Line: "m f=", Reason: "missing field value"}}, werr.Lines)
+}
+
+func TestWriteFieldTypes(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	registry := write.NewFieldTypeRegistry()
+	opts := write.DefaultOptions().SetStrictFieldTypes(true).SetFieldTypeRegistry(registry)
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, opts)
+	ctx := context.Background()
+
+	require.NoError(t, writeAPI.WritePoint(ctx, write.NewPointWithMeasurement("m").AddIntField("v", 1)))
+	err := writeAPI.WritePoint(ctx, write.NewPointWithMeasurement("m").AddField("v", struct{ a int }{1}))
+	require.Error(t, err)
+	assert.Equal(t, "invalid point 0: field 'v' of measurement 'm': unsupported value type struct { a int }", err.Error())
+	err = writeAPI.WritePoint(ctx, write.NewPointWithMeasurement("m").AddFloatField("v", 1.5))
+	var fieldErr *write.FieldTypeError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, write.FieldTypeInteger, fieldErr.Expected)
+	assert.Equal(t, []string{"m v=1i"}, service.Lines())
+
+	// without strict mode, unsupported values are written as strings
+	service.Close()
+	writeAPI = NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions())
+	require.NoError(t, writeAPI.WritePoint(ctx, write.NewPointWithMeasurement("m").AddField("v", struct{ a int }{1})))
+	assert.Equal(t, []string{`m v="{1}"`}, service.Lines())
+}
+
+func TestWriteBlockingValidation(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions().SetValidationLevel(write.ValidationStrict)
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, opts)
+	ctx := context.Background()
+
+	err := writeAPI.WriteRecord(ctx, "m f=1", "m _f=2", "m f=3")
+	var verr *write.ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []write.InvalidData{{Index: 1, Line: "m _f=2", Err: verr.Invalid[0].Err}}, verr.Invalid)
+	assert.Equal(t, []string{"m f=1", "m f=3"}, service.Lines())
+	service.Close()
+
+	err = writeAPI.WritePoint(ctx, write.NewPointWithMeasurement("m").AddStringField("s", "\xff"), write.NewPointWithMeasurement("m").AddIntField("i", 1))
+	assert.Equal(t, "invalid point 0: field 's': value is not valid UTF-8", err.Error())
+	assert.Equal(t, []string{"m i=1i"}, service.Lines())
+	service.Close()
+
+	// nothing is sent if all data are invalid
+	err = writeAPI.WriteRecord(ctx, "m")
+	assert.Error(t, err)
+	assert.Equal(t, 0, service.Requests())
+
+	summary, err := writeAPI.WriteFrom(ctx, strings.NewReader("m f=1\nm f=\nm time=1\nm f=2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, WriteSummary{Lines: 4, LinesWritten: 2, LinesFailed: 2, Batches: 1, BytesRead: 26}, summary)
+	assert.Equal(t, []string{"m f=1", "m f=2"}, service.Lines())
+}
+
+func TestWritePointProcessors(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions().
+		AddDefaultTag("env", "test").
+		AddPointProcessor(write.RenameMeasurement(map[string]string{"cpu": "system"})).
+		AddPointProcessor(write.DropTags("pid")).
+		AddPointProcessor(write.DropIf(func(p *write.Point) bool { return len(p.FieldList()) > 1 }))
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, opts)
+	p := write.NewPointWithMeasurement("cpu").AddTag("pid", "1").AddTag("host", "a").AddIntField("v", 1)
+	err := writeAPI.WritePoint(context.Background(), p,
+		write.NewPointWithMeasurement("cpu").AddIntField("v", 1).AddIntField("w", 2),
+		write.NewPointWithMeasurement("mem").AddIntField("v", 2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"system,env=test,host=a v=1i", "mem,env=test v=2i"}, service.Lines())
+	assert.Equal(t, "cpu", p.Name())
+	service.Close()
+
+	// nothing is sent when all points are dropped
+	require.NoError(t, writeAPI.WritePoint(context.Background(), write.NewPointWithMeasurement("cpu").AddIntField("v", 1).AddIntField("w", 2)))
+	assert.Equal(t, 0, service.Requests())
+}
+
+func TestWriteFrom(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(3).SetUseGZip(true))
+	var progress []WriteSummary
+	writeAPI.SetWriteProgressCallback(func(summary WriteSummary, err error) {
+		assert.NoError(t, err)
+		progress = append(progress, summary)
+	})
+	input := "# comment\ntest f=1i\n\ntest f=2i\r\ntest f=3i\ntest f=4i\n  test f=5i"
+	summary, err := writeAPI.WriteFrom(context.Background(), strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, WriteSummary{Lines: 5, LinesWritten: 5, Batches: 2, BytesRead: int64(len(input))}, summary)
+	assert.True(t, service.WasGzip())
+	assert.Equal(t, 2, service.Requests())
+	assert.Equal(t, []string{"test f=1i", "test f=2i", "test f=3i", "test f=4i", "test f=5i"}, service.Lines())
+	require.Len(t, progress, 2)
+	assert.Equal(t, WriteSummary{Lines: 3, LinesWritten: 3, Batches: 1, BytesRead: 42}, progress[0])
+	assert.Equal(t, summary, progress[1])
+}
+
+func TestWriteFromMaxBytes(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(5).SetMaxBatchBytes(25))
+	// each record has 10 bytes including separator
+	summary, err := writeAPI.WriteFrom(context.Background(), strings.NewReader(
+		"test f=0i\ntest f=1i\ntest f=2i\ntest f=3i\ntest f=4i\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), summary.Batches)
+	assert.Equal(t, int64(5), summary.LinesWritten)
+	assert.Equal(t, 3, service.Requests())
+	assert.Len(t, service.Lines(), 5)
+}
+
+func TestWriteFromErrors(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetRequestHandler(func(_ string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		if strings.Contains(string(b), "m f=\n") {
+			return &http2.Error{StatusCode: 400, Code: "invalid", Message: "unable to parse 'm f=': missing field value"}
+		}
+		if strings.Contains(string(b), "m f=6") {
+			return &http2.Error{StatusCode: 401, Code: "unauthorized", Message: "unauthorized access"}
+		}
+		return service.DecodeLines(strings.NewReader(string(b)))
+	})
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(2))
+	var errs []error
+	writeAPI.SetWriteProgressCallback(func(_ WriteSummary, err error) {
+		errs = append(errs, err)
+	})
+	summary, err := writeAPI.WriteFrom(context.Background(), strings.NewReader("m f=1\nm f=2\nm f=\nm f=4\nm f=5\nm f=6\nm f=7\nm f=8"))
+	require.Error(t, err)
+	assert.Equal(t, "unauthorized: unauthorized access", err.Error())
+	assert.Equal(t, WriteSummary{Lines: 6, LinesWritten: 3, LinesFailed: 3, Batches: 3, BytesRead: 35}, summary)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	var werr *write.WriteError
+	require.True(t, errors.As(errs[1], &werr))
+	assert.Equal(t, []write.RejectedLine{{Number: 1, Line: "m f=", Reason: "missing field value"}}, werr.Lines)
+	assert.Equal(t, err, errs[2])
+}
+
+func TestWriteFromPartiallyWrittenBatch(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetRequestHandler(func(_ string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		if strings.Contains(strings.TrimSuffix(string(b), "\n"), "\n") {
+			return &http2.Error{StatusCode: 413, Code: "request too large", Message: "payload too large"}
+		}
+		if strings.Contains(string(b), "m f=4") {
+			return &http2.Error{StatusCode: 503, Code: "unavailable", Message: "service unavailable"}
+		}
+		return service.DecodeLines(strings.NewReader(string(b)))
+	})
+	writeAPI := NewWriteAPIBlocking("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(4))
+	summary, err := writeAPI.WriteFrom(context.Background(), strings.NewReader("m f=1\nm f=2\nm f=3\nm f=4\n"))
+	require.Error(t, err)
+	assert.Equal(t, "unavailable: service unavailable", err.Error())
+	// lines written before the batch failed are not counted as failed
+	assert.Equal(t, int64(3), summary.LinesWritten)
+	assert.Equal(t, int64(1), summary.LinesFailed)
+	assert.Equal(t, []string{"m f=1", "m f=2", "m f=3"}, service.Lines())
+}
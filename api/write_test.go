@@ -5,6 +5,8 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -166,6 +168,40 @@ func TestWriteError(t *testing.T) {
 	writeAPI.Close()
 }
 
+func TestWriteValidation(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(5).SetValidationLevel(write.ValidationBasic))
+	defer writeAPI.Close()
+	errCh := writeAPI.Errors()
+	writeAPI.WriteRecord("m f=1")
+	writeAPI.WriteRecord("m f=")
+	err := <-errCh
+	var verr *write.ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, "m f=", verr.Invalid[0].Line)
+	writeAPI.WritePoint(write.NewPointWithMeasurement("m").AddFloatField("f", math.Inf(1)))
+	err = <-errCh
+	assert.Equal(t, "invalid point 0: field 'f': unsupported float value +Inf", err.Error())
+	assert.Error(t, writeAPI.TryWriteRecord("m"))
+	assert.Error(t, writeAPI.TryWritePoint(write.NewPointWithMeasurement("m")))
+	writeAPI.Flush()
+	assert.Equal(t, []string{"m f=1"}, service.Lines())
+}
+
+func TestWritePointProcessorsAsync(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions().SetBatchSize(5).SetPendingBufferSize(10).AddPointProcessor(write.Sample(time.Hour))
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, opts)
+	defer writeAPI.Close()
+	for i := 0; i < 3; i++ {
+		writeAPI.WritePoint(write.NewPointWithMeasurement("m").AddIntField("v", int64(i)))
+		require.NoError(t, writeAPI.TryWritePoint(write.NewPointWithMeasurement("n").AddIntField("v", int64(i))))
+	}
+	writeAPI.Flush()
+	assert.Equal(t, []string{"m v=0i", "n v=0i"}, service.Lines())
+	assert.EqualValues(t, 2, writeAPI.Stats().PointsAccepted)
+}
+
 func TestWriteErrorCallback(t *testing.T) {
 	service := test.NewTestService(t, "http://localhost:8888")
 	log.Log.SetLogLevel(log.DebugLevel)
@@ -312,3 +348,361 @@ func TestWriteApiErrorHeaders(t *testing.T) {
 	wg.Wait()
 	assert.Equal(t, calls, 3)
 }
+
+func TestPersistentRetryQueue(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions().SetBatchSize(5).SetRetryInterval(10000).SetRetryQueueDir(t.TempDir())
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, opts)
+	service.SetReplyError(&http.Error{
+		StatusCode: 503,
+	})
+	points := test.GenPoints(15)
+	for i := 0; i < 10; i++ {
+		writeAPI.WritePoint(points[i])
+	}
+	writeAPI.Close()
+	require.Len(t, service.Lines(), 0)
+
+	// simulate restart
+	service.SetReplyError(nil)
+	writeAPI = NewWriteAPI("my-org", "my-bucket", service, opts)
+	for i := 10; i < 15; i++ {
+		writeAPI.WritePoint(points[i])
+	}
+	writeAPI.Close()
+	require.Len(t, service.Lines(), 15)
+	for i, p := range points {
+		line := write.PointToLineProtocol(p, opts.Precision())
+		assert.Equal(t, line[:len(line)-1], service.Lines()[i])
+	}
+}
+
+// blockWrites makes service block writes until returned release func is called
+// and returns a channel notified when a write starts
+func blockWrites(service *test.HTTPService) (<-chan struct{}, func()) {
+	started := make(chan struct{}, 100)
+	release := make(chan struct{})
+	service.SetRequestHandler(func(url string, body io.Reader) error {
+		started <- struct{}{}
+		<-release
+		return service.DecodeLines(body)
+	})
+	return started, func() { close(release) }
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	points := test.GenPoints(6)
+	lineOf := func(i int) string {
+		line := write.PointToLineProtocol(points[i], time.Nanosecond)
+		return line[:len(line)-1]
+	}
+	testCases := []struct {
+		name     string
+		policy   write.OverflowPolicy
+		lines    []int
+		dropped  uint64
+		reported bool
+	}{
+		{"drop newest", write.OverflowDropNewest, []int{0, 1, 2, 3}, 2, false},
+		{"drop oldest", write.OverflowDropOldest, []int{0, 1, 4, 5}, 2, false},
+		{"error", write.OverflowError, []int{0, 1, 2, 3}, 2, true},
+		{"block with timeout", write.OverflowBlockWithTimeout, []int{0, 1, 2, 3}, 2, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := test.NewTestService(t, "http://localhost:8888")
+			started, release := blockWrites(service)
+			var discarded []string
+			opts := write.DefaultOptions().SetBatchSize(1).SetPendingBufferSize(2).SetOverflowPolicy(tc.policy).SetOverflowTimeout(10).
+				SetDeadLetterHandler(write.DeadLetterHandlerFunc(func(lines string, reason write.DiscardReason, _ error) {
+					assert.Equal(t, write.DiscardOverflow, reason)
+					discarded = append(discarded, strings.TrimSuffix(lines, "\n"))
+				}))
+			writeAPI := NewWriteAPI("my-org", "my-bucket", service, opts)
+			errCh := writeAPI.Errors()
+			// first point is being written
+			writeAPI.WritePoint(points[0])
+			<-started
+			// second point waits for the write
+			writeAPI.WritePoint(points[1])
+			<-time.After(20 * time.Millisecond)
+			// pending buffer is filled
+			writeAPI.WritePoint(points[2])
+			writeAPI.WritePoint(points[3])
+			assert.Equal(t, ErrBufferFull, writeAPI.TryWritePoint(points[4]))
+			// overflow
+			writeAPI.WritePoint(points[4])
+			writeAPI.WritePoint(points[5])
+			assert.Equal(t, tc.dropped, writeAPI.DroppedPoints())
+			assert.EqualValues(t, len(tc.lines), writeAPI.Stats().LinesAccepted)
+			var bytesAccepted int
+			for _, l := range tc.lines {
+				bytesAccepted += len(lineOf(l)) + 1
+			}
+			assert.EqualValues(t, bytesAccepted, writeAPI.Stats().BytesAccepted)
+			select {
+			case err := <-errCh:
+				assert.True(t, tc.reported)
+				assert.Equal(t, ErrBufferFull, err)
+			default:
+				assert.False(t, tc.reported)
+			}
+			release()
+			writeAPI.Close()
+			require.Len(t, service.Lines(), len(tc.lines))
+			written := make(map[int]bool)
+			for i, l := range tc.lines {
+				assert.Equal(t, lineOf(l), service.Lines()[i])
+				written[l] = true
+			}
+			var expDiscarded []string
+			for i := range points {
+				if !written[i] {
+					expDiscarded = append(expDiscarded, lineOf(i))
+				}
+			}
+			assert.Equal(t, expDiscarded, discarded)
+		})
+	}
+}
+
+func TestTryWrite(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	started, release := blockWrites(service)
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(1).SetPendingBufferSize(1))
+	lines := test.GenRecords(4)
+	require.NoError(t, writeAPI.TryWriteRecord(lines[0]))
+	<-started
+	require.NoError(t, writeAPI.TryWriteRecord(lines[1]))
+	<-time.After(20 * time.Millisecond)
+	require.NoError(t, writeAPI.TryWriteRecord(lines[2]))
+	assert.Equal(t, ErrBufferFull, writeAPI.TryWriteRecord(lines[3]))
+	assert.EqualValues(t, 0, writeAPI.DroppedPoints())
+	release()
+	writeAPI.Close()
+	assert.Equal(t, lines[:3], service.Lines())
+}
+
+func TestMaxBatchBytes(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	var batches []int
+	var mu sync.Mutex
+	service.SetRequestHandler(func(url string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		mu.Lock()
+		batches = append(batches, len(b))
+		mu.Unlock()
+		return service.DecodeLines(strings.NewReader(string(b)))
+	})
+	// each record has 10 bytes including new line
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(5).SetMaxBatchBytes(25))
+	for i := 0; i < 6; i++ {
+		writeAPI.WriteRecord(fmt.Sprintf("test f=%di", i))
+	}
+	// a record bigger than the limit goes alone
+	writeAPI.WriteRecord("test f=\"very long string value\"")
+	writeAPI.WriteRecord("test f=6i")
+	writeAPI.Close()
+	assert.Len(t, service.Lines(), 8)
+	assert.Equal(t, []int{20, 20, 20, 32, 10}, batches)
+}
+
+func TestWriteRejectedLines(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetReplyError(&http.Error{
+		StatusCode: 400,
+		Code:       "invalid",
+		Message:    "failed to parse line protocol:\nerrors encountered on line(s):\nline 2: missing field value",
+	})
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(3))
+	var cbErr *write.WriteError
+	writeAPI.SetWriteFailedCallback(func(batch string, error http.Error, retryAttempts uint) bool {
+		assert.True(t, errors.As(&error, &cbErr))
+		return true
+	})
+	errCh := writeAPI.Errors()
+	writeAPI.WriteRecord("m f=1")
+	writeAPI.WriteRecord("m f=")
+	writeAPI.WriteRecord("m f=3")
+	err := <-errCh
+	var werr *write.WriteError
+	require.True(t, errors.As(err, &werr))
+	assert.Equal(t, []write.RejectedLine{{Number: 2, Line: "m f=", Reason: "missing field value"}}, werr.Lines)
+	assert.Equal(t, werr, cbErr)
+	writeAPI.Close()
+}
+
+func TestWriteStats(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(2))
+	points := test.GenPoints(3)
+	bytes := 0
+	for _, p := range points {
+		writeAPI.WritePoint(p)
+		bytes += len(write.PointToLineProtocol(p, time.Nanosecond))
+	}
+	writeAPI.WriteRecord("test,a=1 f=1i")
+	bytes += len("test,a=1 f=1i\n")
+	writeAPI.Flush()
+	stats := writeAPI.Stats()
+	assert.EqualValues(t, 3, stats.PointsAccepted)
+	assert.EqualValues(t, 4, stats.LinesAccepted)
+	assert.EqualValues(t, bytes, stats.BytesAccepted)
+	assert.EqualValues(t, 2, stats.BatchesWritten)
+	assert.EqualValues(t, 4, stats.LinesWritten)
+	assert.EqualValues(t, bytes, stats.BytesWritten)
+	assert.EqualValues(t, 0, stats.FailedRequests)
+	assert.Nil(t, stats.LastError)
+	assert.False(t, stats.LastWriteTime.IsZero())
+	assert.EqualValues(t, 2, stats.RequestLatency.Count)
+	assert.Len(t, stats.RequestLatency.Counts, len(stats.RequestLatency.Bounds)+1)
+
+	// failing write
+	service.SetReplyError(&http.Error{StatusCode: 503, Code: "unavailable", Message: "service unavailable"})
+	writeAPI.WriteRecord("test,a=2 f=2i")
+	writeAPI.Flush()
+	stats = writeAPI.Stats()
+	assert.EqualValues(t, 2, stats.BatchesWritten)
+	// Flush retries the batch without additional retrying
+	assert.EqualValues(t, 2, stats.FailedRequests)
+	assert.EqualError(t, stats.LastError, "unavailable: service unavailable")
+	assert.False(t, stats.LastErrorTime.IsZero())
+	assert.EqualValues(t, 0, stats.RetryQueueBatches)
+	assert.EqualValues(t, 1, stats.DiscardedBatches)
+
+	service.SetReplyError(nil)
+	writeAPI.Close()
+	var total uint64
+	for _, c := range writeAPI.Stats().RequestLatency.Counts {
+		total += c
+	}
+	assert.EqualValues(t, 4, total)
+}
+
+func TestWriteCircuitBreaker(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions().SetBatchSize(1).SetRetryStrategy(write.NewConstantRetryStrategy(0)).
+		SetCircuitBreakerThreshold(1).SetCircuitBreakerOpenTimeout(60_000)
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, opts)
+	errCh := writeAPI.Errors()
+	service.SetReplyError(&http.Error{StatusCode: 503, Code: "unavailable", Message: "service unavailable"})
+	writeAPI.WriteRecord("test,a=1 f=1i")
+	assert.EqualError(t, <-errCh, "unavailable: service unavailable")
+	assert.Equal(t, write.CircuitOpen, writeAPI.CircuitState())
+	writeAPI.WriteRecord("test,a=2 f=2i")
+	assert.True(t, errors.Is(<-errCh, write.ErrCircuitOpen))
+	assert.Equal(t, 1, service.Requests())
+	assert.Equal(t, write.CircuitOpen, writeAPI.Stats().CircuitState)
+	writeAPI.Close()
+}
+
+func TestWriteWorkers(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	service.SetRequestHandler(func(url string, body io.Reader) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		<-time.After(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return service.DecodeLines(body)
+	})
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, write.DefaultOptions().SetBatchSize(1).SetWriteWorkers(3))
+	lines := test.GenRecords(9)
+	for _, l := range lines {
+		writeAPI.WriteRecord(l)
+	}
+	writeAPI.Flush()
+	assert.Len(t, service.Lines(), len(lines))
+	assert.ElementsMatch(t, lines, service.Lines())
+	assert.Equal(t, 3, maxRunning)
+	writeAPI.Close()
+}
+
+func TestWriteWorkersPreserveSeriesOrder(t *testing.T) {
+	service := test.NewTestService(t, "http://localhost:8888")
+	service.SetRequestHandler(func(url string, body io.Reader) error {
+		<-time.After(time.Millisecond)
+		return service.DecodeLines(body)
+	})
+	opts := write.DefaultOptions().SetBatchSize(5).SetWriteWorkers(4).SetPreserveSeriesOrder(true)
+	writeAPI := NewWriteAPI("my-org", "my-bucket", service, opts)
+	for i := 0; i < 100; i++ {
+		writeAPI.WriteRecord(fmt.Sprintf("test,host=h%d,escaped=a\\ b f=%di", i%7, i))
+	}
+	writeAPI.Close()
+	require.Len(t, service.Lines(), 100)
+	last := make(map[string]int)
+	for _, l := range service.Lines() {
+		var i int
+		_, err := fmt.Sscanf(l[strings.LastIndex(l, "=")+1:], "%di", &i)
+		require.NoError(t, err)
+		key := seriesKey(l)
+		if prev, ok := last[key]; ok {
+			assert.Greater(t, i, prev, key)
+		}
+		last[key] = i
+	}
+	assert.Len(t, last, 7)
+}
+
+func TestSeriesKey(t *testing.T) {
+	assert.Equal(t, "cpu,host=a", seriesKey("cpu,host=a f=1 1"))
+	assert.Equal(t, `cpu\ load,host=a\ b`, seriesKey(`cpu\ load,host=a\ b f=1`))
+	assert.Equal(t, "cpu", seriesKey("cpu"))
+}
+
+func TestWriteAPIV1(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*ihttp.Request
+	var bodies []string
+	server := httptest.NewServer(ihttp.HandlerFunc(func(w ihttp.ResponseWriter, r *ihttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(ihttp.StatusNoContent)
+	}))
+	defer server.Close()
+	svc := http.NewService(server.URL+"/", "Token my-token", http.DefaultOptions())
+	opts := write.DefaultOptions().SetPrecision(time.Microsecond).SetBatchSize(2)
+
+	// blocking write with client authorization
+	writeAPIBlocking := NewWriteAPIBlockingV1("db", "autogen", svc, opts)
+	require.NoError(t, writeAPIBlocking.WriteRecord(context.Background(), "test a=1 1"))
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/write", requests[0].URL.Path)
+	assert.Equal(t, "db", requests[0].URL.Query().Get("db"))
+	assert.Equal(t, "autogen", requests[0].URL.Query().Get("rp"))
+	assert.Equal(t, "u", requests[0].URL.Query().Get("precision"))
+	assert.Equal(t, "Token my-token", requests[0].Header.Get("Authorization"))
+	assert.Equal(t, "test a=1 1", bodies[0])
+
+	// async write with v1 credentials and default retention policy
+	opts.SetV1Credentials("user", "pass")
+	writeAPI := NewWriteAPIV1("db", "", svc, opts)
+	points := test.GenPoints(2)
+	for _, p := range points {
+		writeAPI.WritePoint(p)
+	}
+	writeAPI.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/write", requests[1].URL.Path)
+	assert.Equal(t, "db", requests[1].URL.Query().Get("db"))
+	_, ok := requests[1].URL.Query()["rp"]
+	assert.False(t, ok)
+	username, password, ok := requests[1].BasicAuth()
+	require.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+	assert.Len(t, strings.Split(strings.TrimSpace(bodies[1]), "\n"), 2)
+}
@@ -56,9 +56,19 @@ type Client interface {
 	// WriteAPIBlocking returns the synchronous, blocking, Write client.
 	// Ensures using a single WriteAPIBlocking instance for each org/bucket pair.
 	WriteAPIBlocking(org, bucket string) api.WriteAPIBlocking
+	// WriteAPIV1 returns the asynchronous, non-blocking, Write client writing to database db and retention policy rp
+	// using the v1 compatibility endpoint. Empty rp means the default retention policy.
+	// Ensures using a single WriteAPIV1 instance for each db/rp pair.
+	WriteAPIV1(db, rp string) api.WriteAPI
+	// WriteAPIBlockingV1 returns the synchronous, blocking, Write client writing to database db and retention policy rp
+	// using the v1 compatibility endpoint. Empty rp means the default retention policy.
+	// Ensures using a single WriteAPIBlockingV1 instance for each db/rp pair.
+	WriteAPIBlockingV1(db, rp string) api.WriteAPIBlocking
 	// QueryAPI returns Query client.
 	// Ensures using a single QueryAPI instance each org.
 	QueryAPI(org string) api.QueryAPI
+	// InfluxQLQueryAPI returns client for querying by InfluxQL using the v1 compatibility endpoint.
+	InfluxQLQueryAPI() api.InfluxQLQueryAPI
 	// AuthorizationsAPI returns Authorizations API client.
 	AuthorizationsAPI() api.AuthorizationsAPI
 	// OrganizationsAPI returns Organizations API client
@@ -242,6 +252,33 @@ func (c *clientImpl) WriteAPIBlocking(org, bucket string) api.WriteAPIBlocking {
 	return c.syncWriteAPIs[key]
 }
 
+// createV1Key creates key of v1 write APIs, which differs from keys of org/bucket pairs
+func createV1Key(db, rp string) string {
+	return "v1\t" + db + "\t" + rp
+}
+
+func (c *clientImpl) WriteAPIV1(db, rp string) api.WriteAPI {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := createV1Key(db, rp)
+	if _, ok := c.writeAPIs[key]; !ok {
+		w := api.NewWriteAPIV1(db, rp, c.httpService, c.options.writeOptions)
+		c.writeAPIs[key] = w
+	}
+	return c.writeAPIs[key]
+}
+
+func (c *clientImpl) WriteAPIBlockingV1(db, rp string) api.WriteAPIBlocking {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := createV1Key(db, rp)
+	if _, ok := c.syncWriteAPIs[key]; !ok {
+		w := api.NewWriteAPIBlockingV1(db, rp, c.httpService, c.options.writeOptions)
+		c.syncWriteAPIs[key] = w
+	}
+	return c.syncWriteAPIs[key]
+}
+
 func (c *clientImpl) Close() {
 	for key, w := range c.writeAPIs {
 		wa := w.(*api.WriteAPIImpl)
@@ -260,6 +297,10 @@ func (c *clientImpl) QueryAPI(org string) api.QueryAPI {
 	return api.NewQueryAPI(org, c.httpService)
 }
 
+func (c *clientImpl) InfluxQLQueryAPI() api.InfluxQLQueryAPI {
+	return api.NewInfluxQLQueryAPI(c.httpService)
+}
+
 func (c *clientImpl) AuthorizationsAPI() api.AuthorizationsAPI {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -46,6 +46,8 @@ func TestUrls(t *testing.T) {
 			ws := iwrite.NewService("org", "bucket", ci.httpService, c.Options().WriteOptions())
 			wu := ws.WriteURL()
 			assert.Equal(t, url.writeURLPrefix+"?bucket=bucket&org=org&precision=ns", wu)
+			ws = iwrite.NewServiceV1("db", "rp", ci.httpService, c.Options().WriteOptions())
+			assert.Equal(t, strings.TrimSuffix(url.writeURLPrefix, "api/v2/write")+"write?db=db&precision=ns&rp=rp", ws.WriteURL())
 		})
 	}
 }
@@ -76,6 +78,14 @@ func TestWriteAPIManagement(t *testing.T) {
 			assert.Len(t, c.syncWriteAPIs, d.expectedCout)
 		})
 	}
+	w := c.WriteAPIV1("o1", "b1")
+	assert.NotNil(t, w)
+	assert.Equal(t, w, c.WriteAPIV1("o1", "b1"))
+	assert.Len(t, c.writeAPIs, 6)
+	wb := c.WriteAPIBlockingV1("o1", "b1")
+	assert.NotNil(t, wb)
+	assert.Equal(t, wb, c.WriteAPIBlockingV1("o1", "b1"))
+	assert.Len(t, c.syncWriteAPIs, 6)
 	c.Close()
 	assert.Len(t, c.writeAPIs, 0)
 	assert.Len(t, c.syncWriteAPIs, 0)
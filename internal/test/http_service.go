@@ -8,6 +8,7 @@ package test
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -42,7 +43,8 @@ func (t *HTTPService) SetWasGzip(wasGzip bool) {
 	t.wasGzip = wasGzip
 }
 
-// SetRequestHandler sets custom handler for requests
+// SetRequestHandler sets custom handler for requests.
+// If the handler returns *http2.Error, it is returned as is.
 func (t *HTTPService) SetRequestHandler(fn func(url string, body io.Reader) error) {
 	t.requestHandler = fn
 }
@@ -148,6 +150,10 @@ func (t *HTTPService) DoPostRequest(_ context.Context, url string, body io.Reade
 	}
 
 	if err != nil {
+		var perror *http2.Error
+		if errors.As(err, &perror) {
+			return perror
+		}
 		return http2.NewError(err)
 	}
 	return nil
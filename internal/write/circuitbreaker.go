@@ -0,0 +1,111 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/influxdata/influxdb-client-go/v2/internal/log"
+)
+
+// circuitBreaker stops sending writes to an unhealthy server.
+// It opens after threshold consecutive failures. After openTimeout it becomes half-open
+// and allows a single probe request. Successful probe closes it, failed probe opens it again.
+type circuitBreaker struct {
+	threshold   uint
+	openTimeout time.Duration
+	onChange    write.CircuitBreakerCallback
+
+	mu       sync.Mutex
+	state    write.CircuitState
+	failures uint
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(threshold uint, openTimeout time.Duration, onChange write.CircuitBreakerCallback) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openTimeout: openTimeout, onChange: onChange}
+}
+
+// allow returns true if a request can be sent
+func (c *circuitBreaker) allow() bool {
+	if c.threshold == 0 {
+		return true
+	}
+	c.mu.Lock()
+	from := c.state
+	allowed := true
+	switch c.state {
+	case write.CircuitOpen:
+		if time.Since(c.openedAt) < c.openTimeout {
+			allowed = false
+		} else {
+			c.state = write.CircuitHalfOpen
+			c.probing = true
+		}
+	case write.CircuitHalfOpen:
+		if c.probing {
+			allowed = false
+		} else {
+			c.probing = true
+		}
+	}
+	to := c.state
+	c.mu.Unlock()
+	c.notify(from, to)
+	return allowed
+}
+
+// success records a request to the healthy server
+func (c *circuitBreaker) success() {
+	if c.threshold == 0 {
+		return
+	}
+	c.mu.Lock()
+	from := c.state
+	c.state = write.CircuitClosed
+	c.failures = 0
+	c.probing = false
+	c.mu.Unlock()
+	c.notify(from, write.CircuitClosed)
+}
+
+// failure records a request failed because of the unhealthy server
+func (c *circuitBreaker) failure() {
+	if c.threshold == 0 {
+		return
+	}
+	c.mu.Lock()
+	from := c.state
+	c.failures++
+	c.probing = false
+	if c.state == write.CircuitHalfOpen || c.failures >= c.threshold {
+		c.state = write.CircuitOpen
+		c.openedAt = time.Now()
+	}
+	to := c.state
+	c.mu.Unlock()
+	c.notify(from, to)
+}
+
+// currentState returns the current state
+func (c *circuitBreaker) currentState() write.CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// notify calls the callback, if the state was changed
+func (c *circuitBreaker) notify(from, to write.CircuitState) {
+	if from == to {
+		return
+	}
+	log.Infof("Circuit breaker: %s -> %s", from, to)
+	if c.onChange != nil {
+		c.onChange(from, to)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var changes []string
+	c := newCircuitBreaker(2, 10*time.Millisecond, func(from, to write.CircuitState) {
+		changes = append(changes, from.String()+"->"+to.String())
+	})
+	assert.True(t, c.allow())
+	c.failure()
+	assert.Equal(t, write.CircuitClosed, c.currentState())
+	// success resets failures
+	c.success()
+	c.failure()
+	assert.Equal(t, write.CircuitClosed, c.currentState())
+	c.failure()
+	assert.Equal(t, write.CircuitOpen, c.currentState())
+	assert.False(t, c.allow())
+
+	<-time.After(15 * time.Millisecond)
+	// single probe
+	assert.True(t, c.allow())
+	assert.Equal(t, write.CircuitHalfOpen, c.currentState())
+	assert.False(t, c.allow())
+	// failed probe opens again
+	c.failure()
+	assert.Equal(t, write.CircuitOpen, c.currentState())
+	assert.False(t, c.allow())
+
+	<-time.After(15 * time.Millisecond)
+	assert.True(t, c.allow())
+	c.success()
+	assert.Equal(t, write.CircuitClosed, c.currentState())
+	assert.True(t, c.allow())
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, changes)
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	c := newCircuitBreaker(0, time.Millisecond, nil)
+	for i := 0; i < 10; i++ {
+		c.failure()
+		assert.True(t, c.allow())
+	}
+	assert.Equal(t, write.CircuitClosed, c.currentState())
+}
@@ -0,0 +1,159 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+const errStringFailedToParse = "failed to parse line protocol"
+
+var (
+	// v2 parsing error, e.g. "line 2: no field values found"
+	lineErrorRegexp = regexp.MustCompile(`(?m)^\s*line (\d+): (.*)$`)
+	// v1 parsing error, e.g. "unable to parse 'cpu value': invalid field format"
+	unableToParseRegexp = regexp.MustCompile(`unable to parse '(.*?)': ([^\n]*)`)
+	droppedRegexp       = regexp.MustCompile(`dropped=(\d+)`)
+)
+
+// isDataError returns true if the error message reports lines rejected because of invalid data
+func isDataError(message string) bool {
+	return strings.Contains(message, errStringPartialWrite) ||
+		strings.Contains(message, errStringUnableToParse) ||
+		strings.Contains(message, errStringFailedToParse)
+}
+
+// newWriteError creates WriteError from the server response reporting rejected data.
+// body is line protocol sent in the request and lineOffset is number of batch lines preceding body.
+// Returns nil if the error doesn't report rejected data.
+func newWriteError(perror *http2.Error, body string, lineOffset int) *write.WriteError {
+	if perror.Err != nil || !isDataError(perror.Message) {
+		return nil
+	}
+	werr := &write.WriteError{
+		StatusCode: perror.StatusCode,
+		Code:       perror.Code,
+		Message:    perror.Message,
+		Dropped:    -1,
+	}
+	if m := droppedRegexp.FindStringSubmatch(perror.Message); m != nil {
+		werr.Dropped, _ = strconv.Atoi(m[1])
+	}
+	lines := strings.Split(body, "\n")
+	for _, m := range lineErrorRegexp.FindAllStringSubmatch(perror.Message, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		rl := write.RejectedLine{Number: n + lineOffset, Reason: m[2]}
+		if n > 0 && n <= len(lines) {
+			rl.Line = lines[n-1]
+		}
+		werr.Lines = append(werr.Lines, rl)
+	}
+	if len(werr.Lines) == 0 {
+		for _, m := range unableToParseRegexp.FindAllStringSubmatch(perror.Message, -1) {
+			rl := write.RejectedLine{Line: m[1], Reason: m[2]}
+			for i, l := range lines {
+				if l == m[1] {
+					rl.Number = i + 1 + lineOffset
+					break
+				}
+			}
+			werr.Lines = append(werr.Lines, rl)
+		}
+	}
+	return werr
+}
+
+// withWriteError returns copy of perror with nested WriteError, if perror reports rejected data.
+// Otherwise, perror is returned unchanged.
+func withWriteError(perror *http2.Error, body string, lineOffset int) *http2.Error {
+	werr := newWriteError(perror, body, lineOffset)
+	if werr == nil {
+		return perror
+	}
+	e := *perror
+	e.Err = werr
+	return &e
+}
+
+// isWriteError returns true if perror holds WriteError
+func isWriteError(perror *http2.Error) bool {
+	var werr *write.WriteError
+	return errors.As(perror, &werr)
+}
+
+// isRejectedData returns true if perror is the server response rejecting data of the written body
+func isRejectedData(perror *http2.Error) bool {
+	return perror != nil && isDataError(perror.Message)
+}
+
+// rejectedLinesError is the nested error of a write failure of a split batch, other parts of which were rejected.
+// It keeps the message of the failure and unwraps to WriteError describing the rejected lines.
+type rejectedLinesError struct {
+	message string
+	werr    *write.WriteError
+}
+
+func (e *rejectedLinesError) Error() string {
+	return e.message
+}
+
+func (e *rejectedLinesError) Unwrap() error {
+	return e.werr
+}
+
+// mergeErrors returns the error of a batch split in two parts, first and second are errors of the parts.
+// The last error not reporting rejected data takes precedence, rejected lines of both parts are kept in its WriteError.
+func mergeErrors(first, second *http2.Error) *http2.Error {
+	if second == nil {
+		return first
+	}
+	if first == nil {
+		return second
+	}
+	result := second
+	if isRejectedData(second) && !isRejectedData(first) {
+		result = first
+	}
+	var werr1, werr2 *write.WriteError
+	has1, has2 := errors.As(first, &werr1), errors.As(second, &werr2)
+	if !has1 && !has2 {
+		return result
+	}
+	var merged write.WriteError
+	switch {
+	case has1 && has2:
+		merged = *werr2
+		merged.Lines = append(append([]write.RejectedLine{}, werr1.Lines...), werr2.Lines...)
+		if werr1.Dropped >= 0 && werr2.Dropped >= 0 {
+			merged.Dropped = werr1.Dropped + werr2.Dropped
+		}
+	case has1:
+		merged = *werr1
+	default:
+		merged = *werr2
+	}
+	e := *result
+	if isRejectedData(result) {
+		e.Err = &merged
+	} else {
+		// keep rejected lines within the error, because of which the rest of the batch is retried or discarded
+		message := result.Error()
+		var rerr *rejectedLinesError
+		if errors.As(result, &rerr) {
+			message = rerr.message
+		}
+		e.Err = &rejectedLinesError{message: message, werr: &merged}
+	}
+	return &e
+}
@@ -0,0 +1,101 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWriteError(t *testing.T) {
+	body := "m f=1\nm f=\nm,t=a f=2\nm g\n"
+	testCases := []struct {
+		name    string
+		perror  *http.Error
+		dropped int
+		lines   []write.RejectedLine
+	}{
+		{
+			name: "v2 parsing error",
+			perror: &http.Error{StatusCode: 400, Code: "invalid",
+				Message: "failed to parse line protocol:\nerrors encountered on line(s):\nline 2: missing field value\nline 4: invalid field format"},
+			dropped: -1,
+			lines: []write.RejectedLine{
+				{Number: 2, Line: "m f=", Reason: "missing field value"},
+				{Number: 4, Line: "m g", Reason: "invalid field format"},
+			},
+		},
+		{
+			name:    "v1 parsing error",
+			perror:  &http.Error{StatusCode: 400, Code: "invalid", Message: "unable to parse 'm g': invalid field format"},
+			dropped: -1,
+			lines: []write.RejectedLine{
+				{Number: 4, Line: "m g", Reason: "invalid field format"},
+			},
+		},
+		{
+			name: "partial write",
+			perror: &http.Error{StatusCode: 400, Code: "invalid",
+				Message: "partial write: field type conflict: input field \"f\" on measurement \"m\" is type float, already exists as type integer dropped=2"},
+			dropped: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			werr := newWriteError(tc.perror, body, 0)
+			require.NotNil(t, werr)
+			assert.Equal(t, tc.perror.StatusCode, werr.StatusCode)
+			assert.Equal(t, tc.perror.Error(), werr.Error())
+			assert.Equal(t, tc.dropped, werr.Dropped)
+			assert.Equal(t, tc.lines, werr.Lines)
+		})
+	}
+
+	assert.Nil(t, newWriteError(&http.Error{StatusCode: 500, Code: "internal error", Message: "gateway error"}, body, 0))
+	assert.Nil(t, newWriteError(http.NewError(errors.New("connection refused")), body, 0))
+}
+
+func TestWithWriteError(t *testing.T) {
+	perror := &http.Error{StatusCode: 400, Code: "invalid", Message: "failed to parse line protocol:\nline 1: missing field value"}
+	e := withWriteError(perror, "m f=\n", 3)
+	// original error is kept unchanged
+	assert.Nil(t, perror.Err)
+	assert.Equal(t, perror.Error(), e.Error())
+	var werr *write.WriteError
+	require.True(t, errors.As(e, &werr))
+	assert.Equal(t, []write.RejectedLine{{Number: 4, Line: "m f=", Reason: "missing field value"}}, werr.Lines)
+
+	other := &http.Error{StatusCode: 503}
+	assert.Equal(t, other, withWriteError(other, "m f=\n", 0))
+}
+
+func TestMergeErrors(t *testing.T) {
+	e1 := withWriteError(&http.Error{StatusCode: 400, Message: "failed to parse line protocol:\nline 1: a"}, "x\n", 0)
+	e2 := withWriteError(&http.Error{StatusCode: 400, Message: "failed to parse line protocol:\nline 1: b"}, "y\n", 1)
+	other := &http.Error{StatusCode: 413}
+	assert.Nil(t, mergeErrors(nil, nil))
+	assert.Equal(t, e1, mergeErrors(e1, nil))
+	merged := mergeErrors(e1, e2)
+	var werr *write.WriteError
+	require.True(t, errors.As(merged, &werr))
+	assert.Equal(t, []write.RejectedLine{{Number: 1, Line: "x", Reason: "a"}, {Number: 2, Line: "y", Reason: "b"}}, werr.Lines)
+	// other error takes precedence and keeps rejected lines
+	for _, merged := range []*http.Error{mergeErrors(e1, other), mergeErrors(other, e1)} {
+		assert.Equal(t, 413, merged.StatusCode)
+		assert.Equal(t, "Unexpected status code 413", merged.Error())
+		require.True(t, errors.As(merged, &werr))
+		assert.Equal(t, []write.RejectedLine{{Number: 1, Line: "x", Reason: "a"}}, werr.Lines)
+	}
+	merged = mergeErrors(mergeErrors(e1, other), e2)
+	assert.Equal(t, 413, merged.StatusCode)
+	assert.Equal(t, "Unexpected status code 413", merged.Error())
+	require.True(t, errors.As(merged, &werr))
+	assert.Len(t, werr.Lines, 2)
+}
@@ -6,23 +6,61 @@ package write
 
 import (
 	"container/list"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/influxdata/influxdb-client-go/v2/internal/log"
 )
 
 type queue struct {
 	list  *list.List
 	limit int
+	// bytes is total size of queued batches
+	bytes int64
+	// store persists batches, nil if queue is kept only in memory
+	store *segmentStore
+	// onDiscard is notified about batches discarded by the queue, can be nil
+	onDiscard func(batch *Batch, reason write.DiscardReason)
 }
 
 func newQueue(limit int) *queue {
 	return &queue{list: list.New(), limit: limit}
 }
+
+// newPersistentQueue creates queue backed by store, filled with batches loaded from the store.
+// Expired batches and batches over the limit are discarded.
+func newPersistentQueue(limit int, store *segmentStore, batches []*Batch, onDiscard func(batch *Batch, reason write.DiscardReason)) *queue {
+	q := &queue{list: list.New(), limit: limit, store: store, onDiscard: onDiscard}
+	for _, b := range batches {
+		q.list.PushBack(b)
+		b.queuedSize = len(b.Batch)
+		q.bytes += int64(b.queuedSize)
+	}
+	for !q.isEmpty() && time.Now().After(q.first().Expires) {
+		log.Warn("Retry queue: discarding expired batch")
+		q.discard(q.pop(), write.DiscardExpired)
+	}
+	for q.list.Len() > q.limit {
+		log.Warn("Retry queue: limit exceeded, discarding oldest batch")
+		q.discard(q.pop(), write.DiscardRetryBufferFull)
+	}
+	return q
+}
+
 func (q *queue) push(batch *Batch) bool {
 	overWrite := false
 	if q.list.Len() == q.limit {
-		q.pop()
+		q.discard(q.pop(), write.DiscardRetryBufferFull)
 		overWrite = true
 	}
 	q.list.PushBack(batch)
+	batch.queuedSize = len(batch.Batch)
+	q.bytes += int64(batch.queuedSize)
+	if q.store != nil {
+		if err := q.store.append(batch); err != nil {
+			log.Errorf("Retry queue: cannot persist batch: %s", err.Error())
+		}
+	}
 	return overWrite
 }
 
@@ -32,11 +70,38 @@ func (q *queue) pop() *Batch {
 		q.list.Remove(el)
 		batch := el.Value.(*Batch)
 		batch.Evicted = true
+		q.bytes -= int64(batch.queuedSize)
+		if q.store != nil {
+			if err := q.store.remove(batch); err != nil {
+				log.Errorf("Retry queue: cannot remove persisted batch: %s", err.Error())
+			}
+		}
 		return batch
 	}
 	return nil
 }
 
+// update records changes of batch, which were made after it was pushed.
+// Only changes of the first batch are recorded, it is the only one written from the queue.
+func (q *queue) update(batch *Batch) {
+	if batch != q.first() {
+		return
+	}
+	q.bytes += int64(len(batch.Batch) - batch.queuedSize)
+	batch.queuedSize = len(batch.Batch)
+	if q.store != nil {
+		if err := q.store.update(batch); err != nil {
+			log.Errorf("Retry queue: cannot persist batch: %s", err.Error())
+		}
+	}
+}
+
+func (q *queue) discard(batch *Batch, reason write.DiscardReason) {
+	if q.onDiscard != nil {
+		q.onDiscard(batch, reason)
+	}
+}
+
 func (q *queue) first() *Batch {
 	el := q.list.Front()
 	if el != nil {
@@ -48,3 +113,16 @@ func (q *queue) first() *Batch {
 func (q *queue) isEmpty() bool {
 	return q.list.Len() == 0
 }
+
+// isPersistent returns true if batches survive restart of the application
+func (q *queue) isPersistent() bool {
+	return q.store != nil
+}
+
+// close releases resources of the store
+func (q *queue) close() error {
+	if q.store != nil {
+		return q.store.close()
+	}
+	return nil
+}
@@ -5,9 +5,15 @@
 package write
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestQueue(t *testing.T) {
@@ -32,3 +38,139 @@ func TestQueue(t *testing.T) {
 	assert.Nil(t, que.pop())
 	assert.True(t, que.isEmpty())
 }
+
+func TestPersistentQueue(t *testing.T) {
+	dir := t.TempDir()
+	opts := write.DefaultOptions().SetRetryQueueSegmentSize(40)
+	store, batches, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 0)
+	que := newPersistentQueue(3, store, batches, nil)
+	assert.True(t, que.isPersistent())
+	expires := time.Now().Add(time.Hour)
+	for i := 1; i <= 4; i++ {
+		que.push(&Batch{Batch: fmt.Sprintf("line %d\n", i), Expires: expires})
+	}
+	// first batch was evicted
+	assert.Equal(t, 3, que.list.Len())
+	require.NoError(t, que.close())
+
+	store, batches, err = openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 3)
+	assert.Equal(t, "line 2\n", batches[0].Batch)
+	assert.Equal(t, "line 4\n", batches[2].Batch)
+	assert.Equal(t, expires.UnixNano(), batches[0].Expires.UnixNano())
+	que = newPersistentQueue(3, store, batches, nil)
+	assert.Equal(t, "line 2\n", que.pop().Batch)
+	que.push(&Batch{Batch: "line 5\n", Expires: expires})
+	require.NoError(t, que.close())
+
+	store, batches, err = openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 3)
+	assert.Equal(t, "line 3\n", batches[0].Batch)
+	assert.Equal(t, "line 5\n", batches[2].Batch)
+	que = newPersistentQueue(3, store, batches, nil)
+	for !que.isEmpty() {
+		que.pop()
+	}
+	require.NoError(t, que.close())
+	// everything consumed, no files left
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestPersistentQueueExpiredAndLimit(t *testing.T) {
+	dir := t.TempDir()
+	opts := write.DefaultOptions()
+	store, _, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	que := newPersistentQueue(5, store, nil, nil)
+	que.push(&Batch{Batch: "expired\n", Expires: time.Now().Add(-time.Second)})
+	for i := 1; i <= 4; i++ {
+		que.push(&Batch{Batch: fmt.Sprintf("line %d\n", i), Expires: time.Now().Add(time.Hour)})
+	}
+	require.NoError(t, que.close())
+
+	store, batches, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 5)
+	// limit lowered
+	que = newPersistentQueue(2, store, batches, nil)
+	require.Equal(t, 2, que.list.Len())
+	assert.Equal(t, "line 3\n", que.first().Batch)
+	require.NoError(t, que.close())
+}
+
+func TestPersistentQueueDamagedSegment(t *testing.T) {
+	dir := t.TempDir()
+	opts := write.DefaultOptions().SetRetryQueueFsyncPolicy(write.FsyncNever)
+	store, _, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	que := newPersistentQueue(5, store, nil, nil)
+	que.push(&Batch{Batch: "line 1\n", Expires: time.Now().Add(time.Hour)})
+	que.push(&Batch{Batch: "line 2\n", Expires: time.Now().Add(time.Hour)})
+	require.NoError(t, que.close())
+
+	// simulate crash during write of the second record
+	path := store.segmentPath(1)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	store, batches, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "line 1\n", batches[0].Batch)
+	que = newPersistentQueue(5, store, batches, nil)
+	que.push(&Batch{Batch: "line 3\n", Expires: time.Now().Add(time.Hour)})
+	require.NoError(t, que.close())
+
+	_, batches, err = openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, "line 3\n", batches[1].Batch)
+}
+
+func TestPersistentQueueUpdate(t *testing.T) {
+	dir := t.TempDir()
+	opts := write.DefaultOptions()
+	store, _, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	que := newPersistentQueue(5, store, nil, nil)
+	expires := time.Now().Add(time.Hour)
+	first := &Batch{Batch: "line 1\nline 2\nline 3\n", Expires: expires}
+	second := &Batch{Batch: "line 4\n", Expires: expires, RetryAttempts: 1}
+	que.push(first)
+	que.push(second)
+	// first batch partially written and retried
+	first.Batch = "line 3\n"
+	first.RetryAttempts = 2
+	que.update(first)
+	// only the first batch is updated
+	second.Batch = "changed\n"
+	que.update(second)
+	require.NoError(t, que.close())
+
+	store, batches, err := openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, "line 3\n", batches[0].Batch)
+	assert.Equal(t, uint(2), batches[0].RetryAttempts)
+	assert.Equal(t, expires.UnixNano(), batches[0].Expires.UnixNano())
+	assert.Equal(t, "line 4\n", batches[1].Batch)
+	assert.Equal(t, uint(1), batches[1].RetryAttempts)
+
+	// updated batch written, the update is not applied to the next one
+	que = newPersistentQueue(5, store, batches, nil)
+	assert.Equal(t, "line 3\n", que.pop().Batch)
+	require.NoError(t, que.close())
+	_, batches, err = openSegmentStore(dir, opts)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "line 4\n", batches[0].Batch)
+	_, err = os.Stat(filepath.Join(dir, headFileName))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,437 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/influxdata/influxdb-client-go/v2/internal/log"
+)
+
+const (
+	segmentExt     = ".seg"
+	cursorFileName = "cursor"
+	headFileName   = "head"
+	// record header: payload length, checksum, expiration time in unix nanoseconds, retry attempts
+	recordHeaderSize = 20
+	// position of the record replaced by the head file: segment id, end of the record
+	headPositionSize = 16
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// segmentStore persists batches of the retry queue into append-only segment files.
+// Batches are appended to the last segment and consumed from the first one,
+// position of the first not consumed batch is kept in the cursor file.
+// Fully consumed segments are removed.
+// Records are never modified, when the first batch changes (it is retried or partially written),
+// its current state is kept in the head file, which replaces the first record when the store is opened.
+// A directory of segmentStore must not be shared by multiple stores.
+type segmentStore struct {
+	dir           string
+	segmentSize   int64
+	fsyncPolicy   write.FsyncPolicy
+	fsyncInterval time.Duration
+	lastSync      time.Time
+	// ids of existing segment files in ascending order
+	segments []uint64
+	nextID   uint64
+	// stored batches in the queue order
+	records  []storedRecord
+	tail     *os.File
+	tailSize int64
+}
+
+// storedRecord binds a batch with the position where its record ends
+type storedRecord struct {
+	batch   *Batch
+	segment uint64
+	end     int64
+}
+
+// openSegmentStore opens store in dir, creating the dir if it doesn't exist, and returns batches stored by previous runs
+func openSegmentStore(dir string, options *write.Options) (*segmentStore, []*Batch, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	s := &segmentStore{
+		dir:           dir,
+		segmentSize:   int64(options.RetryQueueSegmentSize()),
+		fsyncPolicy:   options.RetryQueueFsyncPolicy(),
+		fsyncInterval: time.Duration(options.RetryQueueFsyncInterval()) * time.Millisecond,
+		lastSync:      time.Now(),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentExt), 10, 64)
+		if err != nil {
+			log.Warnf("Retry queue: ignoring unknown file %s", e.Name())
+			continue
+		}
+		s.segments = append(s.segments, id)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+	s.nextID = 1
+	if len(s.segments) > 0 {
+		s.nextID = s.segments[len(s.segments)-1] + 1
+	}
+
+	cursorSegment, cursorOffset, err := s.readCursor()
+	if err != nil {
+		return nil, nil, err
+	}
+	segments := s.segments[:0]
+	var batches []*Batch
+	for i, id := range s.segments {
+		if id < cursorSegment {
+			// already consumed
+			if err := os.Remove(s.segmentPath(id)); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		offset := int64(0)
+		if id == cursorSegment {
+			offset = cursorOffset
+		}
+		records, size, err := s.readSegment(id, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == len(s.segments)-1 {
+			// continue appending after the last valid record
+			s.tail, err = os.OpenFile(s.segmentPath(id), os.O_RDWR, 0o644)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := s.tail.Truncate(size); err != nil {
+				return nil, nil, err
+			}
+			if _, err := s.tail.Seek(size, io.SeekStart); err != nil {
+				return nil, nil, err
+			}
+			s.tailSize = size
+		}
+		segments = append(segments, id)
+		for _, r := range records {
+			batches = append(batches, r.batch)
+		}
+		s.records = append(s.records, records...)
+	}
+	s.segments = segments
+	if err := s.readHead(); err != nil {
+		return nil, nil, err
+	}
+	if len(s.records) > 0 {
+		batches[0] = s.records[0].batch
+	}
+	return s, batches, nil
+}
+
+// readHead replaces the first record with the batch stored in the head file, if the file belongs to it.
+// Stale or damaged head file is removed.
+func (s *segmentStore) readHead() error {
+	path := filepath.Join(s.dir, headFileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(s.records) > 0 && len(b) >= headPositionSize+recordHeaderSize {
+		segment := binary.BigEndian.Uint64(b[0:8])
+		end := int64(binary.BigEndian.Uint64(b[8:16]))
+		if r := &s.records[0]; r.segment == segment && r.end == end {
+			if batch, ok := decodeRecord(b[headPositionSize:headPositionSize+recordHeaderSize], b[headPositionSize+recordHeaderSize:]); ok {
+				r.batch = batch
+				return nil
+			}
+			log.Warn("Retry queue: head file is corrupted, ignoring it")
+		}
+	}
+	return s.removeHead()
+}
+
+// readSegment reads records of the segment, starting at offset.
+// Reading stops at the first damaged record, size is the end of the last valid record.
+func (s *segmentStore) readSegment(id uint64, offset int64) ([]storedRecord, int64, error) {
+	f, err := os.Open(s.segmentPath(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	var records []storedRecord
+	header := make([]byte, recordHeaderSize)
+	pos := offset
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warnf("Retry queue: segment %d has damaged record at %d, ignoring rest of the segment", id, pos)
+			}
+			break
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(f, payload); err != nil {
+			log.Warnf("Retry queue: segment %d has truncated record at %d, ignoring rest of the segment", id, pos)
+			break
+		}
+		batch, ok := decodeRecord(header, payload)
+		if !ok {
+			log.Warnf("Retry queue: segment %d has corrupted record at %d, ignoring rest of the segment", id, pos)
+			break
+		}
+		pos += recordHeaderSize + int64(size)
+		records = append(records, storedRecord{batch: batch, segment: id, end: pos})
+	}
+	return records, pos, nil
+}
+
+// encodeRecord creates record of batch
+func encodeRecord(batch *Batch) []byte {
+	record := make([]byte, recordHeaderSize+len(batch.Batch))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(batch.Batch)))
+	binary.BigEndian.PutUint64(record[8:16], uint64(batch.Expires.UnixNano()))
+	binary.BigEndian.PutUint32(record[16:20], uint32(batch.RetryAttempts))
+	copy(record[recordHeaderSize:], batch.Batch)
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(record[8:], crcTable))
+	return record
+}
+
+// decodeRecord creates batch from record header and payload, it returns false if the record is damaged
+func decodeRecord(header, payload []byte) (*Batch, bool) {
+	if int(binary.BigEndian.Uint32(header[0:4])) != len(payload) {
+		return nil, false
+	}
+	crc := crc32.Update(crc32.Checksum(header[8:recordHeaderSize], crcTable), crcTable, payload)
+	if crc != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, false
+	}
+	return &Batch{
+		Batch:         string(payload),
+		Expires:       time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16]))),
+		RetryAttempts: uint(binary.BigEndian.Uint32(header[16:20])),
+	}, true
+}
+
+// append stores batch at the end of the last segment
+func (s *segmentStore) append(batch *Batch) error {
+	record := encodeRecord(batch)
+	if s.tail == nil || (s.tailSize > 0 && s.tailSize+int64(len(record)) > s.segmentSize) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.tail.Write(record); err != nil {
+		return err
+	}
+	s.tailSize += int64(len(record))
+	s.records = append(s.records, storedRecord{batch: batch, segment: s.segments[len(s.segments)-1], end: s.tailSize})
+	return s.sync(false)
+}
+
+// remove marks batch, which must be the first stored one, as consumed.
+// Batches which were not stored are ignored.
+func (s *segmentStore) remove(batch *Batch) error {
+	if len(s.records) == 0 || s.records[0].batch != batch {
+		return nil
+	}
+	r := s.records[0]
+	s.records = s.records[1:]
+	if len(s.records) == 0 {
+		// nothing left, start from scratch
+		return s.clear()
+	}
+	for len(s.segments) > 0 && s.segments[0] < r.segment {
+		if err := os.Remove(s.segmentPath(s.segments[0])); err != nil {
+			return err
+		}
+		s.segments = s.segments[1:]
+	}
+	if err := s.writeCursor(r.segment, r.end); err != nil {
+		return err
+	}
+	// head file of the consumed record is ignored once the cursor moved
+	return s.removeHead()
+}
+
+// update persists current state of batch, which must be the first stored one, into the head file.
+// Batches which were not stored are ignored.
+func (s *segmentStore) update(batch *Batch) error {
+	if len(s.records) == 0 || s.records[0].batch != batch {
+		return nil
+	}
+	r := s.records[0]
+	data := make([]byte, headPositionSize, headPositionSize+recordHeaderSize+len(batch.Batch))
+	binary.BigEndian.PutUint64(data[0:8], r.segment)
+	binary.BigEndian.PutUint64(data[8:16], uint64(r.end))
+	data = append(data, encodeRecord(batch)...)
+	return s.writeFile(headFileName, data)
+}
+
+// removeHead removes the head file
+func (s *segmentStore) removeHead() error {
+	if err := os.Remove(filepath.Join(s.dir, headFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// clear removes all segments and the cursor
+func (s *segmentStore) clear() error {
+	if err := s.closeTail(); err != nil {
+		return err
+	}
+	for _, id := range s.segments {
+		if err := os.Remove(s.segmentPath(id)); err != nil {
+			return err
+		}
+	}
+	s.segments = s.segments[:0]
+	if err := os.Remove(filepath.Join(s.dir, cursorFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := s.removeHead(); err != nil {
+		return err
+	}
+	return s.sync(true)
+}
+
+// rotate starts a new segment
+func (s *segmentStore) rotate() error {
+	if err := s.closeTail(); err != nil {
+		return err
+	}
+	id := s.nextID
+	s.nextID++
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	s.tail = f
+	s.tailSize = 0
+	s.segments = append(s.segments, id)
+	return nil
+}
+
+func (s *segmentStore) closeTail() error {
+	if s.tail == nil {
+		return nil
+	}
+	var err error
+	if s.fsyncPolicy != write.FsyncNever {
+		err = s.tail.Sync()
+	}
+	if cerr := s.tail.Close(); err == nil {
+		err = cerr
+	}
+	s.tail = nil
+	return err
+}
+
+// sync flushes changes to the stable storage according to the fsync policy.
+// Directory is synced as well when dirChanged is true.
+func (s *segmentStore) sync(dirChanged bool) error {
+	switch s.fsyncPolicy {
+	case write.FsyncNever:
+		return nil
+	case write.FsyncInterval:
+		if time.Since(s.lastSync) < s.fsyncInterval {
+			return nil
+		}
+	}
+	s.lastSync = time.Now()
+	if s.tail != nil {
+		if err := s.tail.Sync(); err != nil {
+			return err
+		}
+	}
+	if dirChanged {
+		syncDir(s.dir)
+	}
+	return nil
+}
+
+func (s *segmentStore) readCursor() (uint64, int64, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, cursorFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	var segment uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(b), "%d %d", &segment, &offset); err != nil {
+		return 0, 0, fmt.Errorf("invalid retry queue cursor: %w", err)
+	}
+	return segment, offset, nil
+}
+
+// writeCursor atomically replaces cursor file
+func (s *segmentStore) writeCursor(segment uint64, offset int64) error {
+	return s.writeFile(cursorFileName, []byte(fmt.Sprintf("%d %d\n", segment, offset)))
+}
+
+// writeFile atomically replaces file name in the store directory with data
+func (s *segmentStore) writeFile(name string, data []byte) error {
+	path := filepath.Join(s.dir, name)
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil && s.fsyncPolicy == write.FsyncAlways {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	return s.sync(true)
+}
+
+// close syncs and closes the last segment
+func (s *segmentStore) close() error {
+	return s.closeTail()
+}
+
+func (s *segmentStore) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+// syncDir syncs directory entries, errors are ignored as not all platforms support it
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
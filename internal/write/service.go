@@ -8,14 +8,16 @@ package write
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
@@ -36,6 +38,8 @@ type Batch struct {
 	Evicted bool
 	// time when this batch expires
 	Expires time.Time
+	// size of the batch counted by the retry queue
+	queuedSize int
 }
 
 // NewBatch creates new batch
@@ -52,8 +56,8 @@ type BatchErrorCallback func(batch *Batch, error2 http2.Error) bool
 
 // Service is responsible for reliable writing of batches
 type Service struct {
-	org                  string
-	bucket               string
+	queueKey             string
+	v1                   bool
 	httpService          http2.Service
 	url                  string
 	lastWriteAttempt     time.Time
@@ -64,15 +68,16 @@ type Service struct {
 	errorCb              BatchErrorCallback
 	retryDelay           uint
 	retryAttempts        uint
+	stats                *stats
+	breaker              *circuitBreaker
+	// queueLock serializes handling of the retry queue when batches are written concurrently
+	queueLock sync.Mutex
+	// concurrent is true when HandleWrite is called concurrently by multiple write workers
+	concurrent bool
 }
 
 // NewService creates new write service
 func NewService(org string, bucket string, httpService http2.Service, options *write.Options) *Service {
-
-	retryBufferLimit := options.RetryBufferLimit() / options.BatchSize()
-	if retryBufferLimit == 0 {
-		retryBufferLimit = 1
-	}
 	u, _ := url.Parse(httpService.ServerAPIURL())
 	u, _ = u.Parse("write")
 	params := u.Query()
@@ -83,10 +88,37 @@ func NewService(org string, bucket string, httpService http2.Service, options *w
 		params.Set("consistency", string(options.Consistency()))
 	}
 	u.RawQuery = params.Encode()
-	writeURL := u.String()
-	return &Service{
-		org:                  org,
-		bucket:               bucket,
+	return newService(u.String(), org+"/"+bucket, httpService, options)
+}
+
+// NewServiceV1 creates new write service writing to database db and retention policy rp using the v1 compatibility endpoint.
+// Empty rp means the default retention policy.
+func NewServiceV1(db string, rp string, httpService http2.Service, options *write.Options) *Service {
+	u, _ := url.Parse(httpService.ServerURL())
+	u, _ = u.Parse("write")
+	params := u.Query()
+	params.Set("db", db)
+	if rp != "" {
+		params.Set("rp", rp)
+	}
+	params.Set("precision", precisionToV1String(options.Precision()))
+	if options.Consistency() != "" {
+		params.Set("consistency", string(options.Consistency()))
+	}
+	u.RawQuery = params.Encode()
+	w := newService(u.String(), "v1/"+db+"/"+rp, httpService, options)
+	w.v1 = true
+	return w
+}
+
+// newService creates new write service writing to writeURL
+func newService(writeURL string, queueKey string, httpService http2.Service, options *write.Options) *Service {
+	retryBufferLimit := options.RetryBufferLimit() / options.BatchSize()
+	if retryBufferLimit == 0 {
+		retryBufferLimit = 1
+	}
+	w := &Service{
+		queueKey:             queueKey,
 		httpService:          httpService,
 		url:                  writeURL,
 		writeOptions:         options,
@@ -94,7 +126,60 @@ func NewService(org string, bucket string, httpService http2.Service, options *w
 		retryExponentialBase: 2,
 		retryDelay:           options.RetryInterval(),
 		retryAttempts:        0,
+		stats:                newStats(),
+		breaker: newCircuitBreaker(options.CircuitBreakerThreshold(),
+			time.Duration(options.CircuitBreakerOpenTimeout())*time.Millisecond, options.CircuitBreakerCallback()),
+		concurrent: options.WriteWorkers() > 1,
+	}
+	w.retryQueue.onDiscard = w.discardQueued
+	return w
+}
+
+// OpenRetryQueue replaces the in-memory retry queue with a persistent one, if a retry queue directory is set in write options.
+// Batches persisted by previous runs are loaded and written first by the next HandleWrite call.
+// Each org/bucket pair, or database/retention policy pair of v1 writes, uses its own subdirectory of the retry queue directory.
+func (w *Service) OpenRetryQueue() error {
+	if w.writeOptions.RetryQueueDir() == "" {
+		return nil
 	}
+	dir := filepath.Join(w.writeOptions.RetryQueueDir(), url.PathEscape(w.queueKey))
+	store, batches, err := openSegmentStore(dir, w.writeOptions)
+	if err != nil {
+		return err
+	}
+	if len(batches) > 0 {
+		log.Infof("Retry queue: loaded %d batches from %s", len(batches), dir)
+	}
+	w.queueLock.Lock()
+	defer w.queueLock.Unlock()
+	w.retryQueue = newPersistentQueue(w.retryQueue.limit, store, batches, w.discardQueued)
+	w.stats.setQueue(w.retryQueue)
+	return nil
+}
+
+// HasPendingBatches returns true if there are batches waiting in the retry queue
+func (w *Service) HasPendingBatches() bool {
+	w.queueLock.Lock()
+	defer w.queueLock.Unlock()
+	return !w.retryQueue.isEmpty()
+}
+
+// Stats fills statistics of writing into ws.
+// It can be called concurrently with writing.
+func (w *Service) Stats(ws *write.Stats) {
+	w.stats.fill(ws)
+	ws.CircuitState = w.breaker.currentState()
+}
+
+// CircuitState returns state of the circuit breaker, it is always CircuitClosed when the circuit breaker is disabled
+func (w *Service) CircuitState() write.CircuitState {
+	return w.breaker.currentState()
+}
+
+// Close releases resources held by the retry queue.
+// Batches remaining in a persistent retry queue are kept for the next run.
+func (w *Service) Close() error {
+	return w.retryQueue.close()
 }
 
 // SetBatchErrorCallback sets callback allowing custom handling of failed writes.
@@ -104,6 +189,8 @@ func (w *Service) SetBatchErrorCallback(cb BatchErrorCallback) {
 }
 
 // HandleWrite handles writes of batches and handles retrying.
+// Lines rejected by the server because of invalid data are not retried,
+// the error describing them is returned after all batches are written.
 // Retrying is triggered by new writes, there is no scheduler.
 // It first checks retry queue, because it has the highest priority.
 // If there are some batches in retry queue, those are written and incoming batch is added to end of retry queue.
@@ -113,10 +200,37 @@ func (w *Service) SetBatchErrorCallback(cb BatchErrorCallback) {
 // Batch retry time is calculated based on #of attempts.
 // If writes continues failing and # of attempts reaches maximum or total retry time reaches maxRetryTime,
 // batch is discarded.
+// HandleWrite can be called concurrently when write options allow more write workers.
+// A new batch is then written in parallel with other batches, unless there are batches in the retry queue
+// or the circuit breaker is not closed. The retry queue is always handled by one caller at a time.
 func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
+	w.queueLock.Lock()
+	if w.concurrent && batch != nil && w.retryQueue.isEmpty() && w.breaker.currentState() == write.CircuitClosed {
+		w.queueLock.Unlock()
+		log.Debug("Write proc: writing batch in parallel")
+		perror := w.WriteBatch(ctx, batch)
+		if perror == nil {
+			w.recordResult(nil)
+			w.stats.written()
+			return nil
+		}
+		w.queueLock.Lock()
+		defer w.queueLock.Unlock()
+		return w.handleWrite(ctx, batch, perror)
+	}
+	defer w.queueLock.Unlock()
+	return w.handleWrite(ctx, batch, nil)
+}
+
+// handleWrite implements HandleWrite, queueLock must be held.
+// failed is error of batch already written by the caller, which is handled without writing the batch again.
+func (w *Service) handleWrite(ctx context.Context, batch *Batch, failed *http2.Error) error {
 	log.Debug("Write proc: received write request")
+	defer w.stats.setQueue(w.retryQueue)
 	batchToWrite := batch
 	retrying := false
+	// error about lines rejected by the server, which is not retried
+	var rejectedErr *http2.Error
 	for {
 		select {
 		case <-ctx.Done():
@@ -124,7 +238,7 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 			return ctx.Err()
 		default:
 		}
-		if !w.retryQueue.isEmpty() {
+		if failed == nil && !w.retryQueue.isEmpty() {
 			log.Debug("Write proc: taking batch from retry queue")
 			if !retrying {
 				b := w.retryQueue.first()
@@ -135,17 +249,20 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 					if !b.Evicted {
 						w.retryQueue.pop()
 					}
+					w.discard(b, write.DiscardExpired, nil)
 
 					continue
 				}
 
 				// Can we write? In case of retryable error we must wait a bit
-				if w.lastWriteAttempt.IsZero() || time.Now().After(w.lastWriteAttempt.Add(time.Millisecond*time.Duration(w.retryDelay))) {
+				if lastAttempt := w.lastAttempt(); lastAttempt.IsZero() || time.Now().After(lastAttempt.Add(time.Millisecond*time.Duration(w.retryDelay))) {
 					retrying = true
 				} else {
-					log.Warn("Write proc: cannot write yet, storing batch to queue")
-					if w.retryQueue.push(batch) {
-						log.Error("Write proc: Retry buffer full, discarding oldest batch")
+					if batch != nil {
+						log.Warn("Write proc: cannot write yet, storing batch to queue")
+						if w.retryQueue.push(batch) {
+							log.Error("Write proc: Retry buffer full, discarding oldest batch")
+						}
 					}
 					batchToWrite = nil
 				}
@@ -162,12 +279,47 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 		}
 		// write batch
 		if batchToWrite != nil {
-			perror := w.WriteBatch(ctx, batchToWrite)
+			var perror *http2.Error
+			if failed != nil {
+				// batch was already written by the caller
+				perror, failed = failed, nil
+			} else {
+				if !w.breaker.allow() {
+					perror := http2.NewError(write.ErrCircuitOpen)
+					// store new batch (not taken from queue)
+					if batch != nil {
+						if w.writeOptions.MaxRetries() == 0 {
+							log.Error("Write proc: circuit breaker is open, discarding batch")
+							w.discard(batch, write.DiscardWriteFailed, perror)
+						} else {
+							log.Warn("Write proc: circuit breaker is open, storing batch to queue")
+							if w.retryQueue.push(batch) {
+								log.Error("Write proc: Retry buffer full, discarding oldest batch")
+							}
+						}
+					}
+					return perror
+				}
+				if retrying {
+					atomic.AddUint64(&w.stats.retries, 1)
+				}
+				perror = w.WriteBatch(ctx, batchToWrite)
+			}
+			w.recordResult(perror)
 			if perror != nil {
 				if isIgnorableError(perror) {
 					log.Warnf("Write error: %s", perror.Error())
+					if isWriteError(perror) {
+						w.notifyRejectedLines(batchToWrite, perror)
+						w.discardRejectedLines(perror)
+						rejectedErr = perror
+					}
 				} else {
-					if w.writeOptions.MaxRetries() != 0 && (perror.StatusCode == 0 || perror.StatusCode >= http.StatusTooManyRequests) {
+					if !isRejectedData(perror) {
+						// lines rejected in other parts of a split batch were removed from it
+						w.discardRejectedLines(perror)
+					}
+					if w.writeOptions.MaxRetries() != 0 && w.writeOptions.RetryStrategy().Retryable(perror) {
 						log.Errorf("Write error: %s, batch kept for retrying\n", perror.Error())
 						if perror.RetryAfter > 0 {
 							w.retryDelay = perror.RetryAfter * 1000
@@ -179,20 +331,26 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 							if !batchToWrite.Evicted {
 								w.retryQueue.pop()
 							}
+							w.discard(batchToWrite, write.DiscardRejectedByCallback, perror)
 							return perror
 						}
 						// store new batch (not taken from queue)
 						if !batchToWrite.Evicted && batchToWrite != w.retryQueue.first() {
+							// count the attempt before the batch is persisted
+							batchToWrite.RetryAttempts++
 							if w.retryQueue.push(batch) {
 								log.Error("Retry buffer full, discarding oldest batch")
 							}
-						} else if batchToWrite.RetryAttempts == w.writeOptions.MaxRetries() {
-							log.Error("Reached maximum number of retries, discarding batch")
-							if !batchToWrite.Evicted {
-								w.retryQueue.pop()
+						} else {
+							if batchToWrite.RetryAttempts == w.writeOptions.MaxRetries() {
+								log.Error("Reached maximum number of retries, discarding batch")
+								if !batchToWrite.Evicted {
+									w.retryQueue.pop()
+								}
+								w.discard(batchToWrite, write.DiscardMaxRetries, perror)
 							}
+							batchToWrite.RetryAttempts++
 						}
-						batchToWrite.RetryAttempts++
 						w.retryAttempts++
 						log.Debugf("Write proc: next wait for write is %dms\n", w.retryDelay)
 					} else {
@@ -209,7 +367,14 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 							logMessage += fmt.Sprintf("\nSelected Response Headers:\n%s", logHeaders)
 						}
 						log.Error(logMessage)
+						w.notifyRejectedLines(batchToWrite, perror)
+						// batches remaining in the retry queue are retried later
+						if batchToWrite.Evicted || batchToWrite != w.retryQueue.first() {
+							w.discard(batchToWrite, write.DiscardWriteFailed, perror)
+						}
 					}
+					// batch kept in the queue could be partially written or its retry attempts changed
+					w.retryQueue.update(batchToWrite)
 					log.Errorf("Write failed (retry attempts %d): Status Code %d",
 						batchToWrite.RetryAttempts,
 						perror.StatusCode)
@@ -217,6 +382,7 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 				}
 			}
 
+			w.stats.written()
 			w.retryDelay = w.writeOptions.RetryInterval()
 			w.retryAttempts = 0
 			if retrying && !batchToWrite.Evicted {
@@ -227,9 +393,77 @@ func (w *Service) HandleWrite(ctx context.Context, batch *Batch) error {
 			break
 		}
 	}
+	if rejectedErr != nil {
+		return rejectedErr
+	}
 	return nil
 }
 
+// lastAttempt returns time of the last write request, it is updated also by batches written in parallel
+func (w *Service) lastAttempt() time.Time {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.lastWriteAttempt
+}
+
+// recordResult updates the circuit breaker with the result of a write.
+// Only errors retried by the retry strategy mean the server is unhealthy.
+func (w *Service) recordResult(perror *http2.Error) {
+	if perror != nil && !isIgnorableError(perror) && w.writeOptions.RetryStrategy().Retryable(perror) {
+		w.breaker.failure()
+	} else {
+		w.breaker.success()
+	}
+}
+
+// notifyRejectedLines notifies error callback about lines rejected by the server.
+// The batch is not retried, so the callback result is ignored.
+func (w *Service) notifyRejectedLines(batch *Batch, perror *http2.Error) {
+	if w.errorCb != nil && isWriteError(perror) {
+		w.errorCb(batch, *perror)
+	}
+}
+
+// discard passes batch discarded with reason to the dead-letter handler, if set.
+// perror is the last write error, it can be nil.
+func (w *Service) discard(batch *Batch, reason write.DiscardReason, perror *http2.Error) {
+	w.stats.discarded(reason)
+	h := w.writeOptions.DeadLetterHandler()
+	if h == nil || batch == nil {
+		return
+	}
+	// avoid passing typed nil as the error interface
+	var err error
+	if perror != nil {
+		err = perror
+	}
+	h.HandleDiscarded(batch.Batch, reason, err)
+}
+
+// discardQueued handles batches discarded by the retry queue
+func (w *Service) discardQueued(batch *Batch, reason write.DiscardReason) {
+	w.discard(batch, reason, nil)
+}
+
+// discardRejectedLines passes lines rejected by the server to the dead-letter handler.
+// Rejected lines are discarded only if the server response allows to determine them.
+func (w *Service) discardRejectedLines(perror *http2.Error) {
+	var werr *write.WriteError
+	if w.writeOptions.DeadLetterHandler() == nil || !errors.As(perror, &werr) {
+		return
+	}
+	var sb strings.Builder
+	for _, l := range werr.Lines {
+		if l.Line != "" {
+			sb.WriteString(l.Line)
+			sb.WriteString("\n")
+		}
+	}
+	if sb.Len() > 0 {
+		w.discard(&Batch{Batch: sb.String()}, write.DiscardRejectedData, perror)
+	}
+}
+
 // Non-retryable errors
 const (
 	errStringHintedHandoffNotEmpty = "hinted handoff queue not empty"
@@ -264,43 +498,98 @@ func isIgnorableError(error *http2.Error) bool {
 	return false
 }
 
-// computeRetryDelay calculates retry delay.
-// Retry delay is calculated as random value within the interval
-// [retry_interval * exponential_base^(attempts) and retry_interval * exponential_base^(attempts+1)]
+// computeRetryDelay calculates retry delay using the retry strategy
 func (w *Service) computeRetryDelay(attempts uint) uint {
-	minDelay := int(w.writeOptions.RetryInterval() * pow(w.writeOptions.ExponentialBase(), attempts))
-	maxDelay := int(w.writeOptions.RetryInterval() * pow(w.writeOptions.ExponentialBase(), attempts+1))
-	diff := maxDelay - minDelay
-	if diff <= 0 { //check overflows
-		return w.writeOptions.MaxRetryInterval()
+	return w.writeOptions.RetryStrategy().RetryDelay(attempts, w.retryDelay)
+}
+
+// WriteBatch performs actual writing via HTTP service.
+// When the server rejects the batch as too large (HTTP status 413), the batch is split along line boundaries
+// into smaller batches, which are written recursively. If some lines still cannot be written,
+// the batch is updated to hold only those lines and the last error is returned.
+// When the server rejects lines of the batch because of invalid data, the returned error
+// holds *write.WriteError describing rejected lines as the nested error.
+func (w *Service) WriteBatch(ctx context.Context, batch *Batch) *http2.Error {
+	remaining, perror := w.writeSplitting(ctx, batch.Batch, 0)
+	if perror != nil && !isRejectedData(perror) && remaining != batch.Batch {
+		log.Warnf("Write: %d of %d bytes of batch were written", len(batch.Batch)-len(remaining), len(batch.Batch))
+		batch.Batch = remaining
+	}
+	return perror
+}
+
+// writeSplitting writes body and in case of HTTP status 413 splits it in halves, which are written recursively.
+// lineOffset is number of batch lines preceding body.
+// It returns lines that were not written and the last error.
+// Splitting stops on errors other than 413 or rejected data, lines not written yet are returned as well.
+// Lines of parts rejected because of invalid data are not returned, the error keeps them in its WriteError.
+func (w *Service) writeSplitting(ctx context.Context, body string, lineOffset int) (string, *http2.Error) {
+	perror := w.writeBody(ctx, body)
+	if perror == nil {
+		w.stats.bodyWritten(body, nil)
+		return "", nil
+	}
+	if perror.StatusCode != http.StatusRequestEntityTooLarge {
+		perror = withWriteError(perror, body, lineOffset)
+		if isIgnorableError(perror) {
+			// lines not rejected by the server were written
+			var werr *write.WriteError
+			if errors.As(perror, &werr) {
+				w.stats.bodyWritten(body, werr.Lines)
+			} else {
+				w.stats.bodyWritten(body, nil)
+			}
+		}
+		return body, perror
 	}
-	retryDelay := uint(rand.Intn(diff) + minDelay)
-	if retryDelay > w.writeOptions.MaxRetryInterval() {
-		retryDelay = w.writeOptions.MaxRetryInterval()
+	first, second, ok := splitLines(body)
+	if !ok {
+		log.Errorf("Write: line of %d bytes is too large for the server", len(body))
+		return body, perror
 	}
-	return retryDelay
+	log.Debugf("Write: payload of %d bytes too large, splitting", len(body))
+	remaining, perror := w.writeSplitting(ctx, first, lineOffset)
+	if isRejectedData(perror) {
+		// rejected lines are not retried
+		remaining = ""
+	} else if perror != nil && perror.StatusCode != http.StatusRequestEntityTooLarge {
+		return remaining + second, perror
+	}
+	remaining2, perror2 := w.writeSplitting(ctx, second, lineOffset+strings.Count(first, "\n"))
+	if isRejectedData(perror2) {
+		remaining2 = ""
+	}
+	return remaining + remaining2, mergeErrors(perror, perror2)
 }
 
-// pow computes x**y
-func pow(x, y uint) uint {
-	p := uint(1)
-	if y == 0 {
-		return 1
+// splitLines splits body in two parts at the line boundary closest to the middle.
+// It returns false if body contains a single line.
+func splitLines(body string) (string, string, bool) {
+	// ignore trailing new line
+	content := strings.TrimSuffix(body, "\n")
+	if len(content) == 0 {
+		return "", "", false
 	}
-	for i := uint(1); i <= y; i++ {
-		p = p * x
+	mid := len(content) / 2
+	i := strings.LastIndexByte(content[:mid+1], '\n')
+	if i < 0 {
+		i = strings.IndexByte(content[mid:], '\n')
+		if i < 0 {
+			return "", "", false
+		}
+		i += mid
 	}
-	return p
+	return body[:i+1], body[i+1:], true
 }
 
-// WriteBatch performs actual writing via HTTP service
-func (w *Service) WriteBatch(ctx context.Context, batch *Batch) *http2.Error {
+// writeBody sends body via HTTP service
+func (w *Service) writeBody(ctx context.Context, data string) *http2.Error {
 	var body io.Reader
 	var err error
-	body = strings.NewReader(batch.Batch)
+	body = strings.NewReader(data)
 
 	if log.Level() >= ilog.DebugLevel {
-		log.Debugf("Writing batch: %s", batch.Batch)
+		log.Debugf("Writing batch: %s", data)
 	}
 	if w.writeOptions.UseGZip() {
 		body, err = gzip.CompressWithGzip(body)
@@ -311,26 +600,79 @@ func (w *Service) WriteBatch(ctx context.Context, batch *Batch) *http2.Error {
 	w.lock.Lock()
 	w.lastWriteAttempt = time.Now()
 	w.lock.Unlock()
+	start := time.Now()
 	perror := w.httpService.DoPostRequest(ctx, w.url, body, func(req *http.Request) {
 		if w.writeOptions.UseGZip() {
 			req.Header.Set("Content-Encoding", "gzip")
 		}
+		if w.v1 {
+			if username, password := w.writeOptions.V1Credentials(); username != "" {
+				req.SetBasicAuth(username, password)
+			}
+		}
 	}, func(r *http.Response) error {
 		return r.Body.Close()
 	})
+	if perror != nil {
+		w.stats.request(time.Since(start), perror)
+	} else {
+		w.stats.request(time.Since(start), nil)
+	}
 	return perror
 }
 
-// Flush sends batches from retry queue immediately, without retrying
+// Flush sends batches from retry queue immediately, without retrying.
+// Batches of a persistent retry queue are removed only when written successfully,
+// flushing stops on the first error and remaining batches are kept for the next run.
+// While the circuit breaker is open, batches are not sent. Batches of a persistent retry queue are kept,
+// other batches are discarded.
 func (w *Service) Flush() {
+	w.queueLock.Lock()
+	defer w.queueLock.Unlock()
+	defer w.stats.setQueue(w.retryQueue)
 	for !w.retryQueue.isEmpty() {
+		if w.retryQueue.isPersistent() {
+			b := w.retryQueue.first()
+			if time.Now().After(b.Expires) {
+				log.Error("Oldest batch in retry queue expired, discarding")
+				w.retryQueue.pop()
+				w.discard(b, write.DiscardExpired, nil)
+				continue
+			}
+			if !w.breaker.allow() {
+				log.Warn("Circuit breaker is open, keeping batches in retry queue")
+				return
+			}
+			err := w.WriteBatch(context.Background(), b)
+			w.recordResult(err)
+			if err != nil {
+				log.Errorf("Error flushing batch from retry queue, keeping remaining batches: %s", err.Error())
+				// batch could be partially written
+				w.retryQueue.update(b)
+				return
+			}
+			w.stats.written()
+			w.retryQueue.pop()
+			continue
+		}
 		b := w.retryQueue.pop()
 		if time.Now().After(b.Expires) {
 			log.Error("Oldest batch in retry queue expired, discarding")
+			w.discard(b, write.DiscardExpired, nil)
 			continue
 		}
-		if err := w.WriteBatch(context.Background(), b); err != nil {
+		if !w.breaker.allow() {
+			log.Error("Circuit breaker is open, discarding batch from retry queue")
+			w.discard(b, write.DiscardWriteFailed, http2.NewError(write.ErrCircuitOpen))
+			continue
+		}
+		err := w.WriteBatch(context.Background(), b)
+		w.recordResult(err)
+		if err != nil {
 			log.Errorf("Error flushing batch from retry queue: %w", err.Unwrap())
+			w.discard(b, write.DiscardWriteFailed, err)
+		} else {
+			w.stats.written()
 		}
 	}
 }
@@ -382,22 +724,97 @@ func existTag(tags []*lp.Tag, key string) bool {
 	return false
 }
 
-// EncodePoints creates line protocol string from points
+// ProcessPoints applies point processors set in write options to points.
+// Points dropped by processors are nil in the returned slice, so that indexes of the other points are kept.
+// Processors get copies of points, points are returned unchanged if there are no processors.
+func (w *Service) ProcessPoints(points ...*write.Point) []*write.Point {
+	processors := w.writeOptions.PointProcessors()
+	if len(processors) == 0 {
+		return points
+	}
+	processed := make([]*write.Point, len(points))
+	for i, point := range points {
+		processed[i] = write.ProcessPoint(point, processors)
+	}
+	return processed
+}
+
+// EncodePoints creates line protocol string from points. Nil points, e.g. dropped by ProcessPoints, are left out.
+// Points which cannot be encoded, or which are rejected by validation, strict field types or field type registry set in write options,
+// are left out and described by returned *write.ValidationError. Line protocol of the other points is returned also in such case.
 func (w *Service) EncodePoints(points ...*write.Point) (string, error) {
 	var buffer bytes.Buffer
+	var invalid []write.InvalidData
 	e := lp.NewEncoder(&buffer)
 	e.SetFieldTypeSupport(lp.UintSupport)
 	e.FailOnFieldErr(true)
 	e.SetPrecision(w.writeOptions.Precision())
-	for _, point := range points {
-		_, err := e.Encode(w.pointToEncode(point))
+	for i, point := range points {
+		if point == nil {
+			continue
+		}
+		err := w.checkPoint(point)
+		if err == nil {
+			size := buffer.Len()
+			if _, err = e.Encode(w.pointToEncode(point)); err != nil {
+				buffer.Truncate(size)
+			}
+		}
 		if err != nil {
-			return "", err
+			invalid = append(invalid, write.InvalidData{Index: i, Err: err})
 		}
 	}
+	if len(invalid) > 0 {
+		return buffer.String(), &write.ValidationError{Invalid: invalid}
+	}
 	return buffer.String(), nil
 }
 
+// checkPoint validates point according to write options
+func (w *Service) checkPoint(point *write.Point) error {
+	if err := write.ValidatePoint(point, w.writeOptions.ValidationLevel()); err != nil {
+		return err
+	}
+	if w.writeOptions.StrictFieldTypes() {
+		if err := point.Err(); err != nil {
+			return err
+		}
+	}
+	if registry := w.writeOptions.FieldTypeRegistry(); registry != nil {
+		if err := registry.Check(point); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRecords validates line protocol records according to validation level of write options.
+// It returns valid records and *write.ValidationError describing invalid records, if there are any.
+func (w *Service) ValidateRecords(records ...string) ([]string, error) {
+	level := w.writeOptions.ValidationLevel()
+	if level == write.ValidationNone {
+		return records, nil
+	}
+	var invalid []write.InvalidData
+	valid := records
+	for i, record := range records {
+		err := write.ValidateRecord(record, w.writeOptions.Precision(), level)
+		if err != nil && invalid == nil {
+			// copy valid records so far
+			valid = append(make([]string, 0, len(records)), records[:i]...)
+		}
+		if err != nil {
+			invalid = append(invalid, write.InvalidData{Index: i, Line: record, Err: err})
+		} else if invalid != nil {
+			valid = append(valid, record)
+		}
+	}
+	if len(invalid) > 0 {
+		return valid, &write.ValidationError{Invalid: invalid}
+	}
+	return records, nil
+}
+
 // pointToEncode determines whether default tags should be applied
 // and returns point with default tags instead of point
 func (w *Service) pointToEncode(point *write.Point) lp.Metric {
@@ -430,3 +847,17 @@ func precisionToString(precision time.Duration) string {
 	}
 	return prec
 }
+
+// precisionToV1String returns precision parameter of the v1 write endpoint
+func precisionToV1String(precision time.Duration) string {
+	prec := "ns"
+	switch precision {
+	case time.Microsecond:
+		prec = "u"
+	case time.Millisecond:
+		prec = "ms"
+	case time.Second:
+		prec = "s"
+	}
+	return prec
+}
@@ -5,10 +5,13 @@
 package write
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	ilog "log"
+	"math"
 	ihttp "net/http"
 	"net/http/httptest"
 	"runtime"
@@ -75,6 +78,66 @@ func TestAddDefaultTags(t *testing.T) {
 	assert.Len(t, p.TagList(), 2)
 }
 
+func TestEncodePointsValidation(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions()
+	srv := NewService("org", "buc", hs, opts)
+	points := []*write.Point{
+		write.NewPointWithMeasurement("m").AddIntField("v", 1),
+		write.NewPointWithMeasurement("m").AddFloatField("v", math.NaN()),
+		write.NewPointWithMeasurement("m").AddTag("_t", "a").AddIntField("v", 3),
+		write.NewPointWithMeasurement("").AddIntField("v", 4),
+	}
+
+	// encoding errors are reported per point also without validation
+	s, err := srv.EncodePoints(points...)
+	assert.Equal(t, "m v=1i\nm,_t=a v=3i\n", s)
+	var verr *write.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Invalid, 2)
+	assert.Equal(t, 1, verr.Invalid[0].Index)
+	assert.Equal(t, 3, verr.Invalid[1].Index)
+
+	opts.SetValidationLevel(write.ValidationStrict)
+	s, err = srv.EncodePoints(points...)
+	assert.Equal(t, "m v=1i\n", s)
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Invalid, 3)
+	assert.Equal(t, "field 'v': unsupported float value NaN", verr.Invalid[0].Err.Error())
+	assert.Equal(t, "tag key '_t' begins with '_'", verr.Invalid[1].Err.Error())
+	assert.Equal(t, "empty measurement", verr.Invalid[2].Err.Error())
+
+	s, err = srv.EncodePoints(points[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "m v=1i\n", s)
+}
+
+func TestValidateRecords(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8888")
+	opts := write.DefaultOptions()
+	srv := NewService("org", "buc", hs, opts)
+	records := []string{"m v=1", "m v=", "m _v=1", "m v=2"}
+
+	valid, err := srv.ValidateRecords(records...)
+	assert.NoError(t, err)
+	assert.Equal(t, records, valid)
+
+	opts.SetValidationLevel(write.ValidationBasic)
+	valid, err = srv.ValidateRecords(records...)
+	assert.Equal(t, []string{"m v=1", "m _v=1", "m v=2"}, valid)
+	var verr *write.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Invalid, 1)
+	assert.Equal(t, write.InvalidData{Index: 1, Line: "m v=", Err: verr.Invalid[0].Err}, verr.Invalid[0])
+
+	opts.SetValidationLevel(write.ValidationStrict)
+	valid, err = srv.ValidateRecords(records...)
+	assert.Equal(t, []string{"m v=1", "m v=2"}, valid)
+	require.True(t, errors.As(err, &verr))
+	assert.Len(t, verr.Invalid, 2)
+	assert.Equal(t, "invalid record 1: line 1, column 5: missing field value (and 1 more)", err.Error())
+}
+
 func TestRetryStrategy(t *testing.T) {
 	log.Log.SetLogLevel(log.DebugLevel)
 	hs := test.NewTestService(t, "http://localhost:8086")
@@ -343,7 +406,7 @@ func TestMaxRetryTime(t *testing.T) {
 	assert.Equal(t, 1, srv.retryQueue.list.Len())
 
 	//wait until remaining accumulated retryDelay has passed, because there hasn't been a successful write yet
-	<-time.After(time.Until(srv.lastWriteAttempt.Add(time.Millisecond * time.Duration(srv.retryDelay))))
+	<-time.After(time.Until(srv.lastAttempt().Add(time.Millisecond * time.Duration(srv.retryDelay))))
 	// Clear error and let write pass
 	hs.SetReplyError(nil)
 	// A batch from retry queue will be sent first
@@ -445,14 +508,6 @@ func TestWriteContextCancel(t *testing.T) {
 	assert.Len(t, hs.Lines(), 0)
 }
 
-func TestPow(t *testing.T) {
-	assert.EqualValues(t, 1, pow(10, 0))
-	assert.EqualValues(t, 10, pow(10, 1))
-	assert.EqualValues(t, 4, pow(2, 2))
-	assert.EqualValues(t, 1, pow(1, 2))
-	assert.EqualValues(t, 125, pow(5, 3))
-}
-
 func assertBetween(t *testing.T, val, min, max uint) {
 	t.Helper()
 	assert.True(t, val >= min && val <= max, fmt.Sprintf("%d is outside <%d;%d>", val, min, max))
@@ -568,7 +623,7 @@ func TestRetryIntervalAccumulation(t *testing.T) {
 	hs.SetReplyError(nil)
 
 	// Wait until write queue is ready to retry; in meantime, keep writing and confirming queue state
-	retryTimeout := srv.lastWriteAttempt.Add(time.Millisecond * time.Duration(srv.retryDelay))
+	retryTimeout := srv.lastAttempt().Add(time.Millisecond * time.Duration(srv.retryDelay))
 	log.Log.Infof("Continuing to write for %d ms until flushing write attempt", time.Until(retryTimeout).Milliseconds())
 	for ; time.Until(retryTimeout) >= 0; i++ {
 		b := NewBatch(fmt.Sprintf("%d\n", i), opts.MaxRetryTime())
@@ -693,14 +748,18 @@ func TestIgnoreErrors(t *testing.T) {
 	b := NewBatch("1", 20)
 	err := srv.HandleWrite(ctx, b)
 	assert.NoError(t, err)
+	// rejected data is not retried, but reported
+	var werr *write.WriteError
 	err = srv.HandleWrite(ctx, b)
-	assert.NoError(t, err)
+	assert.ErrorAs(t, err, &werr)
 	err = srv.HandleWrite(ctx, b)
-	assert.NoError(t, err)
+	assert.ErrorAs(t, err, &werr)
 	err = srv.HandleWrite(ctx, b)
-	assert.NoError(t, err)
+	assert.ErrorAs(t, err, &werr)
+	assert.True(t, srv.retryQueue.isEmpty())
 	err = srv.HandleWrite(ctx, b)
 	assert.Error(t, err)
+	assert.False(t, errors.As(err, &werr))
 }
 
 func TestHttpErrorHeaders(t *testing.T) {
@@ -719,3 +778,419 @@ func TestHttpErrorHeaders(t *testing.T) {
 	assert.Equal(t, "Not All Correct", err.(*http.Error).Header.Get("X-Test-Val1"))
 	assert.Equal(t, "Atlas LV-3B", err.(*http.Error).Header.Get("X-Test-Val2"))
 }
+
+func TestSplitLines(t *testing.T) {
+	first, second, ok := splitLines("a\nb\nc\nd\n")
+	require.True(t, ok)
+	assert.Equal(t, "a\nb\n", first)
+	assert.Equal(t, "c\nd\n", second)
+	first, second, ok = splitLines("aaaaaa\nb")
+	require.True(t, ok)
+	assert.Equal(t, "aaaaaa\n", first)
+	assert.Equal(t, "b", second)
+	_, _, ok = splitLines("a\n")
+	assert.False(t, ok)
+	_, _, ok = splitLines("abc")
+	assert.False(t, ok)
+	_, _, ok = splitLines("\n")
+	assert.False(t, ok)
+}
+
+func TestSplitTooLargeBatch(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8086")
+	requests := 0
+	hs.SetRequestHandler(func(url string, body io.Reader) error {
+		requests++
+		b, _ := io.ReadAll(body)
+		if len(b) > 25 {
+			return &http.Error{StatusCode: 413, Code: "Request Entity Too Large"}
+		}
+		return hs.DecodeLines(bytes.NewReader(b))
+	})
+	srv := NewService("my-org", "my-bucket", hs, write.DefaultOptions())
+	// each line has 10 bytes
+	lines := make([]string, 8)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("test f=%di", i)
+	}
+	b := NewBatch(strings.Join(lines, "\n")+"\n", 1000)
+	err := srv.WriteBatch(context.Background(), b)
+	require.Nil(t, err)
+	assert.Equal(t, lines, hs.Lines())
+	// 1x80 + 2x40 + 4x20
+	assert.Equal(t, 7, requests)
+
+	hs.Close()
+	hs.SetRequestHandler(func(url string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		if len(b) > 25 {
+			return &http.Error{StatusCode: 413, Code: "Request Entity Too Large"}
+		}
+		return hs.DecodeLines(bytes.NewReader(b))
+	})
+	longLine := "test f=\"very long string value\"\n"
+	b = NewBatch(lines[0]+"\n"+longLine+lines[1]+"\n"+lines[2], 1000)
+	err = srv.WriteBatch(context.Background(), b)
+	require.NotNil(t, err)
+	assert.Equal(t, 413, err.StatusCode)
+	assert.Equal(t, longLine, b.Batch)
+	assert.Equal(t, []string{lines[0], lines[1], lines[2]}, hs.Lines())
+}
+
+func TestSplitStopsOnOtherError(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8086")
+	requests := 0
+	hs.SetRequestHandler(func(url string, body io.Reader) error {
+		requests++
+		b, _ := io.ReadAll(body)
+		if len(b) > 25 {
+			return &http.Error{StatusCode: 413}
+		}
+		if requests > 2 {
+			return &http.Error{StatusCode: 503}
+		}
+		return hs.DecodeLines(bytes.NewReader(b))
+	})
+	srv := NewService("my-org", "my-bucket", hs, write.DefaultOptions())
+	b := NewBatch("test f=0i\ntest f=1i\ntest f=2i\ntest f=3i\n", 1000)
+	err := srv.WriteBatch(context.Background(), b)
+	require.NotNil(t, err)
+	assert.Equal(t, 503, err.StatusCode)
+	assert.Equal(t, []string{"test f=0i", "test f=1i"}, hs.Lines())
+	assert.Equal(t, "test f=2i\ntest f=3i\n", b.Batch)
+	assert.Equal(t, 3, requests)
+}
+
+func TestSplitRejectedAndFailed(t *testing.T) {
+	var list []discarded
+	handler := write.DeadLetterHandlerFunc(func(lines string, reason write.DiscardReason, err error) {
+		list = append(list, discarded{lines, reason, err})
+	})
+	hs := test.NewTestService(t, "http://localhost:8086")
+	hs.SetRequestHandler(func(url string, body io.Reader) error {
+		b, _ := io.ReadAll(body)
+		switch {
+		case len(b) > 25:
+			return &http.Error{StatusCode: 413}
+		case strings.HasPrefix(string(b), "test f=0i"):
+			return &http.Error{StatusCode: 400, Code: "invalid", Message: "failed to parse line protocol:\nline 1: invalid field format"}
+		default:
+			return &http.Error{StatusCode: 503}
+		}
+	})
+	opts := write.DefaultOptions().SetDeadLetterHandler(handler)
+	srv := NewService("my-org", "my-bucket", hs, opts)
+	b := NewBatch("test f=0i\ntest f=1i\ntest f=2i\ntest f=3i\n", opts.MaxRetryTime())
+	err := srv.HandleWrite(context.Background(), b)
+	require.NotNil(t, err)
+	var perror *http.Error
+	require.True(t, errors.As(err, &perror))
+	assert.Equal(t, 503, perror.StatusCode)
+	var werr *write.WriteError
+	require.True(t, errors.As(err, &werr))
+	assert.Equal(t, []write.RejectedLine{{Number: 1, Line: "test f=0i", Reason: "invalid field format"}}, werr.Lines)
+	// only the failed part is retried
+	assert.Equal(t, "test f=2i\ntest f=3i\n", b.Batch)
+	assert.True(t, srv.HasPendingBatches())
+	require.Len(t, list, 1)
+	assert.Equal(t, "test f=0i\n", list[0].lines)
+	assert.Equal(t, write.DiscardRejectedData, list[0].reason)
+}
+
+type discarded struct {
+	lines  string
+	reason write.DiscardReason
+	err    error
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	var list []discarded
+	handler := write.DeadLetterHandlerFunc(func(lines string, reason write.DiscardReason, err error) {
+		list = append(list, discarded{lines, reason, err})
+	})
+	hs := test.NewTestService(t, "http://localhost:8086")
+	ctx := context.Background()
+
+	// retry buffer full
+	opts := write.DefaultOptions().SetBatchSize(1).SetRetryBufferLimit(1).SetDeadLetterHandler(handler)
+	srv := NewService("my-org", "my-bucket", hs, opts)
+	hs.SetReplyError(&http.Error{StatusCode: 429})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	assert.NoError(t, srv.HandleWrite(ctx, NewBatch("2\n", opts.MaxRetryTime())))
+	require.Len(t, list, 1)
+	assert.Equal(t, discarded{"1\n", write.DiscardRetryBufferFull, nil}, list[0])
+
+	// max retries
+	list = nil
+	opts = write.DefaultOptions().SetRetryInterval(1).SetMaxRetries(1).SetDeadLetterHandler(handler)
+	srv = NewService("my-org", "my-bucket", hs, opts)
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	<-time.After(time.Millisecond*time.Duration(srv.retryDelay) + time.Millisecond)
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("2\n", opts.MaxRetryTime())))
+	require.Len(t, list, 1)
+	assert.Equal(t, "1\n", list[0].lines)
+	assert.Equal(t, write.DiscardMaxRetries, list[0].reason)
+	assert.EqualError(t, list[0].err, "Unexpected status code 429")
+
+	// expired
+	list = nil
+	opts = write.DefaultOptions().SetRetryInterval(1).SetDeadLetterHandler(handler)
+	srv = NewService("my-org", "my-bucket", hs, opts)
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", 1)))
+	<-time.After(5 * time.Millisecond)
+	hs.SetReplyError(nil)
+	assert.NoError(t, srv.HandleWrite(ctx, NewBatch("2\n", opts.MaxRetryTime())))
+	require.Len(t, list, 1)
+	assert.Equal(t, discarded{"1\n", write.DiscardExpired, nil}, list[0])
+
+	// rejected by callback
+	list = nil
+	srv = NewService("my-org", "my-bucket", hs, opts)
+	srv.SetBatchErrorCallback(func(_ *Batch, _ http.Error) bool {
+		return false
+	})
+	hs.SetReplyError(&http.Error{StatusCode: 503})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	require.Len(t, list, 1)
+	assert.Equal(t, "1\n", list[0].lines)
+	assert.Equal(t, write.DiscardRejectedByCallback, list[0].reason)
+	assert.Error(t, list[0].err)
+
+	// not retryable error
+	list = nil
+	srv = NewService("my-org", "my-bucket", hs, opts)
+	hs.SetReplyError(&http.Error{StatusCode: 401, Code: "unauthorized", Message: "unauthorized access"})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	require.Len(t, list, 1)
+	assert.Equal(t, "1\n", list[0].lines)
+	assert.Equal(t, write.DiscardWriteFailed, list[0].reason)
+	assert.EqualError(t, list[0].err, "unauthorized: unauthorized access")
+
+	// rejected data, only rejected lines are discarded
+	list = nil
+	hs.SetReplyError(&http.Error{StatusCode: 400, Code: "invalid", Message: "unable to parse 'm f=': missing field value"})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("m f=1\nm f=\n", opts.MaxRetryTime())))
+	require.Len(t, list, 1)
+	assert.Equal(t, "m f=\n", list[0].lines)
+	assert.Equal(t, write.DiscardRejectedData, list[0].reason)
+	var werr *write.WriteError
+	assert.True(t, errors.As(list[0].err, &werr))
+	// rejected line is not counted as written
+	var stats write.Stats
+	srv.Stats(&stats)
+	assert.EqualValues(t, 1, stats.BatchesWritten)
+	assert.EqualValues(t, 1, stats.LinesWritten)
+	assert.EqualValues(t, len("m f=1\n"), stats.BytesWritten)
+}
+
+// testRetryStrategy retries only service unavailable errors with constant delay
+type testRetryStrategy struct {
+	delays []uint
+}
+
+func (s *testRetryStrategy) Retryable(err *http.Error) bool {
+	return err.StatusCode == 503
+}
+
+func (s *testRetryStrategy) RetryDelay(_ uint, previousDelay uint) uint {
+	s.delays = append(s.delays, previousDelay)
+	return 1
+}
+
+func TestRetryStrategyOption(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8086")
+	strategy := &testRetryStrategy{}
+	opts := write.DefaultOptions().SetRetryStrategy(strategy)
+	ctx := context.Background()
+	srv := NewService("my-org", "my-bucket", hs, opts)
+
+	hs.SetReplyError(&http.Error{StatusCode: 503})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	assert.EqualValues(t, 1, srv.retryDelay)
+	assert.Equal(t, 1, srv.retryQueue.list.Len())
+	assert.Equal(t, []uint{opts.RetryInterval()}, strategy.delays)
+
+	<-time.After(2 * time.Millisecond)
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("2\n", opts.MaxRetryTime())))
+	assert.Equal(t, 2, srv.retryQueue.list.Len())
+	assert.Equal(t, []uint{opts.RetryInterval(), 1}, strategy.delays)
+
+	// 429 is not retried by the strategy, retry delay is not computed
+	hs.SetReplyError(&http.Error{StatusCode: 429})
+	<-time.After(2 * time.Millisecond)
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("3\n", opts.MaxRetryTime())))
+	assert.Len(t, strategy.delays, 2)
+	assert.Equal(t, 3, srv.retryQueue.list.Len())
+
+	hs.SetReplyError(nil)
+	<-time.After(2 * time.Millisecond)
+	assert.NoError(t, srv.HandleWrite(ctx, nil))
+	assert.Equal(t, 0, srv.retryQueue.list.Len())
+	assert.Equal(t, []string{"1", "2", "3"}, hs.Lines())
+}
+
+func TestCircuitBreakerService(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8086")
+	var states []write.CircuitState
+	opts := write.DefaultOptions().
+		SetRetryStrategy(write.NewConstantRetryStrategy(0)).
+		SetCircuitBreakerThreshold(2).
+		SetCircuitBreakerOpenTimeout(20).
+		SetCircuitBreakerCallback(func(_, to write.CircuitState) {
+			states = append(states, to)
+		})
+	ctx := context.Background()
+	srv := NewService("my-org", "my-bucket", hs, opts)
+
+	hs.SetReplyError(&http.Error{StatusCode: 503})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	assert.Equal(t, write.CircuitClosed, srv.CircuitState())
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("2\n", opts.MaxRetryTime())))
+	assert.Equal(t, write.CircuitOpen, srv.CircuitState())
+	assert.Equal(t, 2, hs.Requests())
+
+	// fail fast, batches are stored in the queue
+	err := srv.HandleWrite(ctx, NewBatch("3\n", opts.MaxRetryTime()))
+	assert.True(t, errors.Is(err, write.ErrCircuitOpen))
+	assert.Equal(t, 3, srv.retryQueue.list.Len())
+	assert.Equal(t, 2, hs.Requests())
+
+	// probe
+	hs.SetReplyError(nil)
+	<-time.After(25 * time.Millisecond)
+	assert.NoError(t, srv.HandleWrite(ctx, NewBatch("4\n", opts.MaxRetryTime())))
+	assert.Equal(t, write.CircuitClosed, srv.CircuitState())
+	assert.Equal(t, 0, srv.retryQueue.list.Len())
+	assert.Equal(t, []string{"1", "2", "3", "4"}, hs.Lines())
+	assert.Equal(t, []write.CircuitState{write.CircuitOpen, write.CircuitHalfOpen, write.CircuitClosed}, states)
+
+	var stats write.Stats
+	srv.Stats(&stats)
+	assert.Equal(t, write.CircuitClosed, stats.CircuitState)
+}
+
+func TestFlushCircuitOpen(t *testing.T) {
+	var list []discarded
+	handler := write.DeadLetterHandlerFunc(func(lines string, reason write.DiscardReason, err error) {
+		list = append(list, discarded{lines, reason, err})
+	})
+	hs := test.NewTestService(t, "http://localhost:8086")
+	opts := write.DefaultOptions().
+		SetCircuitBreakerThreshold(1).
+		SetCircuitBreakerOpenTimeout(60_000).
+		SetDeadLetterHandler(handler)
+	ctx := context.Background()
+
+	// batches of the in-memory queue are discarded
+	srv := NewService("my-org", "my-bucket", hs, opts)
+	hs.SetReplyError(&http.Error{StatusCode: 503})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	assert.NoError(t, srv.HandleWrite(ctx, NewBatch("2\n", opts.MaxRetryTime())))
+	assert.Equal(t, write.CircuitOpen, srv.CircuitState())
+	assert.Equal(t, 2, srv.retryQueue.list.Len())
+	hs.SetReplyError(nil)
+	srv.Flush()
+	assert.Equal(t, 1, hs.Requests())
+	assert.Equal(t, 0, srv.retryQueue.list.Len())
+	require.Len(t, list, 2)
+	assert.Equal(t, "2\n", list[1].lines)
+	assert.Equal(t, write.DiscardWriteFailed, list[1].reason)
+	assert.True(t, errors.Is(list[1].err, write.ErrCircuitOpen))
+
+	// batches of the persistent queue are kept
+	list = nil
+	opts.SetRetryQueueDir(t.TempDir())
+	srv = NewService("my-org", "my-bucket", hs, opts)
+	require.NoError(t, srv.OpenRetryQueue())
+	hs.SetReplyError(&http.Error{StatusCode: 503})
+	assert.Error(t, srv.HandleWrite(ctx, NewBatch("1\n", opts.MaxRetryTime())))
+	hs.SetReplyError(nil)
+	srv.Flush()
+	assert.Equal(t, 2, hs.Requests())
+	assert.Equal(t, 1, srv.retryQueue.list.Len())
+	assert.Len(t, list, 0)
+	require.NoError(t, srv.Close())
+}
+
+func TestConcurrentHandleWrite(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8086")
+	var mu sync.Mutex
+	requests := 0
+	hs.SetRequestHandler(func(url string, body io.Reader) error {
+		mu.Lock()
+		requests++
+		fail := requests%3 == 0
+		mu.Unlock()
+		if fail {
+			return &http.Error{StatusCode: 503}
+		}
+		return hs.DecodeLines(body)
+	})
+	opts := write.DefaultOptions().SetWriteWorkers(4).SetRetryStrategy(write.NewConstantRetryStrategy(0)).SetMaxRetries(100)
+	srv := NewService("my-org", "my-bucket", hs, opts)
+	ctx := context.Background()
+	var expected []string
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		var lines []string
+		for i := 0; i < 25; i++ {
+			lines = append(lines, fmt.Sprintf("w%d,i=%d f=1i", w, i))
+		}
+		expected = append(expected, lines...)
+		wg.Add(1)
+		go func(lines []string) {
+			defer wg.Done()
+			for _, l := range lines {
+				_ = srv.HandleWrite(ctx, NewBatch(l+"\n", opts.MaxRetryTime()))
+			}
+		}(lines)
+	}
+	wg.Wait()
+	for srv.HasPendingBatches() {
+		_ = srv.HandleWrite(ctx, nil)
+	}
+	assert.ElementsMatch(t, expected, hs.Lines())
+}
+
+func TestConcurrentHandleWriteOneFailed(t *testing.T) {
+	hs := test.NewTestService(t, "http://localhost:8086")
+	var mu sync.Mutex
+	failed := false
+	hs.SetRequestHandler(func(url string, body io.Reader) error {
+		// let other workers check the retry queue while this request is being sent
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		fail := !failed
+		failed = true
+		mu.Unlock()
+		if fail {
+			return &http.Error{StatusCode: 503}
+		}
+		return hs.DecodeLines(body)
+	})
+	opts := write.DefaultOptions().SetBatchSize(1).SetWriteWorkers(4).SetRetryInterval(1).SetMaxRetries(5)
+	srv := NewService("my-org", "my-bucket", hs, opts)
+	ctx := context.Background()
+	var expected []string
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		var lines []string
+		for i := 0; i < 10; i++ {
+			lines = append(lines, fmt.Sprintf("w%d,i=%d f=1i", w, i))
+		}
+		expected = append(expected, lines...)
+		wg.Add(1)
+		go func(lines []string) {
+			defer wg.Done()
+			for _, l := range lines {
+				_ = srv.HandleWrite(ctx, NewBatch(l+"\n", opts.MaxRetryTime()))
+			}
+		}(lines)
+	}
+	wg.Wait()
+	for srv.HasPendingBatches() {
+		time.Sleep(time.Millisecond)
+		_ = srv.HandleWrite(ctx, nil)
+	}
+	assert.ElementsMatch(t, expected, hs.Lines())
+}
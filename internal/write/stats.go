@@ -0,0 +1,138 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// latencyBounds are upper bounds of request latency histogram buckets
+var latencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// stats holds statistics of Service.
+// Counters are updated atomically, so they must stay at the beginning of the struct to be 64-bit aligned.
+type stats struct {
+	batchesWritten   uint64
+	linesWritten     uint64
+	bytesWritten     uint64
+	retries          uint64
+	discardedBatches uint64
+	expiredBatches   uint64
+	queueBatches     int64
+	queueBytes       int64
+
+	mu             sync.Mutex
+	failedRequests uint64
+	lastError      error
+	lastErrorTime  time.Time
+	lastWriteTime  time.Time
+	latency        []uint64
+	latencyCount   uint64
+	latencySum     time.Duration
+}
+
+func newStats() *stats {
+	return &stats{latency: make([]uint64, len(latencyBounds)+1)}
+}
+
+// request records result of a write request
+func (s *stats) request(duration time.Duration, err error) {
+	i := 0
+	for i < len(latencyBounds) && duration > latencyBounds[i] {
+		i++
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[i]++
+	s.latencyCount++
+	s.latencySum += duration
+	if err != nil {
+		s.failedRequests++
+		s.lastError = err
+		s.lastErrorTime = time.Now()
+	} else {
+		s.lastWriteTime = time.Now()
+	}
+}
+
+// written records successfully written batch
+func (s *stats) written() {
+	atomic.AddUint64(&s.batchesWritten, 1)
+}
+
+// bodyWritten records lines of body written by a request, lines rejected by the server are not counted
+func (s *stats) bodyWritten(body string, rejected []write.RejectedLine) {
+	lines, bytes := strings.Count(body, "\n"), len(body)
+	for _, l := range rejected {
+		if lines > 0 {
+			lines--
+		}
+		if l.Line != "" && bytes > len(l.Line) {
+			bytes -= len(l.Line) + 1
+		}
+	}
+	atomic.AddUint64(&s.linesWritten, uint64(lines))
+	atomic.AddUint64(&s.bytesWritten, uint64(bytes))
+}
+
+// discarded records batch discarded with reason
+func (s *stats) discarded(reason write.DiscardReason) {
+	switch reason {
+	case write.DiscardExpired:
+		atomic.AddUint64(&s.expiredBatches, 1)
+	case write.DiscardRejectedData, write.DiscardOverflow:
+		// only parts of batches or points, counted elsewhere
+	default:
+		atomic.AddUint64(&s.discardedBatches, 1)
+	}
+}
+
+// setQueue sets retry queue gauges
+func (s *stats) setQueue(q *queue) {
+	atomic.StoreInt64(&s.queueBatches, int64(q.list.Len()))
+	atomic.StoreInt64(&s.queueBytes, q.bytes)
+}
+
+// fill sets Service related fields of ws
+func (s *stats) fill(ws *write.Stats) {
+	ws.BatchesWritten = atomic.LoadUint64(&s.batchesWritten)
+	ws.LinesWritten = atomic.LoadUint64(&s.linesWritten)
+	ws.BytesWritten = atomic.LoadUint64(&s.bytesWritten)
+	ws.Retries = atomic.LoadUint64(&s.retries)
+	ws.DiscardedBatches = atomic.LoadUint64(&s.discardedBatches)
+	ws.ExpiredBatches = atomic.LoadUint64(&s.expiredBatches)
+	ws.RetryQueueBatches = int(atomic.LoadInt64(&s.queueBatches))
+	ws.RetryQueueBytes = atomic.LoadInt64(&s.queueBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws.FailedRequests = s.failedRequests
+	ws.LastError = s.lastError
+	ws.LastErrorTime = s.lastErrorTime
+	ws.LastWriteTime = s.lastWriteTime
+	ws.RequestLatency = write.LatencyHistogram{
+		Bounds: append([]time.Duration{}, latencyBounds...),
+		Counts: append([]uint64{}, s.latency...),
+		Count:  s.latencyCount,
+		Sum:    s.latencySum,
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020-2021 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	s := newStats()
+	s.request(time.Millisecond, nil)
+	s.request(5*time.Millisecond, nil)
+	s.request(7*time.Millisecond, errors.New("failed"))
+	s.request(time.Minute, nil)
+	s.written()
+	s.bodyWritten("a\nb\n", nil)
+	s.bodyWritten("c\nbad\n", []write.RejectedLine{{Number: 2, Line: "bad"}})
+	s.discarded(write.DiscardExpired)
+	s.discarded(write.DiscardMaxRetries)
+	s.discarded(write.DiscardRejectedData)
+
+	q := newQueue(5)
+	q.push(&Batch{Batch: "c\n"})
+	q.push(&Batch{Batch: "d\ne\n"})
+	q.push(&Batch{Batch: "f\n"})
+	q.pop()
+	// first batch partially written
+	q.first().Batch = "e\n"
+	q.update(q.first())
+	s.setQueue(q)
+
+	var ws write.Stats
+	s.fill(&ws)
+	assert.EqualValues(t, 1, ws.BatchesWritten)
+	assert.EqualValues(t, 3, ws.LinesWritten)
+	assert.EqualValues(t, 6, ws.BytesWritten)
+	assert.EqualValues(t, 1, ws.ExpiredBatches)
+	assert.EqualValues(t, 1, ws.DiscardedBatches)
+	assert.EqualValues(t, 1, ws.FailedRequests)
+	assert.EqualError(t, ws.LastError, "failed")
+	assert.EqualValues(t, 2, ws.RetryQueueBatches)
+	assert.EqualValues(t, 4, ws.RetryQueueBytes)
+
+	h := ws.RequestLatency
+	require.Len(t, h.Counts, len(h.Bounds)+1)
+	assert.EqualValues(t, 2, h.Counts[0])
+	assert.EqualValues(t, 1, h.Counts[1])
+	assert.EqualValues(t, 1, h.Counts[len(h.Counts)-1])
+	assert.EqualValues(t, 4, h.Count)
+	assert.Equal(t, (time.Minute+13*time.Millisecond)/4, h.Mean())
+}